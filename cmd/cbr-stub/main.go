@@ -0,0 +1,27 @@
+// Команда cbr-stub запускает локальную заглушку SOAP-сервиса ЦБ РФ.
+// Для работы банковского сервиса с заглушкой укажите CBR_URL=http://localhost:8081/DailyInfoWebServ/DailyInfo.asmx
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/cbrstub"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "адрес, на котором слушает заглушка")
+	keyRate := flag.Float64("key-rate", 21.00, "ключевая ставка, которую возвращает заглушка")
+	flag.Parse()
+
+	stub := cbrstub.NewServer()
+	stub.KeyRate = *keyRate
+
+	mux := http.NewServeMux()
+	mux.Handle("/DailyInfoWebServ/DailyInfo.asmx", stub)
+
+	// Заглушка не загружает .env и ключи банковского сервиса, поэтому пишет в стандартный лог
+	log.Printf("CBR stub is running on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
@@ -65,6 +65,23 @@ func InitDB(db *sql.DB) error {
 		return err
 	}
 
+	// Роль пользователя: customer — клиент банка, support — сотрудник службы поддержки
+	alterUserRoleQuery := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'customer';
+	`
+	if _, err := db.Exec(alterUserRoleQuery); err != nil {
+		return err
+	}
+
+	// Настройка напоминаний о платежах по кредиту: за сколько дней до платежа напоминать
+	// (NULL — значение по умолчанию PAYMENT_REMINDER_DAYS, 0 — не напоминать)
+	alterUserReminderDaysQuery := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS payment_reminder_days INTEGER;
+	`
+	if _, err := db.Exec(alterUserReminderDaysQuery); err != nil {
+		return err
+	}
+
 	// Создание таблицы счетов
 	createAccountsTableQuery := `
 	CREATE TABLE IF NOT EXISTS accounts (
@@ -95,6 +112,51 @@ func InitDB(db *sql.DB) error {
 		return err
 	}
 
+	// Статус карты: active, blocked, lost, expired или closed. Карты не удаляются, а закрываются,
+	// перевыпущенная карта ссылается на карту, которую она заменила
+	alterCardsStatusQuery := `
+	ALTER TABLE cards ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+	ALTER TABLE cards ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+	ALTER TABLE cards ADD COLUMN IF NOT EXISTS reissued_from INTEGER REFERENCES cards(id);
+	CREATE INDEX IF NOT EXISTS idx_cards_status ON cards (status);
+	`
+	if _, err := db.Exec(alterCardsStatusQuery); err != nil {
+		return err
+	}
+
+	// Хеш номера карты (HMAC) для поиска карты по номеру без расшифровки
+	alterCardsPANHashQuery := `
+	ALTER TABLE cards ADD COLUMN IF NOT EXISTS pan_hash VARCHAR(64);
+	CREATE INDEX IF NOT EXISTS idx_cards_pan_hash ON cards (pan_hash);
+	`
+	if _, err := db.Exec(alterCardsPANHashQuery); err != nil {
+		return err
+	}
+
+	// CVV, зашифрованный PGP, для показа владельцу: в столбце cvv хранится только bcrypt-хеш для проверки
+	alterCardsCVVEncryptedQuery := `
+	ALTER TABLE cards ADD COLUMN IF NOT EXISTS cvv_encrypted TEXT;
+	`
+	if _, err := db.Exec(alterCardsCVVEncryptedQuery); err != nil {
+		return err
+	}
+
+	// Счетчик неверных CVV и сроков действия подряд: после CARD_MAX_FAILED_VERIFICATIONS ошибок карта блокируется
+	alterCardsFailedVerificationsQuery := `
+	ALTER TABLE cards ADD COLUMN IF NOT EXISTS failed_verifications INTEGER NOT NULL DEFAULT 0;
+	`
+	if _, err := db.Exec(alterCardsFailedVerificationsQuery); err != nil {
+		return err
+	}
+
+	// Маскированный номер карты (первые 6 и последние 4 цифры), который показывается без расшифровки
+	alterCardsPANMaskedQuery := `
+	ALTER TABLE cards ADD COLUMN IF NOT EXISTS pan_masked VARCHAR(19);
+	`
+	if _, err := db.Exec(alterCardsPANMaskedQuery); err != nil {
+		return err
+	}
+
 	// Создание таблицы переводов
 	createTransfersTableQuery := `
 	CREATE TABLE IF NOT EXISTS transfers (
@@ -110,6 +172,25 @@ func InitDB(db *sql.DB) error {
 		return err
 	}
 
+	// Валюты и курс конвертации для переводов между счетами в разных валютах.
+	// Для ранее созданных переводов сумма зачисления равна сумме списания
+	alterTransfersTableQuery := `
+	ALTER TABLE transfers ADD COLUMN IF NOT EXISTS currency VARCHAR(3);
+	ALTER TABLE transfers ADD COLUMN IF NOT EXISTS to_amount DECIMAL(15, 2);
+	ALTER TABLE transfers ADD COLUMN IF NOT EXISTS to_currency VARCHAR(3);
+	ALTER TABLE transfers ADD COLUMN IF NOT EXISTS exchange_rate DECIMAL(18, 8) NOT NULL DEFAULT 1;
+	UPDATE transfers t SET currency = COALESCE(a.currency, 'RUB')
+	FROM accounts a WHERE t.currency IS NULL AND a.id = t.from_account;
+	UPDATE transfers SET currency = 'RUB' WHERE currency IS NULL;
+	UPDATE transfers SET to_amount = amount, to_currency = currency WHERE to_amount IS NULL;
+	ALTER TABLE transfers ALTER COLUMN currency SET NOT NULL;
+	ALTER TABLE transfers ALTER COLUMN to_amount SET NOT NULL;
+	ALTER TABLE transfers ALTER COLUMN to_currency SET NOT NULL;
+	`
+	if _, err := db.Exec(alterTransfersTableQuery); err != nil {
+		return err
+	}
+
 	// Создание таблицы кредитов
 	createCreditsTableQuery := `
 	CREATE TABLE IF NOT EXISTS credits (
@@ -125,6 +206,66 @@ func InitDB(db *sql.DB) error {
 		return err
 	}
 
+	// Создание каталога кредитных продуктов и таблиц спредов
+	createCreditProductsTableQuery := `
+	CREATE TABLE IF NOT EXISTS credit_products (
+		id SERIAL PRIMARY KEY,
+		code VARCHAR(50) NOT NULL UNIQUE,
+		name VARCHAR(255) NOT NULL,
+		min_amount DECIMAL(15, 2) NOT NULL CHECK (min_amount > 0),
+		max_amount DECIMAL(15, 2) NOT NULL,
+		terms INTEGER[] NOT NULL,
+		issue_fee_percent DECIMAL(5, 2) NOT NULL DEFAULT 0 CHECK (issue_fee_percent >= 0),
+		issue_fee_fixed DECIMAL(15, 2) NOT NULL DEFAULT 0 CHECK (issue_fee_fixed >= 0),
+		is_active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CHECK (max_amount >= min_amount)
+	);
+	CREATE TABLE IF NOT EXISTS credit_product_spreads (
+		id SERIAL PRIMARY KEY,
+		product_id INTEGER NOT NULL REFERENCES credit_products(id) ON DELETE CASCADE,
+		min_term INTEGER NOT NULL,
+		max_term INTEGER NOT NULL,
+		min_amount DECIMAL(15, 2) NOT NULL,
+		max_amount DECIMAL(15, 2) NOT NULL,
+		spread DECIMAL(5, 2) NOT NULL,
+		CHECK (max_term >= min_term AND max_amount >= min_amount)
+	);
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS product_id INTEGER REFERENCES credit_products(id);
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS fee DECIMAL(15, 2) NOT NULL DEFAULT 0;
+	`
+	if _, err := db.Exec(createCreditProductsTableQuery); err != nil {
+		return err
+	}
+
+	// Начальный каталог продуктов. Существующие продукты и их спреды не перезаписываются
+	seedCreditProductsQuery := `
+	INSERT INTO credit_products (code, name, min_amount, max_amount, terms, issue_fee_percent, issue_fee_fixed)
+	VALUES
+		('consumer', 'Потребительский кредит', 10000, 3000000, '{6,12,18,24,36,48,60}', 1.0, 0),
+		('mortgage', 'Ипотека', 500000, 30000000, '{60,120,180,240,300,360}', 0, 5000),
+		('car', 'Автокредит', 100000, 5000000, '{12,24,36,48,60,84}', 0.5, 0)
+	ON CONFLICT (code) DO NOTHING;
+
+	INSERT INTO credit_product_spreads (product_id, min_term, max_term, min_amount, max_amount, spread)
+	SELECT p.id, v.min_term, v.max_term, v.min_amount, v.max_amount, v.spread
+	FROM credit_products p
+	JOIN (VALUES
+		('consumer', 1, 12, 0, 299999.99, 5.0),
+		('consumer', 1, 12, 300000, 9999999999999.99, 4.0),
+		('consumer', 13, 60, 0, 299999.99, 6.0),
+		('consumer', 13, 60, 300000, 9999999999999.99, 5.0),
+		('mortgage', 1, 180, 0, 9999999999999.99, 2.0),
+		('mortgage', 181, 360, 0, 9999999999999.99, 2.5),
+		('car', 1, 36, 0, 9999999999999.99, 3.0),
+		('car', 37, 84, 0, 9999999999999.99, 3.5)
+	) AS v(code, min_term, max_term, min_amount, max_amount, spread) ON v.code = p.code
+	WHERE NOT EXISTS (SELECT 1 FROM credit_product_spreads s WHERE s.product_id = p.id);
+	`
+	if _, err := db.Exec(seedCreditProductsQuery); err != nil {
+		return err
+	}
+
 	// Создание таблицы графика платежей
 	createPaymentSchedulesTableQuery := `
 	CREATE TABLE IF NOT EXISTS payment_schedules (
@@ -140,6 +281,17 @@ func InitDB(db *sql.DB) error {
 		return err
 	}
 
+	// Тип графика платежей по кредиту и разбивка платежей графика на основной долг и проценты
+	alterScheduleColumnsQuery := `
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS schedule_type VARCHAR(20) NOT NULL DEFAULT 'annuity';
+	ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS principal DECIMAL(15, 2);
+	ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS interest DECIMAL(15, 2);
+	ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS remaining_balance DECIMAL(15, 2);
+	`
+	if _, err := db.Exec(alterScheduleColumnsQuery); err != nil {
+		return err
+	}
+
 	// Создание таблицы платежей
 	createPaymentsTableQuery := `
 	CREATE TABLE IF NOT EXISTS payments (
@@ -155,6 +307,149 @@ func InitDB(db *sql.DB) error {
 		return err
 	}
 
+	// Статус кредита, тип платежа и связь платежей по графику со строками графика.
+	// Для ранее созданных кредитов ожидающие платежи связываются со строками графика по дате
+	alterPaymentsTableQuery := `
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS type VARCHAR(20) NOT NULL DEFAULT 'scheduled';
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS schedule_id INTEGER REFERENCES payment_schedules(id) ON DELETE SET NULL;
+	UPDATE payments p SET schedule_id = s.id
+	FROM payment_schedules s
+	WHERE p.schedule_id IS NULL AND p.status IN ('pending', 'failed')
+	AND s.credit_id = p.credit_id AND s.due_date = p.payment_date;
+	CREATE INDEX IF NOT EXISTS idx_payments_schedule_id ON payments (schedule_id);
+	`
+	if _, err := db.Exec(alterPaymentsTableQuery); err != nil {
+		return err
+	}
+
+	// Счет для автоматического списания платежей по кредиту, счет зачисления кредита
+	// и счет, с которого был списан платеж
+	alterRepaymentAccountQuery := `
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS repayment_account_id INTEGER REFERENCES accounts(id) ON DELETE SET NULL;
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS account_id INTEGER REFERENCES accounts(id) ON DELETE SET NULL;
+	ALTER TABLE payments ADD COLUMN IF NOT EXISTS account_id INTEGER REFERENCES accounts(id) ON DELETE SET NULL;
+	`
+	if _, err := db.Exec(alterRepaymentAccountQuery); err != nil {
+		return err
+	}
+
+	// Разбивка задолженности по кредиту на основной долг, проценты, неустойку и комиссии.
+	// Для ранее выданных кредитов основным долгом считается непогашенный основной долг по графику
+	// (вся задолженность для строк без разбивки), остаток задолженности — неустойкой
+	alterCreditBalanceQuery := `
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS principal_outstanding DECIMAL(15, 2) NOT NULL DEFAULT 0;
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS interest_outstanding DECIMAL(15, 2) NOT NULL DEFAULT 0;
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS penalties_outstanding DECIMAL(15, 2) NOT NULL DEFAULT 0;
+	ALTER TABLE credits ADD COLUMN IF NOT EXISTS fees_outstanding DECIMAL(15, 2) NOT NULL DEFAULT 0;
+	ALTER TABLE payment_schedules ADD COLUMN IF NOT EXISTS interest_accrued BOOLEAN NOT NULL DEFAULT FALSE;
+	UPDATE credits c SET
+		principal_outstanding = LEAST(c.amount, u.principal),
+		penalties_outstanding = c.amount - LEAST(c.amount, u.principal)
+	FROM (
+		SELECT cr.id, COALESCE(SUM(COALESCE(s.principal, cr.amount)), 0) AS principal
+		FROM credits cr
+		LEFT JOIN payment_schedules s ON s.credit_id = cr.id AND NOT s.is_paid
+		GROUP BY cr.id
+	) u
+	WHERE u.id = c.id AND c.amount > 0
+	AND c.principal_outstanding = 0 AND c.interest_outstanding = 0
+	AND c.penalties_outstanding = 0 AND c.fees_outstanding = 0;
+	`
+	if _, err := db.Exec(alterCreditBalanceQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы заявок на кредит
+	createCreditApplicationsTableQuery := `
+	CREATE TABLE IF NOT EXISTS credit_applications (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		product_id INTEGER REFERENCES credit_products(id),
+		account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+		amount DECIMAL(15, 2) NOT NULL,
+		term INTEGER NOT NULL,
+		schedule_type VARCHAR(20) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		score INTEGER,
+		debt_to_income DECIMAL(7, 2),
+		reject_reason TEXT,
+		credit_id INTEGER REFERENCES credits(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_credit_applications_user_id ON credit_applications (user_id);
+	`
+	if _, err := db.Exec(createCreditApplicationsTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы реструктуризаций кредитов и архива графиков платежей.
+	// Перед каждой реструктуризацией действовавший график целиком сохраняется под ее номером версии
+	createCreditRestructuringsTableQuery := `
+	CREATE TABLE IF NOT EXISTS credit_restructurings (
+		id SERIAL PRIMARY KEY,
+		credit_id INTEGER REFERENCES credits(id) ON DELETE CASCADE,
+		operator_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		version INTEGER NOT NULL,
+		grace_months INTEGER NOT NULL DEFAULT 0,
+		extend_months INTEGER NOT NULL DEFAULT 0,
+		old_interest_rate DECIMAL(5, 2) NOT NULL,
+		new_interest_rate DECIMAL(5, 2) NOT NULL,
+		old_term INTEGER NOT NULL,
+		new_term INTEGER NOT NULL,
+		reason TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (credit_id, version)
+	);
+	CREATE TABLE IF NOT EXISTS payment_schedule_versions (
+		id SERIAL PRIMARY KEY,
+		restructuring_id INTEGER REFERENCES credit_restructurings(id) ON DELETE CASCADE,
+		schedule_id INTEGER,
+		due_date TIMESTAMP NOT NULL,
+		amount DECIMAL(15, 2) NOT NULL,
+		principal DECIMAL(15, 2),
+		interest DECIMAL(15, 2),
+		remaining_balance DECIMAL(15, 2),
+		is_paid BOOLEAN NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_payment_schedule_versions_restructuring_id ON payment_schedule_versions (restructuring_id);
+	`
+	if _, err := db.Exec(createCreditRestructuringsTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы справок о полном погашении кредита: одна справка на кредит
+	createClosureCertificatesTableQuery := `
+	CREATE TABLE IF NOT EXISTS credit_closure_certificates (
+		id SERIAL PRIMARY KEY,
+		credit_id INTEGER UNIQUE REFERENCES credits(id) ON DELETE CASCADE,
+		number VARCHAR(50) UNIQUE NOT NULL,
+		closed_at TIMESTAMP NOT NULL,
+		total_paid DECIMAL(15, 2) NOT NULL,
+		document TEXT NOT NULL,
+		emailed_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := db.Exec(createClosureCertificatesTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы отправленных напоминаний о платежах: не более одного напоминания на строку графика,
+	// даже если планировщик запущен в нескольких экземплярах
+	createPaymentRemindersTableQuery := `
+	CREATE TABLE IF NOT EXISTS payment_reminders (
+		id SERIAL PRIMARY KEY,
+		schedule_id INTEGER UNIQUE REFERENCES payment_schedules(id) ON DELETE CASCADE,
+		credit_id INTEGER REFERENCES credits(id) ON DELETE CASCADE,
+		sent_at TIMESTAMP NOT NULL
+	);
+	`
+	if _, err := db.Exec(createPaymentRemindersTableQuery); err != nil {
+		return err
+	}
+
 	// Создание таблицы операций
 	createTransactionsTableQuery := `
 	CREATE TABLE IF NOT EXISTS transactions (
@@ -171,5 +466,292 @@ func InitDB(db *sql.DB) error {
 		return err
 	}
 
+	// Создание таблицы записей журнала главной книги
+	createJournalEntriesTableQuery := `
+	CREATE TABLE IF NOT EXISTS journal_entries (
+		id SERIAL PRIMARY KEY,
+		type VARCHAR(30) NOT NULL,
+		reference_id INTEGER,
+		description TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := db.Exec(createJournalEntriesTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы проводок
+	createPostingsTableQuery := `
+	CREATE TABLE IF NOT EXISTS postings (
+		id SERIAL PRIMARY KEY,
+		entry_id INTEGER REFERENCES journal_entries(id) ON DELETE CASCADE,
+		ledger_account VARCHAR(30) NOT NULL,
+		account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+		credit_id INTEGER REFERENCES credits(id) ON DELETE CASCADE,
+		direction VARCHAR(6) NOT NULL CHECK (direction IN ('debit', 'credit')),
+		amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+		currency VARCHAR(3) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_postings_account_id ON postings (account_id);
+	CREATE INDEX IF NOT EXISTS idx_postings_credit_id ON postings (credit_id);
+	`
+	if _, err := db.Exec(createPostingsTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы начислений неустойки: не более одного начисления в день по платежу.
+	// Штрафы, начисленные до ведения таблицы разовой проводкой, переносятся в нее, чтобы не начислять их повторно
+	createPenaltiesTableQuery := `
+	CREATE TABLE IF NOT EXISTS penalties (
+		id SERIAL PRIMARY KEY,
+		credit_id INTEGER REFERENCES credits(id) ON DELETE CASCADE,
+		payment_id INTEGER REFERENCES payments(id) ON DELETE CASCADE,
+		accrual_date DATE NOT NULL,
+		amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (payment_id, accrual_date)
+	);
+	CREATE INDEX IF NOT EXISTS idx_penalties_credit_id ON penalties (credit_id);
+	INSERT INTO penalties (credit_id, payment_id, accrual_date, amount)
+	SELECT p.credit_id, p.id, MAX(e.created_at)::date, SUM(ps.amount)
+	FROM payments p
+	JOIN journal_entries e ON e.type = 'penalty' AND e.reference_id = p.id
+	JOIN postings ps ON ps.entry_id = e.id AND ps.ledger_account = 'penalty_income'
+	WHERE NOT EXISTS (SELECT 1 FROM penalties pn WHERE pn.payment_id = p.id)
+	GROUP BY p.credit_id, p.id;
+	`
+	if _, err := db.Exec(createPenaltiesTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы истории ключевой ставки ЦБ РФ
+	createKeyRatesTableQuery := `
+	CREATE TABLE IF NOT EXISTS key_rates (
+		date DATE PRIMARY KEY,
+		rate DECIMAL(5, 2) NOT NULL,
+		fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := db.Exec(createKeyRatesTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы истории курсов валют ЦБ РФ
+	createCurrencyRatesTableQuery := `
+	CREATE TABLE IF NOT EXISTS currency_rates (
+		date DATE NOT NULL,
+		code VARCHAR(3) NOT NULL,
+		nominal INTEGER NOT NULL,
+		value DECIMAL(15, 4) NOT NULL,
+		fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (date, code)
+	);
+	`
+	if _, err := db.Exec(createCurrencyRatesTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы периодов, за которые данные ЦБ РФ уже загружены
+	createRateFetchRangesTableQuery := `
+	CREATE TABLE IF NOT EXISTS rate_fetch_ranges (
+		id SERIAL PRIMARY KEY,
+		source VARCHAR(20) NOT NULL,
+		from_date DATE NOT NULL,
+		to_date DATE NOT NULL,
+		fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_rate_fetch_ranges_source ON rate_fetch_ranges (source, from_date, to_date);
+	`
+	if _, err := db.Exec(createRateFetchRangesTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы котировок обмена валюты
+	createFXQuotesTableQuery := `
+	CREATE TABLE IF NOT EXISTS fx_quotes (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		from_account INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+		to_account INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+		amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+		from_currency VARCHAR(3) NOT NULL,
+		to_amount DECIMAL(15, 2) NOT NULL CHECK (to_amount > 0),
+		to_currency VARCHAR(3) NOT NULL,
+		market_rate DECIMAL(18, 8) NOT NULL,
+		rate DECIMAL(18, 8) NOT NULL,
+		spread_percent DECIMAL(5, 2) NOT NULL DEFAULT 0,
+		status VARCHAR(20) NOT NULL CHECK (status IN ('active', 'executed')),
+		expires_at TIMESTAMP NOT NULL,
+		executed_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := db.Exec(createFXQuotesTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы ключей идемпотентности
+	createIdempotencyKeysTableQuery := `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		key VARCHAR(255) NOT NULL,
+		method VARCHAR(10) NOT NULL,
+		path TEXT NOT NULL,
+		request_hash VARCHAR(64) NOT NULL,
+		status_code INTEGER,
+		content_type VARCHAR(255),
+		response_body BYTEA,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, key)
+	);
+	`
+	if _, err := db.Exec(createIdempotencyKeysTableQuery); err != nil {
+		return err
+	}
+
+	// Аренда ключа идемпотентности: пока она не истекла, ключ занят обрабатывающим запрос процессом.
+	// Ключ без ответа с истекшей арендой (процесс упал) может занять повтор запроса
+	alterIdempotencyKeysLeaseQuery := `
+	ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+	`
+	if _, err := db.Exec(alterIdempotencyKeysLeaseQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы истории запусков задач планировщика
+	createJobRunsTableQuery := `
+	CREATE TABLE IF NOT EXISTS job_runs (
+		id SERIAL PRIMARY KEY,
+		job_name VARCHAR(100) NOT NULL,
+		triggered_by VARCHAR(20) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		instance VARCHAR(255),
+		started_at TIMESTAMP NOT NULL,
+		finished_at TIMESTAMP,
+		duration_ms BIGINT,
+		error TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_job_runs_job_name_started_at ON job_runs (job_name, started_at DESC);
+	`
+	if _, err := db.Exec(createJobRunsTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблицы блокировок средств на счетах
+	createAccountHoldsTableQuery := `
+	CREATE TABLE IF NOT EXISTS account_holds (
+		id SERIAL PRIMARY KEY,
+		account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+		amount DECIMAL(15, 2) NOT NULL,
+		currency VARCHAR(3) NOT NULL,
+		source VARCHAR(50) NOT NULL,
+		description TEXT,
+		reference_id INTEGER,
+		status VARCHAR(20) NOT NULL DEFAULT 'active',
+		expires_at TIMESTAMP NOT NULL,
+		released_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE account_holds ADD COLUMN IF NOT EXISTS description TEXT;
+	CREATE INDEX IF NOT EXISTS idx_account_holds_account_status ON account_holds (account_id, status);
+	CREATE INDEX IF NOT EXISTS idx_account_holds_status_expires_at ON account_holds (status, expires_at);
+	`
+	if _, err := db.Exec(createAccountHoldsTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблиц авторизаций по картам и операций по ним
+	createCardAuthorizationsTableQuery := `
+	CREATE TABLE IF NOT EXISTS card_authorizations (
+		id SERIAL PRIMARY KEY,
+		card_id INTEGER REFERENCES cards(id) ON DELETE CASCADE,
+		account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+		merchant_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		hold_id INTEGER REFERENCES account_holds(id) ON DELETE SET NULL,
+		amount DECIMAL(15, 2) NOT NULL,
+		captured_amount DECIMAL(15, 2) NOT NULL DEFAULT 0,
+		refunded_amount DECIMAL(15, 2) NOT NULL DEFAULT 0,
+		currency VARCHAR(3) NOT NULL,
+		description TEXT,
+		status VARCHAR(20) NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_card_authorizations_status_expires ON card_authorizations (status, expires_at);
+
+	CREATE TABLE IF NOT EXISTS card_payment_operations (
+		id SERIAL PRIMARY KEY,
+		authorization_id INTEGER REFERENCES card_authorizations(id) ON DELETE CASCADE,
+		type VARCHAR(20) NOT NULL,
+		amount DECIMAL(15, 2) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := db.Exec(createCardAuthorizationsTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблиц лимитов карт и отказов в оплате картой, код категории торгового предприятия в авторизациях
+	createCardLimitsTableQuery := `
+	ALTER TABLE card_authorizations ADD COLUMN IF NOT EXISTS mcc VARCHAR(4);
+	CREATE INDEX IF NOT EXISTS idx_card_authorizations_card_created ON card_authorizations (card_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS card_limits (
+		card_id INTEGER PRIMARY KEY REFERENCES cards(id) ON DELETE CASCADE,
+		per_transaction_limit DECIMAL(15, 2),
+		daily_limit DECIMAL(15, 2),
+		monthly_limit DECIMAL(15, 2),
+		allowed_mccs TEXT[] NOT NULL DEFAULT '{}',
+		blocked_mccs TEXT[] NOT NULL DEFAULT '{}',
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS card_declines (
+		id SERIAL PRIMARY KEY,
+		card_id INTEGER REFERENCES cards(id) ON DELETE CASCADE,
+		merchant_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		amount DECIMAL(15, 2) NOT NULL,
+		mcc VARCHAR(4),
+		reason VARCHAR(50) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_card_declines_card_created ON card_declines (card_id, created_at DESC);
+	`
+	if _, err := db.Exec(createCardLimitsTableQuery); err != nil {
+		return err
+	}
+
+	// Создание таблиц одноразовых кодов и журнала показа полных реквизитов карт
+	createCardRevealTablesQuery := `
+	CREATE TABLE IF NOT EXISTS card_reveal_otps (
+		id SERIAL PRIMARY KEY,
+		card_id INTEGER REFERENCES cards(id) ON DELETE CASCADE,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		code_hash VARCHAR(64) NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_card_reveal_otps_card_user ON card_reveal_otps (card_id, user_id, created_at DESC);
+
+	CREATE TABLE IF NOT EXISTS card_reveal_audit (
+		id SERIAL PRIMARY KEY,
+		card_id INTEGER REFERENCES cards(id) ON DELETE CASCADE,
+		user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		method VARCHAR(20) NOT NULL,
+		result VARCHAR(30) NOT NULL,
+		ip VARCHAR(64),
+		user_agent TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_card_reveal_audit_card_created ON card_reveal_audit (card_id, created_at DESC);
+	`
+	if _, err := db.Exec(createCardRevealTablesQuery); err != nil {
+		return err
+	}
+
 	return nil
 }
\ No newline at end of file
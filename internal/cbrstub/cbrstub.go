@@ -0,0 +1,151 @@
+// Package cbrstub — упрощенная имитация SOAP-сервиса DailyInfo ЦБ РФ
+// для локальной разработки и проверки клиента без доступа к cbr.ru.
+// Поддерживаются методы KeyRate и GetCursOnDate; формат ответов повторяет оригинальный сервис
+package cbrstub
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// Currency — курс валюты, который возвращает заглушка
+type Currency struct {
+	Name    string
+	Nominal int
+	Value   float64 // стоимость Nominal единиц в рублях
+	Code    int     // цифровой код ISO 4217
+}
+
+// Server — обработчик SOAP-запросов с фиксированными данными
+type Server struct {
+	KeyRate    float64
+	Currencies map[string]Currency // по буквенному коду валюты
+}
+
+// NewServer возвращает заглушку с правдоподобными данными по умолчанию
+func NewServer() *Server {
+	return &Server{
+		KeyRate: 21.00,
+		Currencies: map[string]Currency{
+			"USD": {Name: "Доллар США", Nominal: 1, Value: 90.1234, Code: 840},
+			"EUR": {Name: "Евро", Nominal: 1, Value: 98.5678, Code: 978},
+			"CNY": {Name: "Китайский юань", Nominal: 1, Value: 12.3456, Code: 156},
+			"JPY": {Name: "Японская иена", Nominal: 100, Value: 60.4321, Code: 392},
+		},
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeFault(w, fmt.Sprintf("failed to read request: %v", err))
+		return
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(body); err != nil {
+		writeFault(w, fmt.Sprintf("invalid XML: %v", err))
+		return
+	}
+
+	// Метод определяется по SOAPAction (SOAP 1.1) или по элементу тела запроса (SOAP 1.2)
+	action := strings.Trim(r.Header.Get("SOAPAction"), `"`)
+	action = strings.TrimPrefix(action, "http://web.cbr.ru/")
+	switch {
+	case action == "KeyRate" || doc.FindElement("//Body/KeyRate") != nil:
+		s.keyRate(w, doc)
+	case action == "GetCursOnDate" || doc.FindElement("//Body/GetCursOnDate") != nil:
+		s.cursOnDate(w, doc)
+	default:
+		writeFault(w, "unsupported action: "+action)
+	}
+}
+
+func (s *Server) keyRate(w http.ResponseWriter, doc *etree.Document) {
+	fromDate, err := requestDate(doc, "//KeyRate/fromDate")
+	if err != nil {
+		writeFault(w, err.Error())
+		return
+	}
+	toDate, err := requestDate(doc, "//KeyRate/ToDate")
+	if err != nil {
+		writeFault(w, err.Error())
+		return
+	}
+
+	// Как и оригинальный сервис, возвращаем значения за период начиная с последнего
+	var rows strings.Builder
+	for i, date := 0, toDate; !date.Before(fromDate); i, date = i+1, date.AddDate(0, 0, -1) {
+		fmt.Fprintf(&rows, `<KR diffgr:id="KR%d" msdata:rowOrder="%d"><DT>%s</DT><Rate>%.2f</Rate></KR>`,
+			i+1, i, date.Format("2006-01-02T15:04:05-07:00"), s.KeyRate)
+	}
+	writeResult(w, "KeyRate", `<KeyRate xmlns="">`+rows.String()+`</KeyRate>`)
+}
+
+func (s *Server) cursOnDate(w http.ResponseWriter, doc *etree.Document) {
+	date, err := requestDate(doc, "//GetCursOnDate/On_date")
+	if err != nil {
+		writeFault(w, err.Error())
+		return
+	}
+
+	codes := make([]string, 0, len(s.Currencies))
+	for code := range s.Currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var rows strings.Builder
+	for i, code := range codes {
+		currency := s.Currencies[code]
+		fmt.Fprintf(&rows, `<ValuteCursOnDate diffgr:id="ValuteCursOnDate%d" msdata:rowOrder="%d">`+
+			`<Vname>%s</Vname><Vnom>%d</Vnom><Vcurs>%.4f</Vcurs><Vcode>%d</Vcode><VchCode>%s</VchCode>`+
+			`</ValuteCursOnDate>`, i+1, i, currency.Name, currency.Nominal, currency.Value, currency.Code, code)
+	}
+	writeResult(w, "GetCursOnDate",
+		fmt.Sprintf(`<ValuteData xmlns="" OnDate="%s">%s</ValuteData>`, date.Format("20060102"), rows.String()))
+}
+
+func requestDate(doc *etree.Document, path string) (time.Time, error) {
+	element := doc.FindElement(path)
+	if element == nil {
+		return time.Time{}, fmt.Errorf("element %s is missing", path)
+	}
+	value := strings.TrimSpace(element.Text())
+	if len(value) > len("2006-01-02") {
+		value = value[:len("2006-01-02")]
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, time.FixedZone("MSK", 3*60*60))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date in %s: %v", path, err)
+	}
+	return date, nil
+}
+
+func writeResult(w http.ResponseWriter, method, data string) {
+	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>`+
+		`<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xsd="http://www.w3.org/2001/XMLSchema">`+
+		`<soap:Body><%[1]sResponse xmlns="http://web.cbr.ru/"><%[1]sResult>`+
+		`<diffgr:diffgram xmlns:msdata="urn:schemas-microsoft-com:xml-msdata" xmlns:diffgr="urn:schemas-microsoft-com:xml-diffgram-v1">%[2]s</diffgr:diffgram>`+
+		`</%[1]sResult></%[1]sResponse></soap:Body></soap:Envelope>`, method, data)
+}
+
+func writeFault(w http.ResponseWriter, reason string) {
+	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>`+
+		`<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body><soap:Fault>`+
+		`<soap:Code><soap:Value>soap:Sender</soap:Value></soap:Code>`+
+		`<soap:Reason><soap:Text xml:lang="en">%s</soap:Text></soap:Reason>`+
+		`</soap:Fault></soap:Body></soap:Envelope>`, reason)
+}
@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
 )
@@ -42,7 +46,7 @@ func (h *CardHandler) CreateCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(card)
+	writeCard(w, card)
 }
 
 func (h *CardHandler) GetAccountCards(w http.ResponseWriter, r *http.Request) {
@@ -71,7 +75,7 @@ func (h *CardHandler) GetAccountCards(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    json.NewEncoder(w).Encode(cards)
+    writeCards(w, cards)
 }
 
 func (h *CardHandler) GetCard(w http.ResponseWriter, r *http.Request) {
@@ -98,7 +102,7 @@ func (h *CardHandler) GetCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(card)
+	writeCard(w, card)
 }
 
 func (h *CardHandler) DeleteCard(w http.ResponseWriter, r *http.Request) {
@@ -118,10 +122,254 @@ func (h *CardHandler) DeleteCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.DeleteCard(uint(cardID)); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Карта не удаляется, а закрывается, чтобы сохранить историю
+	if err := h.service.CloseCard(uint(cardID)); err != nil {
+		writeCardError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
+}
+
+// BlockCard блокирует карту. С телом {"lost": true} карта отмечается утерянной и может быть только перевыпущена
+func (h *CardHandler) BlockCard(w http.ResponseWriter, r *http.Request) {
+	cardID, ok := h.ownedCardID(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Lost bool `json:"lost"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	card, err := h.service.BlockCard(cardID, request.Lost)
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+
+	writeCard(w, card)
+}
+
+// UnblockCard снимает временную блокировку карты
+func (h *CardHandler) UnblockCard(w http.ResponseWriter, r *http.Request) {
+	cardID, ok := h.ownedCardID(w, r)
+	if !ok {
+		return
+	}
+
+	card, err := h.service.UnblockCard(cardID)
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+
+	writeCard(w, card)
+}
+
+// ReissueCard закрывает карту и выпускает взамен новую
+func (h *CardHandler) ReissueCard(w http.ResponseWriter, r *http.Request) {
+	cardID, ok := h.ownedCardID(w, r)
+	if !ok {
+		return
+	}
+
+	card, err := h.service.ReissueCard(cardID)
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	writeCard(w, card)
+}
+
+// GetCardLimits возвращает лимиты карты и ограничения по категориям торговых предприятий
+func (h *CardHandler) GetCardLimits(w http.ResponseWriter, r *http.Request) {
+	cardID, ok := h.ownedCardID(w, r)
+	if !ok {
+		return
+	}
+
+	limits, err := h.service.GetCardLimits(cardID)
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(limits)
+}
+
+// UpdateCardLimits изменяет лимиты карты; поля, отсутствующие в запросе, не меняются
+func (h *CardHandler) UpdateCardLimits(w http.ResponseWriter, r *http.Request) {
+	cardID, ok := h.ownedCardID(w, r)
+	if !ok {
+		return
+	}
+
+	var request services.CardLimitsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limits, err := h.service.UpdateCardLimits(cardID, request)
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(limits)
+}
+
+// GetCardDeclines возвращает отказы в оплате картой с причинами
+func (h *CardHandler) GetCardDeclines(w http.ResponseWriter, r *http.Request) {
+	cardID, ok := h.ownedCardID(w, r)
+	if !ok {
+		return
+	}
+
+	declines, err := h.service.GetCardDeclines(cardID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(declines)
+}
+
+// RevealCard возвращает полный номер карты, срок действия и CVV после повторного ввода пароля или одноразового кода
+func (h *CardHandler) RevealCard(w http.ResponseWriter, r *http.Request) {
+	cardID, ok := h.ownedCardID(w, r)
+	if !ok {
+		return
+	}
+	userID := r.Context().Value("userID").(uint)
+
+	var request services.CardRevealRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	card, err := h.service.RevealCard(cardID, userID, request, revealClient(r))
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+
+	writeRevealedCard(w, card)
+}
+
+// RequestRevealOTP отправляет на почту владельца одноразовый код для показа реквизитов карты
+func (h *CardHandler) RequestRevealOTP(w http.ResponseWriter, r *http.Request) {
+	cardID, ok := h.ownedCardID(w, r)
+	if !ok {
+		return
+	}
+	userID := r.Context().Value("userID").(uint)
+
+	expiresAt, err := h.service.RequestRevealOTP(cardID, userID, revealClient(r))
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"expires_at": expiresAt})
+}
+
+// GetRevealEvents возвращает журнал показа реквизитов карты
+func (h *CardHandler) GetRevealEvents(w http.ResponseWriter, r *http.Request) {
+	cardID, ok := h.ownedCardID(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := h.service.GetRevealEvents(cardID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(events)
+}
+
+// revealClient возвращает адрес и User-Agent клиента для журнала показа реквизитов
+func revealClient(r *http.Request) services.CardRevealClient {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return services.CardRevealClient{IP: ip, UserAgent: r.UserAgent()}
+}
+
+// ownedCardID разбирает card_id из пути и проверяет, что карта принадлежит пользователю
+func (h *CardHandler) ownedCardID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	vars := mux.Vars(r)
+	cardID, err := strconv.ParseUint(vars["card_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	// Проверяем, что карта принадлежит пользователю
+	if !h.service.CardBelongsToUser(uint(cardID), userID) {
+		http.Error(w, "Card does not belong to user", http.StatusForbidden)
+		return 0, false
+	}
+	return uint(cardID), true
+}
+
+// writeCard отдает карту без полного номера и CVV. Их возвращает только RevealCard;
+// если сервис по ошибке вернул их в другом ответе, они вырезаются и это логируется
+func writeCard(w http.ResponseWriter, card *models.Card) {
+	stripCardNumber(card)
+	json.NewEncoder(w).Encode(card)
+}
+
+// writeCards — writeCard для списка карт
+func writeCards(w http.ResponseWriter, cards []models.Card) {
+	for i := range cards {
+		stripCardNumber(&cards[i])
+	}
+	json.NewEncoder(w).Encode(cards)
+}
+
+func stripCardNumber(card *models.Card) {
+	if card.Number == "" && card.CVV == "" {
+		return
+	}
+	utils.Log.WithField("card_id", card.ID).Error("Full card number or CVV in a non-reveal card response, removed")
+	card.Number = ""
+	card.CVV = ""
+}
+
+// writeRevealedCard отправляет реквизиты карты, показанные владельцу: полный номер, срок действия и CVV
+func writeRevealedCard(w http.ResponseWriter, card *models.Card) {
+	// Реквизиты карты не должны сохраняться в кешах
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(card)
+}
+
+// writeCardError возвращает отсутствие карты со статусом 404, отказ в показе реквизитов — с 403,
+// превышение числа попыток показа — с 429
+func writeCardError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrCardNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrCardRevealDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrCardRevealRateLimited):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		writeServiceError(w, err)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger()
+	os.Exit(m.Run())
+}
+
+const testPAN = "4000001234567899"
+
+func TestWriteCardHidesFullNumber(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeCard(rec, &models.Card{ID: 1, Number: testPAN, MaskedNumber: "400000******7899", CVV: "123", CVVHash: "$2a$10$hash"})
+
+	body := rec.Body.String()
+	if strings.Contains(body, testPAN) || strings.Contains(body, `"number"`) {
+		t.Fatalf("card response contains full number: %s", body)
+	}
+	if strings.Contains(body, `"cvv"`) || strings.Contains(body, "$2a$") {
+		t.Fatalf("card response contains CVV: %s", body)
+	}
+	if !strings.Contains(body, `"masked_number":"400000******7899"`) {
+		t.Fatalf("card response has no masked number: %s", body)
+	}
+}
+
+func TestWriteCardsHidesFullNumbers(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeCards(rec, []models.Card{
+		{ID: 1, Number: testPAN, MaskedNumber: "400000******7899"},
+		{ID: 2, MaskedNumber: "400000******7881"},
+	})
+
+	body := rec.Body.String()
+	if strings.Contains(body, testPAN) || strings.Contains(body, `"number"`) {
+		t.Fatalf("cards response contains full number: %s", body)
+	}
+	if strings.Count(body, `"masked_number"`) != 2 {
+		t.Fatalf("cards response must contain both cards: %s", body)
+	}
+}
+
+func TestWriteRevealedCard(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeRevealedCard(rec, &models.Card{
+		ID:           1,
+		Number:       testPAN,
+		MaskedNumber: "400000******7899",
+		CVV:          "123",
+		CVVHash:      "$2a$10$hash",
+		CVVEncrypted: "-----BEGIN PGP MESSAGE-----",
+		Expiry:       "12/30",
+	})
+
+	body := rec.Body.String()
+	for _, field := range []string{`"number":"` + testPAN + `"`, `"expiry":"12/30"`, `"cvv":"123"`} {
+		if !strings.Contains(body, field) {
+			t.Errorf("revealed card response has no %s: %s", field, body)
+		}
+	}
+	if strings.Contains(body, "$2a$") || strings.Contains(body, "PGP") {
+		t.Errorf("revealed card response contains stored CVV: %s", body)
+	}
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", rec.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
+)
+
+type CardPaymentHandler struct {
+	service *services.CardPaymentService
+}
+
+func NewCardPaymentHandler(service *services.CardPaymentService) *CardPaymentHandler {
+	return &CardPaymentHandler{service: service}
+}
+
+// Authorize авторизует оплату картой и блокирует сумму на счете карты (только для торговых предприятий)
+func (h *CardPaymentHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	// Получаем userID торгового предприятия из контекста
+	merchantID := r.Context().Value("userID").(uint)
+
+	var request services.CardAuthorizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	authorization, err := h.service.Authorize(merchantID, request)
+	if err != nil {
+		writeCardPaymentError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(authorization)
+}
+
+// Capture списывает авторизованную сумму или ее часть
+func (h *CardPaymentHandler) Capture(w http.ResponseWriter, r *http.Request) {
+	authorizationID, amount, ok := parseCardPaymentRequest(w, r)
+	if !ok {
+		return
+	}
+	merchantID := r.Context().Value("userID").(uint)
+
+	authorization, err := h.service.Capture(merchantID, authorizationID, amount)
+	if err != nil {
+		writeCardPaymentError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(authorization)
+}
+
+// Void отменяет авторизацию без списания
+func (h *CardPaymentHandler) Void(w http.ResponseWriter, r *http.Request) {
+	authorizationID, ok := parseAuthorizationID(w, r)
+	if !ok {
+		return
+	}
+	merchantID := r.Context().Value("userID").(uint)
+
+	authorization, err := h.service.Void(merchantID, authorizationID)
+	if err != nil {
+		writeCardPaymentError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(authorization)
+}
+
+// Refund возвращает списанную сумму или ее часть на счет карты
+func (h *CardPaymentHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	authorizationID, amount, ok := parseCardPaymentRequest(w, r)
+	if !ok {
+		return
+	}
+	merchantID := r.Context().Value("userID").(uint)
+
+	authorization, err := h.service.Refund(merchantID, authorizationID, amount)
+	if err != nil {
+		writeCardPaymentError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(authorization)
+}
+
+// GetAuthorization возвращает авторизацию с историей операций
+func (h *CardPaymentHandler) GetAuthorization(w http.ResponseWriter, r *http.Request) {
+	authorizationID, ok := parseAuthorizationID(w, r)
+	if !ok {
+		return
+	}
+	merchantID := r.Context().Value("userID").(uint)
+
+	authorization, err := h.service.GetAuthorization(merchantID, authorizationID)
+	if err != nil {
+		writeCardPaymentError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(authorization)
+}
+
+func parseAuthorizationID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	vars := mux.Vars(r)
+	authorizationID, err := strconv.ParseUint(vars["authorization_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid authorization ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(authorizationID), true
+}
+
+// parseCardPaymentRequest разбирает ID авторизации и необязательную сумму {"amount": "..."}.
+// Без тела или без суммы операция выполняется на всю доступную сумму
+func parseCardPaymentRequest(w http.ResponseWriter, r *http.Request) (uint, *models.Money, bool) {
+	authorizationID, ok := parseAuthorizationID(w, r)
+	if !ok {
+		return 0, nil, false
+	}
+
+	var request struct {
+		Amount *models.Money `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, nil, false
+	}
+	return authorizationID, request.Amount, true
+}
+
+// writeCardPaymentError возвращает отказ в авторизации со статусом 402, отсутствие авторизации — с 404
+func writeCardPaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrCardDeclined):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	case errors.Is(err, services.ErrCardAuthorizationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		writeServiceError(w, err)
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
+)
+
+type CreditApplicationHandler struct {
+	service *services.CreditApplicationService
+}
+
+func NewCreditApplicationHandler(service *services.CreditApplicationService) *CreditApplicationHandler {
+	return &CreditApplicationHandler{service: service}
+}
+
+func (h *CreditApplicationHandler) SubmitApplication(w http.ResponseWriter, r *http.Request) {
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	var request struct {
+		ProductID    uint         `json:"product_id"`
+		AccountID    uint         `json:"account_id"` // счет для зачисления кредита
+		Amount       models.Money `json:"amount"`
+		Term         int          `json:"term"`
+		ScheduleType string       `json:"schedule_type"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	application, err := h.service.Submit(userID, request.ProductID, request.AccountID, request.Amount, request.Term, request.ScheduleType)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(application)
+}
+
+func (h *CreditApplicationHandler) GetUserApplications(w http.ResponseWriter, r *http.Request) {
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	applications, err := h.service.GetApplications(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(applications)
+}
+
+func (h *CreditApplicationHandler) GetApplication(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	applicationID, err := strconv.ParseUint(vars["application_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid application ID", http.StatusBadRequest)
+		return
+	}
+
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	application, err := h.service.GetApplication(userID, uint(applicationID))
+	if err != nil {
+		writeApplicationError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(application)
+}
+
+func writeApplicationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrApplicationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrApplicationNotApproved):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeServiceError(w, err)
+	}
+}
@@ -18,29 +18,54 @@ func NewCreditHandler(service *services.CreditService) *CreditHandler {
 	return &CreditHandler{service: service}
 }
 
+// CreateCredit выдает кредит по одобренной заявке
 func (h *CreditHandler) CreateCredit(w http.ResponseWriter, r *http.Request) {
 	// Получаем userID из контекста
 	userID := r.Context().Value("userID").(uint)
 
+	// Поля прежнего формата запроса разбираются только для того, чтобы явно отклонить такой запрос,
+	// а не выдать кредит по пустой заявке
 	var request struct {
-		Amount float64 `json:"amount"`
-		Term   int     `json:"term"`
+		ApplicationID uint             `json:"application_id"`
+		ProductID     *json.RawMessage `json:"product_id"`
+		AccountID     *json.RawMessage `json:"account_id"`
+		Amount        *json.RawMessage `json:"amount"`
+		Term          *json.RawMessage `json:"term"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if request.ProductID != nil || request.AccountID != nil || request.Amount != nil || request.Term != nil {
+		http.Error(w, "product_id, account_id, amount and term are no longer accepted: submit a credit application "+
+			"with POST /credit-applications and issue the credit by its application_id", http.StatusBadRequest)
+		return
+	}
+	if request.ApplicationID == 0 {
+		http.Error(w, "application_id is required", http.StatusBadRequest)
+		return
+	}
 
-	credit, err := h.service.CreateCredit(userID, request.Amount, request.Term)
+	credit, err := h.service.IssueCredit(userID, request.ApplicationID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeApplicationError(w, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(credit)
 }
 
+func (h *CreditHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
+	products, err := h.service.GetProducts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(products)
+}
+
 func (h *CreditHandler) GetUserCredits(w http.ResponseWriter, r *http.Request) {
 	// Получаем userID из контекста
 	userID := r.Context().Value("userID").(uint)
@@ -79,3 +104,64 @@ func (h *CreditHandler) GetPaymentSchedule(w http.ResponseWriter, r *http.Reques
 
 	json.NewEncoder(w).Encode(schedule)
 }
+
+func (h *CreditHandler) SetRepaymentAccount(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	creditID, err := strconv.ParseUint(vars["credit_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	// Проверяем, что кредит принадлежит пользователю
+	if !h.service.CreditBelongsToUser(uint(creditID), userID) {
+		http.Error(w, "Credit does not belong to user", http.StatusForbidden)
+		return
+	}
+
+	var request struct {
+		AccountID *uint `json:"account_id"` // null отключает автоматическое списание
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	credit, err := h.service.SetRepaymentAccount(uint(creditID), userID, request.AccountID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(credit)
+}
+
+func (h *CreditHandler) GetCreditBalance(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	creditID, err := strconv.ParseUint(vars["credit_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	// Проверяем, что кредит принадлежит пользователю
+	if !h.service.CreditBelongsToUser(uint(creditID), userID) {
+		http.Error(w, "Credit does not belong to user", http.StatusForbidden)
+		return
+	}
+
+	balance, err := h.service.GetCreditBalance(uint(creditID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(balance)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
+)
+
+type CreditRestructuringHandler struct {
+	service *services.CreditRestructuringService
+}
+
+func NewCreditRestructuringHandler(service *services.CreditRestructuringService) *CreditRestructuringHandler {
+	return &CreditRestructuringHandler{service: service}
+}
+
+// RestructureCredit предоставляет кредитные каникулы или меняет срок и ставку кредита (только для поддержки)
+func (h *CreditRestructuringHandler) RestructureCredit(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	creditID, err := strconv.ParseUint(vars["credit_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	// Получаем userID сотрудника поддержки из контекста
+	operatorID := r.Context().Value("userID").(uint)
+
+	var request services.RestructuringRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	restructuring, err := h.service.RestructureCredit(uint(creditID), operatorID, request)
+	if err != nil {
+		writeRestructuringError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(restructuring)
+}
+
+// GetRestructurings возвращает историю реструктуризаций кредита с архивными графиками (только для поддержки)
+func (h *CreditRestructuringHandler) GetRestructurings(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	creditID, err := strconv.ParseUint(vars["credit_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	restructurings, err := h.service.GetRestructurings(uint(creditID))
+	if err != nil {
+		writeRestructuringError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(restructurings)
+}
+
+// writeRestructuringError возвращает отсутствие кредита со статусом 404
+func writeRestructuringError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrCreditNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeServiceError(w, err)
+}
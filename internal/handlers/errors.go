@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
+)
+
+// writeServiceError возвращает ошибки валидации со статусом 400, остальные — со статусом 500
+func writeServiceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrValidation) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
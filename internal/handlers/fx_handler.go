@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
+)
+
+type FXHandler struct {
+	service *services.FXService
+}
+
+func NewFXHandler(service *services.FXService) *FXHandler {
+	return &FXHandler{service: service}
+}
+
+func (h *FXHandler) CreateQuote(w http.ResponseWriter, r *http.Request) {
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	var request struct {
+		FromAccount uint         `json:"from_account"`
+		ToAccount   uint         `json:"to_account"`
+		Amount      models.Money `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	quote, err := h.service.CreateQuote(userID, request.FromAccount, request.ToAccount, request.Amount)
+	if err != nil {
+		writeFXError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quote)
+}
+
+func (h *FXHandler) GetQuote(w http.ResponseWriter, r *http.Request) {
+	quoteID, ok := parseQuoteID(w, r)
+	if !ok {
+		return
+	}
+	userID := r.Context().Value("userID").(uint)
+
+	quote, err := h.service.GetQuote(userID, quoteID)
+	if err != nil {
+		writeFXError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(quote)
+}
+
+func (h *FXHandler) ExecuteQuote(w http.ResponseWriter, r *http.Request) {
+	quoteID, ok := parseQuoteID(w, r)
+	if !ok {
+		return
+	}
+	userID := r.Context().Value("userID").(uint)
+
+	quote, err := h.service.ExecuteQuote(userID, quoteID)
+	if err != nil {
+		writeFXError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(quote)
+}
+
+func parseQuoteID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	quoteID, err := strconv.ParseUint(mux.Vars(r)["quote_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid quote ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(quoteID), true
+}
+
+// writeFXError переводит ошибки котировок в HTTP-статусы
+func writeFXError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrFXQuoteNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrFXQuoteExpired):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, services.ErrFXQuoteExecuted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeServiceError(w, err)
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
+)
+
+func TestWriteFXError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: services.ErrFXQuoteNotFound, want: http.StatusNotFound},
+		{err: services.ErrFXQuoteExpired, want: http.StatusGone},
+		{err: services.ErrFXQuoteExecuted, want: http.StatusConflict},
+		{err: fmt.Errorf("%w: insufficient funds", services.ErrValidation), want: http.StatusBadRequest},
+		{err: errors.New("failed to get exchange rate: timeout"), want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeFXError(rec, tt.err)
+			if rec.Code != tt.want {
+				t.Fatalf("writeFXError(%v) status = %d, want %d", tt.err, rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
+)
+
+type HoldHandler struct {
+	service *services.HoldService
+}
+
+func NewHoldHandler(service *services.HoldService) *HoldHandler {
+	return &HoldHandler{service: service}
+}
+
+// CreateHold блокирует часть остатка счета
+func (h *HoldHandler) CreateHold(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := h.ownedAccountID(w, r)
+	if !ok {
+		return
+	}
+
+	var request services.HoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hold, err := h.service.CreateHold(accountID, request)
+	if err != nil {
+		writeHoldError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hold)
+}
+
+// GetAccountHolds возвращает блокировки счета
+func (h *HoldHandler) GetAccountHolds(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := h.ownedAccountID(w, r)
+	if !ok {
+		return
+	}
+
+	holds, err := h.service.GetAccountHolds(accountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(holds)
+}
+
+// ReleaseHold снимает блокировку досрочно
+func (h *HoldHandler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := h.ownedAccountID(w, r)
+	if !ok {
+		return
+	}
+	holdID, err := strconv.ParseUint(mux.Vars(r)["hold_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid hold ID", http.StatusBadRequest)
+		return
+	}
+
+	hold, err := h.service.ReleaseHold(accountID, uint(holdID))
+	if err != nil {
+		writeHoldError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(hold)
+}
+
+// ownedAccountID разбирает account_id из пути и проверяет, что счет принадлежит пользователю
+func (h *HoldHandler) ownedAccountID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	vars := mux.Vars(r)
+	accountID, err := strconv.ParseUint(vars["account_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	// Проверяем, что счет принадлежит пользователю
+	if !h.service.AccountBelongsToUser(uint(accountID), userID) {
+		http.Error(w, "Account does not belong to user", http.StatusForbidden)
+		return 0, false
+	}
+	return uint(accountID), true
+}
+
+// writeHoldError возвращает отсутствие блокировки со статусом 404
+func writeHoldError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrHoldNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeServiceError(w, err)
+}
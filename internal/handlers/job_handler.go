@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
+)
+
+type JobHandler struct {
+	service *services.SchedulerService
+}
+
+func NewJobHandler(service *services.SchedulerService) *JobHandler {
+	return &JobHandler{service: service}
+}
+
+// GetJobs возвращает задачи планировщика с расписанием и последним запуском (только для поддержки)
+func (h *JobHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.service.GetJobs()
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// GetJobRuns возвращает историю запусков задачи (только для поддержки)
+func (h *JobHandler) GetJobRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.service.GetJobRuns(mux.Vars(r)["name"])
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(runs)
+}
+
+// RunJob запускает задачу вручную и сразу возвращает запись запуска (только для поддержки)
+func (h *JobHandler) RunJob(w http.ResponseWriter, r *http.Request) {
+	run, err := h.service.RunJob(mux.Vars(r)["name"])
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// writeJobError возвращает неизвестную задачу со статусом 404, уже выполняющуюся — с 409
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrJobRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeServiceError(w, err)
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
+)
+
+type LedgerHandler struct {
+	service *services.LedgerService
+}
+
+func NewLedgerHandler(service *services.LedgerService) *LedgerHandler {
+	return &LedgerHandler{service: service}
+}
+
+func (h *LedgerHandler) GetAccountHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	accountID, err := strconv.ParseUint(vars["account_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	// Проверяем, что счет принадлежит пользователю
+	if !h.service.AccountBelongsToUser(uint(accountID), userID) {
+		http.Error(w, "Account does not belong to user", http.StatusForbidden)
+		return
+	}
+
+	history, err := h.service.GetAccountHistory(uint(accountID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(history)
+}
@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
 )
 
@@ -29,7 +33,8 @@ func (h *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(uint)
 
 	var request struct {
-		Amount float64 `json:"amount"`
+		Amount         models.Money `json:"amount"`
+		EarlyRepayment string       `json:"early_repayment"` // "reduce_term" или "reduce_payment"
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -43,9 +48,9 @@ func (h *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	payment, err := h.service.CreatePayment(uint(creditID), request.Amount)
+	payment, err := h.service.CreatePayment(uint(creditID), request.Amount, request.EarlyRepayment)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
@@ -78,6 +83,101 @@ func (h *PaymentHandler) GetCreditPayments(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(payments)
 }
 
+func (h *PaymentHandler) GetCreditPenalties(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	creditID, err := strconv.ParseUint(vars["credit_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	// Проверяем, что кредит принадлежит пользователю
+	if !h.service.CreditBelongsToUser(uint(creditID), userID) {
+		http.Error(w, "Credit does not belong to user", http.StatusForbidden)
+		return
+	}
+
+	penalties, err := h.service.GetPenaltiesByCreditID(uint(creditID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(penalties)
+}
+
+// GetPayoffQuote возвращает сумму полного погашения кредита на дату date (YYYY-MM-DD, по умолчанию сегодня)
+func (h *PaymentHandler) GetPayoffQuote(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	creditID, err := strconv.ParseUint(vars["credit_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	var date *time.Time
+	if value := r.URL.Query().Get("date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		date = &parsed
+	}
+
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	// Проверяем, что кредит принадлежит пользователю
+	if !h.service.CreditBelongsToUser(uint(creditID), userID) {
+		http.Error(w, "Credit does not belong to user", http.StatusForbidden)
+		return
+	}
+
+	quote, err := h.service.GetPayoffQuote(uint(creditID), date)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(quote)
+}
+
+// GetClosureCertificate отдает справку о полном погашении кредита файлом с открытой PGP-подписью
+func (h *PaymentHandler) GetClosureCertificate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	creditID, err := strconv.ParseUint(vars["credit_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid credit ID", http.StatusBadRequest)
+		return
+	}
+
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	// Проверяем, что кредит принадлежит пользователю
+	if !h.service.CreditBelongsToUser(uint(creditID), userID) {
+		http.Error(w, "Credit does not belong to user", http.StatusForbidden)
+		return
+	}
+
+	certificate, err := h.service.GetClosureCertificate(uint(creditID))
+	if errors.Is(err, services.ErrCertificateNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="closure-certificate-%s.txt"`, certificate.Number))
+	w.Write([]byte(certificate.Document))
+}
+
 func (h *PaymentHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	paymentID, err := strconv.ParseUint(vars["payment_id"], 10, 32)
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
+)
+
+// defaultRatePeriod — период истории по умолчанию, если from не указан
+const defaultRatePeriod = 30 * 24 * time.Hour
+
+type RateHandler struct {
+	service *services.CBRService
+}
+
+func NewRateHandler(service *services.CBRService) *RateHandler {
+	return &RateHandler{service: service}
+}
+
+func (h *RateHandler) GetKeyRateHistory(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := parseRatePeriod(w, r)
+	if !ok {
+		return
+	}
+
+	rates, err := h.service.GetKeyRateHistory(from, to)
+	if err != nil {
+		writeRateError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(rates)
+}
+
+func (h *RateHandler) GetCurrencyRateHistory(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "Currency code is required", http.StatusBadRequest)
+		return
+	}
+	from, to, ok := parseRatePeriod(w, r)
+	if !ok {
+		return
+	}
+
+	rates, err := h.service.GetCurrencyRateHistory(code, from, to)
+	if err != nil {
+		writeRateError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(rates)
+}
+
+// parseRatePeriod читает параметры from и to в формате YYYY-MM-DD.
+// По умолчанию возвращается история за последние 30 дней
+func parseRatePeriod(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	to := time.Now()
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed
+	}
+
+	from := to.Add(-defaultRatePeriod)
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+	return from, to, true
+}
+
+func writeRateError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrInvalidRatePeriod) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
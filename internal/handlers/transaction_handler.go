@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
 )
 
@@ -29,10 +30,10 @@ func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 	userID := r.Context().Value("userID").(uint)
 
 	var request struct {
-		Amount      float64 `json:"amount"`
-		Type        string  `json:"type"` // "income" или "expense"
-		Category    string  `json:"category"`
-		Description string  `json:"description"`
+		Amount      models.Money `json:"amount"`
+		Type        string       `json:"type"` // "income" или "expense"
+		Category    string       `json:"category"`
+		Description string       `json:"description"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -48,7 +49,7 @@ func (h *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 
 	transaction, err := h.service.CreateTransaction(uint(accountID), request.Amount, request.Type, request.Category, request.Description)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
@@ -116,10 +117,10 @@ func (h *TransactionHandler) UpdateTransaction(w http.ResponseWriter, r *http.Re
 	}
 
 	var request struct {
-		Amount      float64 `json:"amount"`
-		Type        string  `json:"type"` // "income" или "expense"
-		Category    string  `json:"category"`
-		Description string  `json:"description"`
+		Amount      models.Money `json:"amount"`
+		Type        string       `json:"type"` // "income" или "expense"
+		Category    string       `json:"category"`
+		Description string       `json:"description"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -150,7 +151,7 @@ func (h *TransactionHandler) UpdateTransaction(w http.ResponseWriter, r *http.Re
 	transaction.Description = request.Description
 
 	if err := h.service.UpdateTransaction(transaction); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
@@ -182,7 +183,7 @@ func (h *TransactionHandler) DeleteTransaction(w http.ResponseWriter, r *http.Re
 	}
 
 	if err := h.service.DeleteTransaction(uint(transactionID)); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
 )
@@ -36,9 +37,9 @@ func (h *TransferHandler) CreateTransfer(w http.ResponseWriter, r *http.Request)
     }).Infof("Creating transfer from account %v by user %v", fromAccountID, userID)    
     
     var request struct {
-        ToAccount   uint    `json:"to_account"`
-        Amount      float64 `json:"amount"`
-        Description string  `json:"description"`
+        ToAccount   uint         `json:"to_account"`
+        Amount      models.Money `json:"amount"`
+        Description string       `json:"description"`
     }
 
     if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -50,7 +51,7 @@ func (h *TransferHandler) CreateTransfer(w http.ResponseWriter, r *http.Request)
         "ToAccount": request.ToAccount,
         "Amount": request.Amount,
         "Description": request.Description,
-    }).Infof("Transfer request: to_account=%d, amount=%.2f, description=%s",
+    }).Infof("Transfer request: to_account=%d, amount=%s, description=%s",
     request.ToAccount, request.Amount, request.Description)  
 
 
@@ -66,7 +67,7 @@ func (h *TransferHandler) CreateTransfer(w http.ResponseWriter, r *http.Request)
 
     transfer, err := h.service.CreateTransfer(uint(fromAccountID), request.ToAccount, request.Amount, request.Description)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeServiceError(w, err)
         return
     }
 
@@ -57,6 +57,43 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// paymentReminderSettings — настройка напоминаний о платежах по кредиту
+type paymentReminderSettings struct {
+	PaymentReminderDays *int `json:"payment_reminder_days"` // 0 — не напоминать, null — значение по умолчанию
+	Default             bool `json:"default,omitempty"`
+}
+
+func (h *UserHandler) GetPaymentReminderSettings(w http.ResponseWriter, r *http.Request) {
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	days, custom, err := h.service.GetPaymentReminderDays(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(paymentReminderSettings{PaymentReminderDays: &days, Default: !custom})
+}
+
+func (h *UserHandler) SetPaymentReminderSettings(w http.ResponseWriter, r *http.Request) {
+	// Получаем userID из контекста
+	userID := r.Context().Value("userID").(uint)
+
+	var request paymentReminderSettings
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SetPaymentReminderDays(userID, request.PaymentReminderDays); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	h.GetPaymentReminderSettings(w, r)
+}
+
 // Генерация JWT токена
 func GenerateJWTToken(userID uint) (string, error) {
 	claims := jwt.RegisteredClaims{
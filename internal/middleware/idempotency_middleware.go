@@ -0,0 +1,242 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+// Срок, в течение которого повтор запроса с тем же ключом возвращает сохраненный ответ
+const idempotencyKeyTTL = 24 * time.Hour
+
+// Срок аренды ключа по умолчанию (IDEMPOTENCY_LEASE). Если процесс упал, не сохранив ответ,
+// повтор запроса сможет занять ключ после истечения аренды, а не через idempotencyKeyTTL
+const defaultIdempotencyLease = time.Minute
+
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyStore — хранилище ключей идемпотентности, реализуется repositories.IdempotencyRepository
+type IdempotencyStore interface {
+	CreateKey(key *models.IdempotencyKey) (bool, error)
+	GetKey(userID uint, key string) (*models.IdempotencyKey, error)
+	ReclaimKey(keyID uint, lockedUntil, now time.Time) (bool, error)
+	SaveResponse(keyID uint, statusCode int, contentType string, body []byte) error
+	DeleteKey(keyID uint) error
+}
+
+// IdempotencyMiddleware обрабатывает заголовок Idempotency-Key для запросов, изменяющих деньги.
+// Первый запрос с ключом выполняется и его ответ сохраняется; повтор с тем же телом получает
+// сохраненный ответ, а повтор с другим телом отклоняется. Запросы без заголовка выполняются как обычно.
+// Должен подключаться после AuthMiddleware: ключи хранятся в разрезе пользователя
+func IdempotencyMiddleware(repo IdempotencyStore) func(http.Handler) http.Handler {
+	return IdempotencyMiddlewareWithFingerprint(repo, canonicalJSON)
+}
+
+// RequestFingerprint возвращает данные тела запроса, по которым повтор сравнивается с первым запросом.
+// Их SHA-256 хранится в idempotency_keys, поэтому для запросов с секретами (номер карты, CVV)
+// функция должна оставлять только несекретные поля
+type RequestFingerprint func(body []byte) []byte
+
+// IdempotencyMiddlewareWithFingerprint — IdempotencyMiddleware, сравнивающий запросы по отпечатку fingerprint
+func IdempotencyMiddlewareWithFingerprint(repo IdempotencyStore, fingerprint RequestFingerprint) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			userID, ok := r.Context().Value("userID").(uint)
+			if !ok {
+				http.Error(w, "Invalid user ID", http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			record := &models.IdempotencyKey{
+				UserID:      userID,
+				Key:         key,
+				Method:      r.Method,
+				Path:        r.URL.Path,
+				RequestHash: requestHash(r.Method, r.URL.Path, fingerprint(body)),
+				LockedUntil: now.Add(idempotencyLease()),
+				CreatedAt:   now,
+			}
+
+			created, err := reserveIdempotencyKey(repo, record)
+			if err != nil {
+				utils.Log.WithError(err).Error("Failed to reserve idempotency key")
+				http.Error(w, "Failed to process Idempotency-Key", http.StatusInternalServerError)
+				return
+			}
+			if !created {
+				replayIdempotentResponse(repo, w, record)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				// При панике освобождаем ключ, чтобы клиент мог повторить запрос
+				if p := recover(); p != nil {
+					repo.DeleteKey(record.ID)
+					panic(p)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+
+			// Ответы с ошибкой сервера не сохраняем: операция не выполнена, повтор допустим
+			if recorder.status >= http.StatusInternalServerError {
+				if err := repo.DeleteKey(record.ID); err != nil {
+					utils.Log.WithError(err).Error("Failed to release idempotency key")
+				}
+				return
+			}
+			if err := repo.SaveResponse(record.ID, recorder.status, recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+				utils.Log.WithFields(logrus.Fields{
+					"error":          err.Error(),
+					"idempotencyKey": key,
+				}).Error("Failed to save idempotent response")
+			}
+		})
+	}
+}
+
+// reserveIdempotencyKey создает ключ; просроченный ключ с тем же значением удаляется и создается заново.
+// Ключ того же запроса без сохраненного ответа и с истекшей арендой занимается повторно
+func reserveIdempotencyKey(repo IdempotencyStore, record *models.IdempotencyKey) (bool, error) {
+	created, err := repo.CreateKey(record)
+	if err != nil || created {
+		return created, err
+	}
+
+	existing, err := repo.GetKey(record.UserID, record.Key)
+	if err != nil {
+		return false, err
+	}
+	if record.CreatedAt.Sub(existing.CreatedAt) >= idempotencyKeyTTL {
+		if err := repo.DeleteKey(existing.ID); err != nil {
+			return false, err
+		}
+		return repo.CreateKey(record)
+	}
+
+	// Процесс, занявший ключ, не сохранил ответ до истечения аренды — скорее всего, он упал.
+	// Повтор с другим телом ключ не получает и будет отклонен с 422
+	if existing.StatusCode == 0 && record.CreatedAt.After(existing.LockedUntil) && sameIdempotentRequest(existing, record) {
+		reclaimed, err := repo.ReclaimKey(existing.ID, record.LockedUntil, record.CreatedAt)
+		if err != nil || !reclaimed {
+			return false, err
+		}
+		record.ID = existing.ID
+		return true, nil
+	}
+	return false, nil
+}
+
+// sameIdempotentRequest сообщает, совпадает ли повтор с запросом, для которого создан ключ
+func sameIdempotentRequest(existing, record *models.IdempotencyKey) bool {
+	return existing.Method == record.Method && existing.Path == record.Path && existing.RequestHash == record.RequestHash
+}
+
+// idempotencyLease возвращает срок аренды ключа из IDEMPOTENCY_LEASE. Он должен превышать
+// время обработки самого долгого запроса, иначе повтор займет ключ до завершения исходного запроса
+func idempotencyLease() time.Duration {
+	lease, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_LEASE"))
+	if err != nil || lease <= 0 {
+		return defaultIdempotencyLease
+	}
+	return lease
+}
+
+func replayIdempotentResponse(repo IdempotencyStore, w http.ResponseWriter, record *models.IdempotencyKey) {
+	existing, err := repo.GetKey(record.UserID, record.Key)
+	if err != nil {
+		http.Error(w, "Failed to process Idempotency-Key", http.StatusInternalServerError)
+		return
+	}
+
+	if !sameIdempotentRequest(existing, record) {
+		http.Error(w, "Idempotency-Key has already been used with a different request", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if existing.StatusCode == 0 {
+		http.Error(w, "Request with this Idempotency-Key is still being processed", http.StatusConflict)
+		return
+	}
+
+	if existing.ContentType != "" {
+		w.Header().Set("Content-Type", existing.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(existing.StatusCode)
+	w.Write(existing.ResponseBody)
+}
+
+// canonicalJSON — отпечаток по умолчанию: JSON-тело приводится к каноническому виду,
+// чтобы порядок полей и пробелы не влияли на сравнение
+func canonicalJSON(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err == nil {
+		if encoded, err := json.Marshal(parsed); err == nil {
+			return encoded
+		}
+	}
+	return body
+}
+
+// requestHash вычисляет хеш запроса по методу, пути и отпечатку тела
+func requestHash(method, path string, fingerprint []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(fingerprint)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder передает ответ клиенту и одновременно сохраняет его копию
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger()
+	os.Exit(m.Run())
+}
+
+// memoryIdempotencyStore — IdempotencyStore в памяти с той же семантикой, что и таблица idempotency_keys
+type memoryIdempotencyStore struct {
+	mu     sync.Mutex
+	nextID uint
+	keys   map[string]*models.IdempotencyKey
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{keys: make(map[string]*models.IdempotencyKey)}
+}
+
+func storeKey(userID uint, key string) string {
+	return fmt.Sprintf("%d/%s", userID, key)
+}
+
+func (s *memoryIdempotencyStore) CreateKey(key *models.IdempotencyKey) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[storeKey(key.UserID, key.Key)]; ok {
+		return false, nil
+	}
+	s.nextID++
+	key.ID = s.nextID
+	stored := *key
+	s.keys[storeKey(key.UserID, key.Key)] = &stored
+	return true, nil
+}
+
+func (s *memoryIdempotencyStore) GetKey(userID uint, key string) (*models.IdempotencyKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.keys[storeKey(userID, key)]
+	if !ok {
+		return nil, fmt.Errorf("idempotency key not found")
+	}
+	record := *stored
+	return &record, nil
+}
+
+func (s *memoryIdempotencyStore) ReclaimKey(keyID uint, lockedUntil, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stored := range s.keys {
+		if stored.ID == keyID && stored.StatusCode == 0 && stored.LockedUntil.Before(now) {
+			stored.LockedUntil = lockedUntil
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryIdempotencyStore) SaveResponse(keyID uint, statusCode int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stored := range s.keys {
+		if stored.ID == keyID {
+			stored.StatusCode = statusCode
+			stored.ContentType = contentType
+			stored.ResponseBody = body
+		}
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) DeleteKey(keyID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, stored := range s.keys {
+		if stored.ID == keyID {
+			delete(s.keys, k)
+		}
+	}
+	return nil
+}
+
+// update изменяет сохраненный ключ, имитируя состояние после сбоя или истечения срока
+func (s *memoryIdempotencyStore) update(userID uint, key string, fn func(*models.IdempotencyKey)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.keys[storeKey(userID, key)])
+}
+
+// countingHandler считает вызовы и отвечает status с телом, содержащим номер вызова
+type countingHandler struct {
+	calls  int
+	status int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(h.status)
+	fmt.Fprintf(w, `{"call":%d}`, h.calls)
+}
+
+func doIdempotentRequest(handler http.Handler, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/accounts/1/transactions", strings.NewReader(body))
+	r.Header.Set("Idempotency-Key", key)
+	r = r.WithContext(context.WithValue(r.Context(), "userID", uint(1)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestIdempotencyReplaysSavedResponse(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	next := &countingHandler{status: http.StatusCreated}
+	handler := IdempotencyMiddleware(store)(next)
+
+	first := doIdempotentRequest(handler, "k1", `{"amount":"10.00","type":"income"}`)
+	// Порядок полей и пробелы не влияют на сравнение запросов
+	replay := doIdempotentRequest(handler, "k1", `{ "type": "income", "amount": "10.00" }`)
+
+	if next.calls != 1 {
+		t.Fatalf("handler calls = %d, want 1", next.calls)
+	}
+	if replay.Code != first.Code || replay.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %s, want %d %s", replay.Code, replay.Body, first.Code, first.Body)
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" || replay.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("replay headers = %v", replay.Header())
+	}
+}
+
+func TestIdempotencyRejectsDifferentRequest(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	next := &countingHandler{status: http.StatusOK}
+	handler := IdempotencyMiddleware(store)(next)
+
+	doIdempotentRequest(handler, "k1", `{"amount":"10.00"}`)
+	rec := doIdempotentRequest(handler, "k1", `{"amount":"20.00"}`)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rec.Code)
+	}
+	if next.calls != 1 {
+		t.Fatalf("handler calls = %d, want 1", next.calls)
+	}
+}
+
+func TestIdempotencyInProgressKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		lockedUntil time.Duration
+		body        string
+		wantStatus  int
+		wantCalls   int
+	}{
+		// Исходный запрос еще обрабатывается: аренда не истекла
+		{name: "active lease", lockedUntil: time.Minute, body: `{"amount":"10.00"}`, wantStatus: http.StatusConflict, wantCalls: 0},
+		// Процесс упал, не сохранив ответ: повтор занимает ключ и выполняет запрос
+		{name: "expired lease", lockedUntil: -time.Second, body: `{"amount":"10.00"}`, wantStatus: http.StatusOK, wantCalls: 1},
+		// Истекшая аренда не позволяет выполнить по ключу другой запрос
+		{name: "expired lease, different body", lockedUntil: -time.Second, body: `{"amount":"20.00"}`, wantStatus: http.StatusUnprocessableEntity, wantCalls: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryIdempotencyStore()
+			now := time.Now()
+			store.CreateKey(&models.IdempotencyKey{
+				UserID:      1,
+				Key:         "k1",
+				Method:      http.MethodPost,
+				Path:        "/accounts/1/transactions",
+				RequestHash: requestHash(http.MethodPost, "/accounts/1/transactions", canonicalJSON([]byte(`{"amount":"10.00"}`))),
+				LockedUntil: now.Add(tt.lockedUntil),
+				CreatedAt:   now.Add(-time.Minute),
+			})
+			next := &countingHandler{status: http.StatusOK}
+			handler := IdempotencyMiddleware(store)(next)
+
+			rec := doIdempotentRequest(handler, "k1", tt.body)
+			if rec.Code != tt.wantStatus || next.calls != tt.wantCalls {
+				t.Fatalf("status = %d, calls = %d; want %d, %d", rec.Code, next.calls, tt.wantStatus, tt.wantCalls)
+			}
+			if tt.wantCalls == 0 {
+				return
+			}
+
+			// Ответ занявшего ключ запроса сохраняется и выдается при следующем повторе
+			replay := doIdempotentRequest(handler, "k1", tt.body)
+			if next.calls != 1 || replay.Header().Get("Idempotent-Replayed") != "true" {
+				t.Fatalf("replay status = %d, calls = %d; want saved response", replay.Code, next.calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	next := &countingHandler{status: http.StatusInternalServerError}
+	handler := IdempotencyMiddleware(store)(next)
+
+	doIdempotentRequest(handler, "k1", `{"amount":"10.00"}`)
+	next.status = http.StatusOK
+	rec := doIdempotentRequest(handler, "k1", `{"amount":"10.00"}`)
+
+	if rec.Code != http.StatusOK || next.calls != 2 {
+		t.Fatalf("retry after 5xx: status = %d, calls = %d; want 200, 2", rec.Code, next.calls)
+	}
+}
+
+func TestIdempotencyExpiredKeyIsRecreated(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	next := &countingHandler{status: http.StatusOK}
+	handler := IdempotencyMiddleware(store)(next)
+
+	doIdempotentRequest(handler, "k1", `{"amount":"10.00"}`)
+	store.update(1, "k1", func(key *models.IdempotencyKey) {
+		key.CreatedAt = key.CreatedAt.Add(-idempotencyKeyTTL)
+	})
+	// После истечения срока ключ можно использовать для нового запроса
+	rec := doIdempotentRequest(handler, "k1", `{"amount":"20.00"}`)
+
+	if rec.Code != http.StatusOK || next.calls != 2 || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("request with expired key: status = %d, calls = %d", rec.Code, next.calls)
+	}
+}
+
+func TestIdempotencyLeaseFromEnv(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_LEASE", "30s")
+	if got := idempotencyLease(); got != 30*time.Second {
+		t.Errorf("idempotencyLease() = %v, want 30s", got)
+	}
+	t.Setenv("IDEMPOTENCY_LEASE", "garbage")
+	if got := idempotencyLease(); got != defaultIdempotencyLease {
+		t.Errorf("idempotencyLease() with invalid value = %v, want %v", got, defaultIdempotencyLease)
+	}
+}
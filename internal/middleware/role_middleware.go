@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+)
+
+// RequireRole пропускает только пользователей с ролью role. Должен подключаться после AuthMiddleware:
+// роль читается из БД по userID из контекста, поэтому ее изменение действует без перевыпуска токена
+func RequireRole(userRepo *repositories.UserRepository, role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value("userID").(uint)
+			if !ok {
+				http.Error(w, "Authorization required", http.StatusUnauthorized)
+				return
+			}
+
+			user, err := userRepo.GetUserByID(userID)
+			if err != nil || user.Role != role {
+				http.Error(w, "Access denied", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
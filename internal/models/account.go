@@ -3,9 +3,10 @@ package models
 import "time"
 
 type Account struct {
-	ID        uint      `json:"id"`
-	UserID    uint      `json:"user_id"`
-	Balance   float64   `json:"balance"`
-	Currency  string    `json:"currency"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               uint      `json:"id"`
+	UserID           uint      `json:"user_id"`
+	Balance          Money     `json:"balance"`           // учетный остаток
+	AvailableBalance Money     `json:"available_balance"` // остаток за вычетом заблокированных средств
+	Currency         string    `json:"currency"`
+	CreatedAt        time.Time `json:"created_at"`
 }
\ No newline at end of file
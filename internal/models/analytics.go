@@ -3,16 +3,18 @@ package models
 import "time"
 
 type IncomeExpenseStats struct {
-	Income  float64 `json:"income"`
-	Expense float64 `json:"expense"`
+	Income  Money `json:"income"`
+	Expense Money `json:"expense"`
 }
 
+// BalanceForecast — прогноз остатка на дату в одной валюте: по каждой валюте счетов пользователя своя запись
 type BalanceForecast struct {
-	Date    time.Time `json:"date"`
-	Balance float64  `json:"balance"`
+	Date     time.Time `json:"date"`
+	Currency string    `json:"currency"`
+	Balance  Money     `json:"balance"`
 }
 
 type CreditLoad struct {
-	TotalDebt float64 `json:"total_debt"`
-	MonthlyPayment float64 `json:"monthly_payment"`
-}
\ No newline at end of file
+	TotalDebt      Money `json:"total_debt"`
+	MonthlyPayment Money `json:"monthly_payment"`
+}
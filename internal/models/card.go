@@ -2,12 +2,65 @@ package models
 
 import "time"
 
+// Статусы карты
+const (
+	CardActive  = "active"
+	CardBlocked = "blocked" // временно заблокирована владельцем, может быть разблокирована
+	CardLost    = "lost"    // утеряна или украдена, может быть только перевыпущена
+	CardExpired = "expired" // истек срок действия
+	CardClosed  = "closed"  // закрыта или заменена перевыпущенной картой
+)
+
 type Card struct {
+	ID              uint       `json:"id"`
+	AccountID       uint       `json:"account_id"`
+	Number          string     `json:"number,omitempty"` // полный номер возвращается только при показе реквизитов
+	MaskedNumber    string     `json:"masked_number"`    // первые 6 и последние 4 цифры номера
+	CVV             string     `json:"cvv,omitempty"` // CVV возвращается только при показе реквизитов
+	CVVHash         string     `json:"-"`              // bcrypt-хеш CVV для проверки при оплате
+	CVVEncrypted    string     `json:"-"`              // CVV, зашифрованный PGP, для показа реквизитов
+	Expiry          string     `json:"expiry"`
+	Status          string     `json:"status"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	ReissuedFrom    *uint      `json:"reissued_from,omitempty"` // карта, взамен которой выпущена эта
+	CreatedAt       time.Time  `json:"created_at"`
+	HMAC            string     `json:"-"` // Не возвращаем HMAC в ответе
+	PANHash         string     `json:"-"` // HMAC номера карты для поиска по номеру без расшифровки
+}
+
+// Способы подтверждения показа полных реквизитов карты
+const (
+	CardRevealByPassword = "password"
+	CardRevealByOTP      = "otp"
+	CardRevealOTPRequest = "otp_request" // запрос одноразового кода
+)
+
+// Результаты попыток показа реквизитов карты
+const (
+	CardRevealSuccess     = "success"
+	CardRevealDenied      = "denied" // неверный пароль или одноразовый код
+	CardRevealRateLimited = "rate_limited"
+	CardRevealOTPSent     = "otp_sent"
+)
+
+// CardRevealEvent — запись журнала показа полных реквизитов карты
+type CardRevealEvent struct {
 	ID        uint      `json:"id"`
-	AccountID uint      `json:"account_id"`
-	Number    string    `json:"number"`
-	CVV       string    `json:"-"` // Не возвращаем CVV в ответе
-	Expiry    string    `json:"expiry"`
+	CardID    uint      `json:"card_id"`
+	UserID    uint      `json:"user_id"`
+	Method    string    `json:"method"`
+	Result    string    `json:"result"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
 	CreatedAt time.Time `json:"created_at"`
-	HMAC      string    `json:"-"` // Не возвращаем HMAC в ответе
-}
\ No newline at end of file
+}
+
+// CardRevealOTP — одноразовый код для показа реквизитов карты, хранится только HMAC кода
+type CardRevealOTP struct {
+	ID        uint
+	CardID    uint
+	UserID    uint
+	CodeHash  string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// CardLimits — лимиты расходов по карте и ограничения по кодам категорий торговых предприятий (MCC).
+// Лимиты указываются в валюте счета карты, nil — без ограничения
+type CardLimits struct {
+	CardID         uint       `json:"card_id"`
+	PerTransaction *Money     `json:"per_transaction_limit"`
+	Daily          *Money     `json:"daily_limit"`   // расходы за календарный день
+	Monthly        *Money     `json:"monthly_limit"` // расходы за календарный месяц
+	AllowedMCCs    []string   `json:"allowed_mccs"`  // если список не пуст, оплата разрешена только в этих категориях
+	BlockedMCCs    []string   `json:"blocked_mccs"`
+	Currency       string     `json:"currency"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+}
+
+// CardDecline — отказ в оплате картой с причиной отказа
+type CardDecline struct {
+	ID         uint      `json:"id"`
+	CardID     uint      `json:"card_id"`
+	MerchantID uint      `json:"merchant_id"`
+	Amount     Money     `json:"amount"`
+	MCC        string    `json:"mcc,omitempty"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Статусы авторизации по карте
+const (
+	CardAuthAuthorized = "authorized" // средства заблокированы, ожидается списание
+	CardAuthCaptured   = "captured"   // списание выполнено полностью или частично
+	CardAuthRefunded   = "refunded"   // списанная сумма полностью возвращена
+	CardAuthVoided     = "voided"     // авторизация отменена торговым предприятием
+	CardAuthExpired    = "expired"    // авторизация истекла без списания
+)
+
+// Операции по авторизации
+const (
+	CardOperationAuthorize = "authorize"
+	CardOperationCapture   = "capture"
+	CardOperationVoid      = "void"
+	CardOperationRefund    = "refund"
+	CardOperationExpire    = "expire"
+)
+
+// CardAuthorization — авторизация оплаты картой у торгового предприятия: на время авторизации
+// сумма блокируется на счете карты, а списывается при подтверждении (capture)
+type CardAuthorization struct {
+	ID          uint                   `json:"id"`
+	CardID      uint                   `json:"card_id"`
+	AccountID   uint                   `json:"account_id"`
+	MerchantID  uint                   `json:"merchant_id"`
+	HoldID      uint                   `json:"hold_id"`
+	Amount      Money                  `json:"amount"`
+	Captured    Money                  `json:"captured_amount"`
+	Refunded    Money                  `json:"refunded_amount"`
+	Currency    string                 `json:"currency"`
+	Description string                 `json:"description"`
+	MCC         string                 `json:"mcc,omitempty"` // код категории торгового предприятия
+	Status      string                 `json:"status"`
+	ExpiresAt   time.Time              `json:"expires_at"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	Operations  []CardPaymentOperation `json:"operations,omitempty"`
+}
+
+// CardPaymentOperation — операция по авторизации: авторизация, списание, отмена, возврат или истечение
+type CardPaymentOperation struct {
+	ID              uint      `json:"id"`
+	AuthorizationID uint      `json:"authorization_id"`
+	Type            string    `json:"type"`
+	Amount          Money     `json:"amount"`
+	CreatedAt       time.Time `json:"created_at"`
+}
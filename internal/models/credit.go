@@ -2,20 +2,87 @@ package models
 
 import "time"
 
+// Типы графика платежей
+const (
+	ScheduleAnnuity        = "annuity"        // равные ежемесячные платежи
+	ScheduleDifferentiated = "differentiated" // равные доли основного долга, проценты на остаток
+)
+
+// Статусы кредита
+const (
+	CreditActive = "active"
+	CreditClosed = "closed"
+)
+
 type Credit struct {
-	ID           uint      `json:"id"`
-	UserID       uint      `json:"user_id"`
-	Amount       float64   `json:"amount"`
-	InterestRate float64   `json:"interest_rate"`
-	Term         int       `json:"term"` // в месяцах
-	CreatedAt    time.Time `json:"created_at"`
+	ID                 uint          `json:"id"`
+	UserID             uint          `json:"user_id"`
+	ProductID          *uint         `json:"product_id,omitempty"`
+	Amount             Money         `json:"amount"` // общая задолженность, сумма Outstanding
+	InterestRate       float64       `json:"interest_rate"`
+	Term               int           `json:"term"` // в месяцах
+	ScheduleType       string        `json:"schedule_type"`
+	Status             string        `json:"status"`
+	Fee                Money         `json:"fee"`                            // комиссия за выдачу, удерживается из выдаваемой суммы
+	AccountID          *uint         `json:"account_id,omitempty"`           // счет, на который зачислен кредит
+	RepaymentAccountID *uint         `json:"repayment_account_id,omitempty"` // счет для автоматического списания платежей
+	Outstanding        CreditBalance `json:"outstanding"`
+	CreatedAt          time.Time     `json:"created_at"`
+}
+
+// CreditBalance — разбивка задолженности по кредиту на составляющие
+type CreditBalance struct {
+	Principal Money `json:"principal"` // непогашенный основной долг
+	Interest  Money `json:"interest"`  // начисленные и неуплаченные проценты
+	Penalties Money `json:"penalties"` // неустойка за просрочку
+	Fees      Money `json:"fees"`      // комиссии
 }
 
+// Total возвращает общую сумму задолженности
+func (b CreditBalance) Total() Money {
+	return b.Principal.Add(b.Interest).Add(b.Penalties).Add(b.Fees)
+}
+
+// Add возвращает покомпонентную сумму двух разбивок
+func (b CreditBalance) Add(other CreditBalance) CreditBalance {
+	return CreditBalance{
+		Principal: b.Principal.Add(other.Principal),
+		Interest:  b.Interest.Add(other.Interest),
+		Penalties: b.Penalties.Add(other.Penalties),
+		Fees:      b.Fees.Add(other.Fees),
+	}
+}
+
+// Neg возвращает разбивку с противоположными знаками
+func (b CreditBalance) Neg() CreditBalance {
+	return CreditBalance{
+		Principal: b.Principal.Neg(),
+		Interest:  b.Interest.Neg(),
+		Penalties: b.Penalties.Neg(),
+		Fees:      b.Fees.Neg(),
+	}
+}
+
+// CreditBalanceInfo — задолженность по кредиту и сумма ближайшего платежа по графику
+type CreditBalanceInfo struct {
+	CreditID uint `json:"credit_id"`
+	CreditBalance
+	Total     Money      `json:"total"`
+	AmountDue Money      `json:"amount_due"` // сумма, которую нужно внести ближайшим платежом по графику
+	DueDate   *time.Time `json:"due_date,omitempty"`
+}
+
+// PaymentSchedule — строка графика платежей: платеж Amount состоит из погашения
+// основного долга Principal и процентов Interest, RemainingBalance — остаток долга после платежа
 type PaymentSchedule struct {
-	ID        uint      `json:"id"`
-	CreditID  uint      `json:"credit_id"`
-	DueDate   time.Time `json:"due_date"`
-	Amount    float64   `json:"amount"`
-	IsPaid    bool      `json:"is_paid"`
-	CreatedAt time.Time `json:"created_at"`
-}
\ No newline at end of file
+	ID               uint      `json:"id"`
+	CreditID         uint      `json:"credit_id"`
+	DueDate          time.Time `json:"due_date"`
+	Amount           Money     `json:"amount"`
+	Principal        Money     `json:"principal"`
+	Interest         Money     `json:"interest"`
+	RemainingBalance Money     `json:"remaining_balance"`
+	IsPaid           bool      `json:"is_paid"`
+	InterestAccrued  bool      `json:"-"` // проценты по строке включены в задолженность по кредиту
+	CreatedAt        time.Time `json:"created_at"`
+}
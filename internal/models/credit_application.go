@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Статусы заявки на кредит
+const (
+	ApplicationSubmitted = "submitted" // заявка принята
+	ApplicationScoring   = "scoring"   // идет оценка кредитоспособности
+	ApplicationApproved  = "approved"  // одобрена, кредит можно получить
+	ApplicationRejected  = "rejected"  // отклонена
+	ApplicationIssued    = "issued"    // кредит выдан
+)
+
+// CreditApplication — заявка на кредит. Кредит выдается только по одобренной заявке
+type CreditApplication struct {
+	ID           uint      `json:"id"`
+	UserID       uint      `json:"user_id"`
+	ProductID    uint      `json:"product_id"`
+	AccountID    uint      `json:"account_id"` // счет для зачисления кредита
+	Amount       Money     `json:"amount"`
+	Term         int       `json:"term"` // в месяцах
+	ScheduleType string    `json:"schedule_type"`
+	Status       string    `json:"status"`
+	Score        *int      `json:"score,omitempty"`
+	DebtToIncome *float64  `json:"debt_to_income,omitempty"` // доля ежемесячных платежей в доходе, в процентах
+	RejectReason string    `json:"reject_reason,omitempty"`
+	CreditID     *uint     `json:"credit_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
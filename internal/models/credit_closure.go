@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// PayoffQuote — сумма полного погашения кредита на дату Date с разбивкой по составляющим задолженности.
+// Включает проценты по платежам, наступающим до этой даты, и неустойку, которая будет начислена к ней
+type PayoffQuote struct {
+	CreditID uint      `json:"credit_id"`
+	Date     time.Time `json:"date"`
+	CreditBalance
+	Total Money `json:"total"`
+}
+
+// ClosureCertificate — справка о полном погашении кредита. Document — текст справки с открытой PGP-подписью банка
+type ClosureCertificate struct {
+	ID        uint       `json:"id"`
+	CreditID  uint       `json:"credit_id"`
+	Number    string     `json:"number"`
+	ClosedAt  time.Time  `json:"closed_at"`
+	TotalPaid Money      `json:"total_paid"`
+	Document  string     `json:"document"`
+	EmailedAt *time.Time `json:"emailed_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// CreditProduct — кредитный продукт с ограничениями по сумме и сроку и правилами ценообразования.
+// Ставка по кредиту равна ключевой ставке ЦБ РФ плюс спред из таблицы Spreads
+type CreditProduct struct {
+	ID              uint                  `json:"id"`
+	Code            string                `json:"code"` // consumer, mortgage, car
+	Name            string                `json:"name"`
+	MinAmount       Money                 `json:"min_amount"`
+	MaxAmount       Money                 `json:"max_amount"`
+	Terms           []int                 `json:"terms"`             // допустимые сроки в месяцах
+	IssueFeePercent float64               `json:"issue_fee_percent"` // комиссия за выдачу в процентах от суммы
+	IssueFeeFixed   Money                 `json:"issue_fee_fixed"`   // фиксированная комиссия за выдачу
+	IsActive        bool                  `json:"is_active"`
+	CreatedAt       time.Time             `json:"created_at"`
+	Spreads         []CreditProductSpread `json:"spreads"`
+}
+
+// CreditProductSpread — надбавка к ключевой ставке для диапазона сроков и сумм (границы включительно)
+type CreditProductSpread struct {
+	ID        uint    `json:"id"`
+	ProductID uint    `json:"product_id"`
+	MinTerm   int     `json:"min_term"`
+	MaxTerm   int     `json:"max_term"`
+	MinAmount Money   `json:"min_amount"`
+	MaxAmount Money   `json:"max_amount"`
+	Spread    float64 `json:"spread"` // в процентных пунктах
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// CreditRestructuring — реструктуризация кредита: кредитные каникулы (перенос платежей на GraceMonths месяцев),
+// увеличение срока на ExtendMonths платежей и изменение ставки. Version — номер графика, действовавшего
+// до реструктуризации; сам график сохраняется в PreviousSchedule
+type CreditRestructuring struct {
+	ID               uint              `json:"id"`
+	CreditID         uint              `json:"credit_id"`
+	OperatorID       *uint             `json:"operator_id,omitempty"` // сотрудник поддержки, проводивший реструктуризацию
+	Version          int               `json:"version"`
+	GraceMonths      int               `json:"grace_months"`
+	ExtendMonths     int               `json:"extend_months"`
+	OldInterestRate  float64           `json:"old_interest_rate"`
+	NewInterestRate  float64           `json:"new_interest_rate"`
+	OldTerm          int               `json:"old_term"`
+	NewTerm          int               `json:"new_term"`
+	Reason           string            `json:"reason,omitempty"`
+	PreviousSchedule []PaymentSchedule `json:"previous_schedule,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Статусы котировки обмена валюты
+const (
+	FXQuoteActive   = "active"
+	FXQuoteExecuted = "executed"
+)
+
+// FXQuote — котировка обмена валюты между собственными счетами пользователя.
+// Действует до ExpiresAt и может быть исполнена только один раз
+type FXQuote struct {
+	ID            uint       `json:"id"`
+	UserID        uint       `json:"user_id"`
+	FromAccount   uint       `json:"from_account"`
+	ToAccount     uint       `json:"to_account"`
+	Amount        Money      `json:"amount"` // сумма списания в валюте FromCurrency
+	FromCurrency  string     `json:"from_currency"`
+	ToAmount      Money      `json:"to_amount"` // сумма зачисления в валюте ToCurrency
+	ToCurrency    string     `json:"to_currency"`
+	MarketRate    float64    `json:"market_rate"` // официальный курс ЦБ РФ
+	Rate          float64    `json:"rate"`        // курс с учетом спреда банка
+	SpreadPercent float64    `json:"spread_percent"`
+	Status        string     `json:"status"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	ExecutedAt    *time.Time `json:"executed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
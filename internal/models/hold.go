@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Статусы блокировки средств
+const (
+	HoldActive   = "active"   // сумма недоступна для расходования
+	HoldCaptured = "captured" // сумма списана со счета
+	HoldReleased = "released" // блокировка снята без списания
+	HoldExpired  = "expired"  // блокировка снята по истечении срока
+)
+
+// Источники блокировки средств
+const (
+	HoldSourceManual            = "manual"             // установлена владельцем счета через API
+	HoldSourceCardAuthorization = "card_authorization" // авторизация оплаты картой, снимается списанием или отменой
+)
+
+// Hold — блокировка части остатка счета под ожидаемое списание
+type Hold struct {
+	ID          uint       `json:"id"`
+	AccountID   uint       `json:"account_id"`
+	Amount      Money      `json:"amount"`
+	Currency    string     `json:"currency"`
+	Source      string     `json:"source"`
+	Description string     `json:"description,omitempty"`
+	ReferenceID *uint      `json:"reference_id,omitempty"` // ID операции источника, например авторизации по карте
+	Status      string     `json:"status"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	ReleasedAt  *time.Time `json:"released_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// IdempotencyKey — сохраненный результат запроса с заголовком Idempotency-Key.
+// StatusCode равен 0, пока исходный запрос еще обрабатывается; LockedUntil — срок, до которого
+// ключ занят обрабатывающим запрос процессом
+type IdempotencyKey struct {
+	ID           uint      `json:"id"`
+	UserID       uint      `json:"user_id"`
+	Key          string    `json:"key"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	RequestHash  string    `json:"request_hash"`
+	StatusCode   int       `json:"status_code"`
+	ContentType  string    `json:"content_type"`
+	ResponseBody []byte    `json:"-"`
+	LockedUntil  time.Time `json:"locked_until"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Способы запуска задачи планировщика
+const (
+	JobTriggerSchedule = "schedule" // по расписанию
+	JobTriggerManual   = "manual"   // вручную через API
+)
+
+// Статусы запуска задачи
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// JobRun — запись истории запуска задачи планировщика
+type JobRun struct {
+	ID          uint       `json:"id"`
+	JobName     string     `json:"job_name"`
+	TriggeredBy string     `json:"triggered_by"`
+	Status      string     `json:"status"`
+	Instance    string     `json:"instance"` // экземпляр сервиса, выполнявший задачу
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	DurationMs  *int64     `json:"duration_ms,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// JobInfo — задача планировщика с расписанием, временем следующего запуска и последним запуском
+type JobInfo struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	NextRun  time.Time `json:"next_run"`
+	LastRun  *JobRun   `json:"last_run,omitempty"`
+}
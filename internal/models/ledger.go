@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// Направления проводки
+const (
+	DirectionDebit  = "debit"
+	DirectionCredit = "credit"
+)
+
+// Счета главной книги
+const (
+	LedgerCustomer       = "customer"        // счет клиента (обязательство банка), привязан к AccountID
+	LedgerLoan           = "loan"            // задолженность по кредиту (актив банка), привязан к CreditID
+	LedgerCash           = "cash"            // внешние поступления и выплаты (касса, корреспондентский счет)
+	LedgerPenaltyIncome  = "penalty_income"  // доход от штрафов
+	LedgerFeeIncome      = "fee_income"      // комиссионный доход
+	LedgerInterestIncome = "interest_income" // процентный доход
+	LedgerFXPosition     = "fx_position"     // валютная позиция банка при конвертации
+	LedgerCardSettlement = "card_settlement" // расчеты с торговыми предприятиями по операциям с картами
+)
+
+// Типы записей журнала
+const (
+	EntryOpeningBalance      = "opening_balance"
+	EntryTransfer            = "transfer"
+	EntryFXExchange          = "fx_exchange"
+	EntryTransaction         = "transaction"
+	EntryTransactionReversal = "transaction_reversal"
+	EntryCreditDisbursement  = "credit_disbursement"
+	EntryCreditRepayment     = "credit_repayment"
+	EntryPenalty             = "penalty"
+	EntryInterestAccrual     = "interest_accrual"
+	EntryCardCapture         = "card_capture"
+	EntryCardRefund          = "card_refund"
+)
+
+// JournalEntry — запись журнала: одна хозяйственная операция,
+// состоящая из сбалансированных проводок по дебету и кредиту
+type JournalEntry struct {
+	ID          uint      `json:"id"`
+	Type        string    `json:"type"`
+	ReferenceID uint      `json:"reference_id"` // ID перевода, операции, кредита или платежа в зависимости от Type
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	Postings    []Posting `json:"postings,omitempty"`
+}
+
+// Posting — проводка по одному счету главной книги
+type Posting struct {
+	ID            uint      `json:"id"`
+	EntryID       uint      `json:"entry_id"`
+	LedgerAccount string    `json:"ledger_account"`
+	AccountID     *uint     `json:"account_id,omitempty"`
+	CreditID      *uint     `json:"credit_id,omitempty"`
+	Direction     string    `json:"direction"` // "debit" или "credit"
+	Amount        Money     `json:"amount"`
+	Currency      string    `json:"currency"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AccountHistoryItem — движение по счету клиента вместе с описанием операции
+type AccountHistoryItem struct {
+	EntryID     uint      `json:"entry_id"`
+	Type        string    `json:"type"`
+	ReferenceID uint      `json:"reference_id"`
+	Description string    `json:"description"`
+	Direction   string    `json:"direction"`
+	Amount      Money     `json:"amount"`
+	Currency    string    `json:"currency"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BalanceMismatch — расхождение сохраненного остатка с остатком по проводкам
+type BalanceMismatch struct {
+	LedgerAccount string `json:"ledger_account"`
+	ID            uint   `json:"id"`
+	Stored        Money  `json:"stored"`
+	Ledger        Money  `json:"ledger"`
+}
+
+// CustomerPosting — проводка по счету клиента
+func CustomerPosting(direction string, accountID uint, amount Money) Posting {
+	return Posting{LedgerAccount: LedgerCustomer, AccountID: &accountID, Direction: direction, Amount: amount, Currency: amount.Currency()}
+}
+
+// LoanPosting — проводка по ссудному счету кредита
+func LoanPosting(direction string, creditID uint, amount Money) Posting {
+	return Posting{LedgerAccount: LedgerLoan, CreditID: &creditID, Direction: direction, Amount: amount, Currency: amount.Currency()}
+}
+
+// BankPosting — проводка по внутреннему счету банка (касса, доходы)
+func BankPosting(direction, ledgerAccount string, amount Money) Posting {
+	return Posting{LedgerAccount: ledgerAccount, Direction: direction, Amount: amount, Currency: amount.Currency()}
+}
@@ -0,0 +1,360 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency — валюта, в которой ведутся кредиты и суммы без явно указанной валюты
+const DefaultCurrency = "RUB"
+
+// Количество минимальных единиц (копеек) в одной единице валюты.
+// Соответствует масштабу колонок DECIMAL(15, 2)
+const minorUnits = 100
+
+// maxMinor — наибольшая по модулю сумма в копейках, которая помещается в колонку DECIMAL(15, 2)
+const maxMinor = 999_999_999_999_999
+
+var (
+	// ErrCurrencyMismatch — сложение, вычитание или сравнение сумм в разных валютах
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrMoneyOverflow — сумма не помещается в колонку DECIMAL(15, 2)
+	ErrMoneyOverflow = errors.New("money amount out of range")
+)
+
+// RoundingMode — политика округления при операциях с денежными суммами
+type RoundingMode int
+
+const (
+	// RoundHalfUp — математическое округление: 0.005 -> 0.01
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven — банковское округление: 0.005 -> 0.00, 0.015 -> 0.02
+	RoundHalfEven
+)
+
+// DefaultRounding — политика округления, применяемая к расчетам по умолчанию
+var DefaultRounding = RoundHalfUp
+
+// Money — денежная сумма, хранимая в минимальных единицах валюты (копейках).
+// Арифметика над Money точная, округление выполняется только явно
+// при умножении/делении с указанием политики округления.
+type Money struct {
+	minor    int64
+	currency string
+}
+
+// NewMoney создает сумму из минимальных единиц валюты (копеек)
+func NewMoney(minor int64, currency string) Money {
+	return Money{minor: minor, currency: currency}
+}
+
+// ParseMoney разбирает десятичную строку вида "1234.56".
+// Строки с более чем двумя знаками после запятой отклоняются, чтобы не терять копейки молча,
+// а суммы, не помещающиеся в DECIMAL(15, 2), — с ErrMoneyOverflow
+func ParseMoney(s, currency string) (Money, error) {
+	m, err := parseMoney(s, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if !m.inRange() {
+		return Money{}, fmt.Errorf("%w: %s", ErrMoneyOverflow, strings.TrimSpace(s))
+	}
+	return m, nil
+}
+
+// parseMoney разбирает десятичную строку без ограничения DECIMAL(15, 2).
+// Используется при чтении из БД, где агрегаты (SUM) могут превышать размер колонки
+func parseMoney(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.ContainsAny(s, "/eE") {
+		return Money{}, fmt.Errorf("invalid money amount: %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Money{}, fmt.Errorf("invalid money amount: %q", s)
+	}
+	r.Mul(r, big.NewRat(minorUnits, 1))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("money amount %q has more than 2 decimal places", s)
+	}
+	if !r.Num().IsInt64() {
+		return Money{}, fmt.Errorf("money amount %q is out of range", s)
+	}
+	return Money{minor: r.Num().Int64(), currency: currency}, nil
+}
+
+// MoneyFromRatChecked округляет рациональное число до копеек по указанной политике.
+// Если результат не помещается в DECIMAL(15, 2), возвращается ErrMoneyOverflow
+func MoneyFromRatChecked(r *big.Rat, currency string, mode RoundingMode) (Money, error) {
+	scaled := new(big.Rat).Mul(r, big.NewRat(minorUnits, 1))
+	minor, ok := roundRat(scaled, mode)
+	if !ok || minor > maxMinor || minor < -maxMinor {
+		return Money{}, fmt.Errorf("%w: %s %s", ErrMoneyOverflow, r.FloatString(2), currency)
+	}
+	return Money{minor: minor, currency: currency}, nil
+}
+
+// MoneyFromRat — MoneyFromRatChecked, паникующий при переполнении
+func MoneyFromRat(r *big.Rat, currency string, mode RoundingMode) Money {
+	m, err := MoneyFromRatChecked(r, currency, mode)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// DecimalRat переводит десятичное значение (ставку, курс) в точное рациональное число
+// по его кратчайшему десятичному представлению, а не по двоичному значению float64
+func DecimalRat(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	return r
+}
+
+// roundRat округляет рациональное число до целого по указанной политике.
+// ok = false, если результат не помещается в int64
+func roundRat(r *big.Rat, mode RoundingMode) (minor int64, ok bool) {
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+	neg := num.Sign() < 0
+	num.Abs(num)
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	// Сравниваем остаток с половиной делителя: 2*rem <=> den
+	cmp := new(big.Int).Mul(rem, big.NewInt(2)).Cmp(den)
+	if cmp > 0 || (cmp == 0 && (mode == RoundHalfUp || quo.Bit(0) == 1)) {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if neg {
+		quo.Neg(quo)
+	}
+	if !quo.IsInt64() {
+		return 0, false
+	}
+	return quo.Int64(), true
+}
+
+// Minor возвращает сумму в минимальных единицах валюты (копейках)
+func (m Money) Minor() int64 {
+	return m.minor
+}
+
+// Currency возвращает код валюты (пустая строка, если валюта не указана)
+func (m Money) Currency() string {
+	return m.currency
+}
+
+// WithCurrency возвращает ту же сумму с указанной валютой
+func (m Money) WithCurrency(currency string) Money {
+	m.currency = currency
+	return m
+}
+
+// Rat возвращает сумму в виде точного рационального числа в единицах валюты
+func (m Money) Rat() *big.Rat {
+	return big.NewRat(m.minor, minorUnits)
+}
+
+// AddChecked складывает суммы. Валюта результата — валюта первого операнда, либо второго,
+// если у первого она не указана. Суммы в разных валютах не складываются: ErrCurrencyMismatch,
+// результат, не помещающийся в DECIMAL(15, 2), отклоняется с ErrMoneyOverflow
+func (m Money) AddChecked(other Money) (Money, error) {
+	currency, err := commonCurrency(m, other)
+	if err != nil {
+		return Money{}, err
+	}
+	sum := Money{minor: m.minor + other.minor, currency: currency}
+	// Переполнение int64: знак результата отличается от знаков обоих слагаемых
+	if (m.minor^sum.minor)&(other.minor^sum.minor) < 0 || !sum.inRange() {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrMoneyOverflow, m, other)
+	}
+	return sum, nil
+}
+
+// SubChecked вычитает сумму по тем же правилам, что и AddChecked
+func (m Money) SubChecked(other Money) (Money, error) {
+	currency, err := commonCurrency(m, other)
+	if err != nil {
+		return Money{}, err
+	}
+	diff := Money{minor: m.minor - other.minor, currency: currency}
+	// Переполнение int64: операнды разных знаков, а знак результата отличается от уменьшаемого
+	if (m.minor^other.minor)&(m.minor^diff.minor) < 0 || !diff.inRange() {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrMoneyOverflow, m, other)
+	}
+	return diff, nil
+}
+
+// Add — AddChecked, паникующий при разных валютах или переполнении. Суммы из разных валют
+// складываются только после конвертации, поэтому паника означает ошибку в коде, а не во входных данных
+func (m Money) Add(other Money) Money {
+	sum, err := m.AddChecked(other)
+	if err != nil {
+		panic(err)
+	}
+	return sum
+}
+
+// Sub — SubChecked, паникующий при разных валютах или переполнении
+func (m Money) Sub(other Money) Money {
+	diff, err := m.SubChecked(other)
+	if err != nil {
+		panic(err)
+	}
+	return diff
+}
+
+// Neg возвращает сумму с противоположным знаком
+func (m Money) Neg() Money {
+	m.minor = -m.minor
+	return m
+}
+
+// MulRat умножает сумму на рациональный коэффициент с округлением до копеек
+func (m Money) MulRat(factor *big.Rat, mode RoundingMode) Money {
+	return MoneyFromRat(new(big.Rat).Mul(m.Rat(), factor), m.currency, mode)
+}
+
+// Percent вычисляет указанный процент от суммы с округлением до копеек
+func (m Money) Percent(percent float64, mode RoundingMode) Money {
+	factor := new(big.Rat).Quo(DecimalRat(percent), big.NewRat(100, 1))
+	return m.MulRat(factor, mode)
+}
+
+// DivInt делит сумму на целое число с округлением до копеек
+func (m Money) DivInt(n int64, mode RoundingMode) Money {
+	return MoneyFromRat(new(big.Rat).Quo(m.Rat(), big.NewRat(n, 1)), m.currency, mode)
+}
+
+// CmpChecked сравнивает суммы: -1, если m < other; 0, если равны; +1, если m > other.
+// Суммы в разных валютах не сравниваются: ErrCurrencyMismatch
+func (m Money) CmpChecked(other Money) (int, error) {
+	if _, err := commonCurrency(m, other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.minor < other.minor:
+		return -1, nil
+	case m.minor > other.minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Cmp — CmpChecked, паникующий при разных валютах
+func (m Money) Cmp(other Money) int {
+	cmp, err := m.CmpChecked(other)
+	if err != nil {
+		panic(err)
+	}
+	return cmp
+}
+
+func (m Money) LessThan(other Money) bool {
+	return m.Cmp(other) < 0
+}
+
+func (m Money) GreaterThan(other Money) bool {
+	return m.Cmp(other) > 0
+}
+
+// inRange сообщает, помещается ли сумма в колонку DECIMAL(15, 2)
+func (m Money) inRange() bool {
+	return m.minor <= maxMinor && m.minor >= -maxMinor
+}
+
+func (m Money) IsZero() bool {
+	return m.minor == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.minor > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.minor < 0
+}
+
+// String возвращает сумму в виде десятичной строки "1234.56"
+func (m Money) String() string {
+	sign := ""
+	minor := m.minor
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/minorUnits, minor%minorUnits)
+}
+
+// MarshalJSON кодирует сумму строкой, чтобы клиенты не теряли точность на float
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON принимает как строку "100.50", так и число 100.50
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSpace(string(data))
+	if s == "null" {
+		*m = Money{currency: m.currency}
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseMoney(s, m.currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan реализует sql.Scanner для колонок DECIMAL
+func (m *Money) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*m = Money{currency: m.currency}
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		*m = Money{minor: v * minorUnits, currency: m.currency}
+		return nil
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	parsed, err := parseMoney(s, m.currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value реализует driver.Valuer: сумма передается в БД десятичной строкой
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// commonCurrency возвращает валюту результата операции над a и b. Сумма без валюты
+// (например, разобранная из запроса) совместима с любой валютой
+func commonCurrency(a, b Money) (string, error) {
+	switch {
+	case a.currency == "":
+		return b.currency, nil
+	case b.currency == "" || a.currency == b.currency:
+		return a.currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, a.currency, b.currency)
+}
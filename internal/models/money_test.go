@@ -0,0 +1,263 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		minor   int64
+		wantErr bool
+	}{
+		{in: "0", minor: 0},
+		{in: "100", minor: 10000},
+		{in: "100.5", minor: 10050},
+		{in: "1234.56", minor: 123456},
+		{in: " 7.01 ", minor: 701},
+		{in: "-0.01", minor: -1},
+		{in: "-15.10", minor: -1510},
+		{in: "0.001", wantErr: true},
+		{in: "1.234", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "1/3", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "100000000000000000000", wantErr: true},
+		{in: "9999999999999.99", minor: 999_999_999_999_999},
+		{in: "-9999999999999.99", minor: -999_999_999_999_999},
+		{in: "10000000000000", wantErr: true},
+		{in: "-10000000000000.00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			m, err := ParseMoney(tt.in, "RUB")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseMoney(%q) = %v, want error", tt.in, m)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMoney(%q) error: %v", tt.in, err)
+			}
+			if m.Minor() != tt.minor || m.Currency() != "RUB" {
+				t.Fatalf("ParseMoney(%q) = %d %s, want %d RUB", tt.in, m.Minor(), m.Currency(), tt.minor)
+			}
+		})
+	}
+}
+
+func TestMoneyFromRatRounding(t *testing.T) {
+	tests := []struct {
+		in       string
+		halfUp   int64
+		halfEven int64
+	}{
+		{in: "0.004", halfUp: 0, halfEven: 0},
+		{in: "0.005", halfUp: 1, halfEven: 0},
+		{in: "0.006", halfUp: 1, halfEven: 1},
+		{in: "0.015", halfUp: 2, halfEven: 2},
+		{in: "0.025", halfUp: 3, halfEven: 2},
+		{in: "1.125", halfUp: 113, halfEven: 112},
+		{in: "-0.004", halfUp: 0, halfEven: 0},
+		{in: "-0.005", halfUp: -1, halfEven: 0},
+		{in: "-0.006", halfUp: -1, halfEven: -1},
+		{in: "-0.015", halfUp: -2, halfEven: -2},
+		{in: "-0.025", halfUp: -3, halfEven: -2},
+		{in: "-1.125", halfUp: -113, halfEven: -112},
+		{in: "1/3", halfUp: 33, halfEven: 33},
+		{in: "-2/3", halfUp: -67, halfEven: -67},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			r, ok := new(big.Rat).SetString(tt.in)
+			if !ok {
+				t.Fatalf("bad test input %q", tt.in)
+			}
+			if got := MoneyFromRat(r, "RUB", RoundHalfUp).Minor(); got != tt.halfUp {
+				t.Errorf("RoundHalfUp(%s) = %d, want %d", tt.in, got, tt.halfUp)
+			}
+			if got := MoneyFromRat(r, "RUB", RoundHalfEven).Minor(); got != tt.halfEven {
+				t.Errorf("RoundHalfEven(%s) = %d, want %d", tt.in, got, tt.halfEven)
+			}
+		})
+	}
+}
+
+func TestMoneyFromRatOverflow(t *testing.T) {
+	huge := new(big.Rat).SetInt64(math.MaxInt64)
+	if _, err := MoneyFromRatChecked(huge, "RUB", RoundHalfUp); !errors.Is(err, ErrMoneyOverflow) {
+		t.Fatalf("MoneyFromRatChecked(MaxInt64) error = %v, want ErrMoneyOverflow", err)
+	}
+	assertPanics(t, func() { MoneyFromRat(huge, "RUB", RoundHalfUp) })
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		in    string
+		minor int64
+		out   string
+	}{
+		{in: `"100.50"`, minor: 10050, out: `"100.50"`},
+		{in: `100.5`, minor: 10050, out: `"100.50"`},
+		{in: `0`, minor: 0, out: `"0.00"`},
+		{in: `"-3.07"`, minor: -307, out: `"-3.07"`},
+		{in: `null`, minor: 0, out: `"0.00"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var m Money
+			if err := json.Unmarshal([]byte(tt.in), &m); err != nil {
+				t.Fatalf("Unmarshal(%s) error: %v", tt.in, err)
+			}
+			if m.Minor() != tt.minor {
+				t.Fatalf("Unmarshal(%s) = %d, want %d", tt.in, m.Minor(), tt.minor)
+			}
+			data, err := json.Marshal(m)
+			if err != nil {
+				t.Fatalf("Marshal error: %v", err)
+			}
+			if string(data) != tt.out {
+				t.Fatalf("Marshal = %s, want %s", data, tt.out)
+			}
+		})
+	}
+
+	var m Money
+	if err := json.Unmarshal([]byte(`"1.001"`), &m); err == nil {
+		t.Fatal("Unmarshal of an amount with 3 decimal places must fail")
+	}
+}
+
+func TestMoneyScanValueRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   interface{}
+		minor int64
+	}{
+		{name: "bytes", src: []byte("1234.56"), minor: 123456},
+		{name: "string", src: "-0.10", minor: -10},
+		{name: "int64", src: int64(42), minor: 4200},
+		{name: "float64", src: 0.1, minor: 10},
+		{name: "nil", src: nil, minor: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMoney(0, "USD")
+			if err := m.Scan(tt.src); err != nil {
+				t.Fatalf("Scan(%v) error: %v", tt.src, err)
+			}
+			if m.Minor() != tt.minor || m.Currency() != "USD" {
+				t.Fatalf("Scan(%v) = %d %s, want %d USD", tt.src, m.Minor(), m.Currency(), tt.minor)
+			}
+
+			v, err := m.Value()
+			if err != nil {
+				t.Fatalf("Value error: %v", err)
+			}
+			back := NewMoney(0, "USD")
+			if err := back.Scan(v); err != nil {
+				t.Fatalf("Scan(Value()) error: %v", err)
+			}
+			if back != m {
+				t.Fatalf("round trip = %v, want %v", back, m)
+			}
+		})
+	}
+
+	var m Money
+	if err := m.Scan(true); err == nil {
+		t.Fatal("Scan(bool) must fail")
+	}
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+	rub := NewMoney(100, "RUB")
+	usd := NewMoney(100, "USD")
+	untagged := NewMoney(50, "")
+
+	if _, err := rub.AddChecked(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("AddChecked(RUB, USD) error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := rub.SubChecked(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("SubChecked(RUB, USD) error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := rub.CmpChecked(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("CmpChecked(RUB, USD) error = %v, want ErrCurrencyMismatch", err)
+	}
+
+	assertPanics(t, func() { rub.Add(usd) })
+	assertPanics(t, func() { rub.Sub(usd) })
+	assertPanics(t, func() { rub.Cmp(usd) })
+	assertPanics(t, func() { rub.LessThan(usd) })
+	assertPanics(t, func() { rub.GreaterThan(usd) })
+
+	// Сумма без валюты совместима с любой валютой и принимает валюту второго операнда
+	if sum := untagged.Add(usd); sum.Minor() != 150 || sum.Currency() != "USD" {
+		t.Errorf("untagged + USD = %d %s, want 150 USD", sum.Minor(), sum.Currency())
+	}
+	if diff := rub.Sub(untagged); diff.Minor() != 50 || diff.Currency() != "RUB" {
+		t.Errorf("RUB - untagged = %d %s, want 50 RUB", diff.Minor(), diff.Currency())
+	}
+	if !untagged.LessThan(rub) || rub.Cmp(NewMoney(100, "RUB")) != 0 {
+		t.Error("comparison with matching or empty currency must succeed")
+	}
+}
+
+func TestMoneyAddSubOverflow(t *testing.T) {
+	max := NewMoney(maxMinor, "RUB")
+	min := NewMoney(-maxMinor, "RUB")
+	one := NewMoney(1, "RUB")
+
+	if _, err := max.AddChecked(one); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("max + 1 error = %v, want ErrMoneyOverflow", err)
+	}
+	if _, err := min.SubChecked(one); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("min - 1 error = %v, want ErrMoneyOverflow", err)
+	}
+	if got, err := max.SubChecked(one); err != nil || got.Minor() != maxMinor-1 {
+		t.Errorf("max - 1 = %v, %v", got, err)
+	}
+	if got, err := max.AddChecked(min); err != nil || !got.IsZero() {
+		t.Errorf("max + min = %v, %v", got, err)
+	}
+
+	// Переполнение int64 тоже обнаруживается
+	huge := NewMoney(math.MaxInt64, "RUB")
+	if _, err := huge.AddChecked(huge); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("MaxInt64 + MaxInt64 error = %v, want ErrMoneyOverflow", err)
+	}
+	assertPanics(t, func() { max.Add(one) })
+}
+
+func TestParseMoneyRange(t *testing.T) {
+	if _, err := ParseMoney("10000000000000.00", "RUB"); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("ParseMoney(10^13) error = %v, want ErrMoneyOverflow", err)
+	}
+	var m Money
+	if err := json.Unmarshal([]byte(`"99999999999999"`), &m); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("Unmarshal(10^14 - 1) error = %v, want ErrMoneyOverflow", err)
+	}
+	// Агрегаты из БД могут превышать размер колонки и читаются без ограничения
+	if err := m.Scan([]byte("10000000000000.00")); err != nil || m.Minor() != 1_000_000_000_000_000 {
+		t.Errorf("Scan(10^13) = %v, %v", m, err)
+	}
+	if _, err := MoneyFromRatChecked(big.NewRat(10_000_000_000_000, 1), "RUB", RoundHalfUp); !errors.Is(err, ErrMoneyOverflow) {
+		t.Errorf("MoneyFromRatChecked(10^13) error = %v, want ErrMoneyOverflow", err)
+	}
+}
+
+func assertPanics(t *testing.T, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	fn()
+}
@@ -2,11 +2,26 @@ package models
 
 import "time"
 
+// Типы платежей по кредиту
+const (
+	PaymentScheduled      = "scheduled"       // платеж по графику
+	PaymentEarlyRepayment = "early_repayment" // досрочное погашение
+)
+
+// Способы пересчета графика при частичном досрочном погашении
+const (
+	EarlyRepaymentReduceTerm    = "reduce_term"    // платеж сохраняется, срок сокращается
+	EarlyRepaymentReducePayment = "reduce_payment" // срок сохраняется, платеж уменьшается
+)
+
 type Payment struct {
 	ID          uint      `json:"id"`
-	CreditID     uint      `json:"credit_id"`
-	Amount       float64   `json:"amount"`
-	PaymentDate  time.Time `json:"payment_date"`
-	Status       string    `json:"status"` // "pending", "completed", "failed"
-	CreatedAt    time.Time `json:"created_at"`
-}
\ No newline at end of file
+	CreditID    uint      `json:"credit_id"`
+	ScheduleID  *uint     `json:"schedule_id,omitempty"` // строка графика для платежа типа scheduled
+	AccountID   *uint     `json:"account_id,omitempty"`  // счет клиента, с которого списан платеж
+	Type        string    `json:"type"`
+	Amount      Money     `json:"amount"`
+	PaymentDate time.Time `json:"payment_date"`
+	Status      string    `json:"status"` // "pending", "completed", "failed", "cancelled"
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// PaymentReminder — напоминание заемщику о предстоящем платеже по графику
+type PaymentReminder struct {
+	ScheduleID uint      `json:"schedule_id"`
+	CreditID   uint      `json:"credit_id"`
+	Email      string    `json:"email"`
+	DueDate    time.Time `json:"due_date"`
+	Amount     Money     `json:"amount"`
+}
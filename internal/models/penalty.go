@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Penalty — неустойка, начисленная за один день просрочки платежа по графику
+type Penalty struct {
+	ID          uint      `json:"id"`
+	CreditID    uint      `json:"credit_id"`
+	PaymentID   uint      `json:"payment_id"`
+	AccrualDate time.Time `json:"accrual_date"`
+	Amount      Money     `json:"amount"`
+	CreatedAt   time.Time `json:"created_at"`
+}
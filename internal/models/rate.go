@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// CurrencyRate — официальный курс ЦБ РФ: стоимость Nominal единиц валюты в рублях
+type CurrencyRate struct {
+	Date    time.Time `json:"date"`
+	Code    string    `json:"code"` // буквенный код валюты ISO 4217
+	Nominal int       `json:"nominal"`
+	Value   float64   `json:"value"`
+}
+
+// KeyRate — значение ключевой ставки ЦБ РФ, действующее с даты Date
+type KeyRate struct {
+	Date time.Time `json:"date"`
+	Rate float64   `json:"rate"`
+}
@@ -5,7 +5,7 @@ import "time"
 type Transaction struct {
 	ID          uint      `json:"id"`
 	AccountID    uint      `json:"account_id"`
-	Amount       float64   `json:"amount"`
+	Amount       Money     `json:"amount"`
 	Type         string    `json:"type"` // "income" или "expense"
 	Category      string    `json:"category"`
 	Description  string    `json:"description"`
@@ -3,10 +3,14 @@ package models
 import "time"
 
 type Transfer struct {
-	ID          uint      `json:"id"`
+	ID           uint      `json:"id"`
 	FromAccount  uint      `json:"from_account"`
 	ToAccount    uint      `json:"to_account"`
-	Amount       float64   `json:"amount"`
+	Amount       Money     `json:"amount"` // сумма списания в валюте счета отправителя
+	Currency     string    `json:"currency"`
+	ToAmount     Money     `json:"to_amount"` // сумма зачисления в валюте счета получателя
+	ToCurrency   string    `json:"to_currency"`
+	ExchangeRate float64   `json:"exchange_rate"` // примененный курс: единиц ToCurrency за единицу Currency
 	Description  string    `json:"description"`
 	CreatedAt    time.Time `json:"created_at"`
-}
\ No newline at end of file
+}
@@ -8,12 +8,21 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Роли пользователей
+const (
+	RoleCustomer = "customer" // клиент банка
+	RoleSupport  = "support"  // сотрудник службы поддержки
+	RoleMerchant = "merchant" // торговое предприятие, принимающее оплату картами банка
+)
+
 type User struct {
-	ID        uint      `json:"id"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	Username  string    `json:"username"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                  uint      `json:"id"`
+	Email               string    `json:"email"`
+	Password            string    `json:"password"`
+	Username            string    `json:"username"`
+	Role                string    `json:"role"`
+	PaymentReminderDays *int      `json:"payment_reminder_days,omitempty"` // за сколько дней напоминать о платеже по кредиту
+	CreatedAt           time.Time `json:"created_at"`
 }
 
 // Валидация username
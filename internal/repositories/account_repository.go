@@ -3,18 +3,33 @@ package repositories
 import (
 	"database/sql"
 	"fmt"
+	"slices"
 
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
 )
 
 type AccountRepository struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func NewAccountRepository(db *sql.DB) *AccountRepository {
 	return &AccountRepository{DB: db}
 }
 
+// accountColumns — колонки счета и доступный остаток: остаток за вычетом действующих блокировок средств
+const accountColumns = `a.id, a.user_id, a.balance, a.currency, a.created_at,
+	a.balance - COALESCE((SELECT SUM(h.amount) FROM account_holds h
+	                      WHERE h.account_id = a.id AND h.status = '` + models.HoldActive + `' AND h.expires_at > NOW()), 0)`
+
+func scanAccount(row rowScanner, account *models.Account) error {
+	if err := row.Scan(&account.ID, &account.UserID, &account.Balance, &account.Currency, &account.CreatedAt, &account.AvailableBalance); err != nil {
+		return err
+	}
+	account.Balance = account.Balance.WithCurrency(account.Currency)
+	account.AvailableBalance = account.AvailableBalance.WithCurrency(account.Currency)
+	return nil
+}
+
 func (r *AccountRepository) CreateAccount(account *models.Account) error {
 	// Используем RETURNING для получения ID созданной записи
 	query := `INSERT INTO accounts (user_id, balance, currency, created_at)
@@ -26,7 +41,7 @@ func (r *AccountRepository) CreateAccount(account *models.Account) error {
 
 func (r *AccountRepository) GetAccountsByUserID(userID uint) ([]models.Account, error) {
 	var accounts []models.Account
-	query := `SELECT id, user_id, balance, currency, created_at FROM accounts WHERE user_id=$1`
+	query := `SELECT ` + accountColumns + ` FROM accounts a WHERE a.user_id=$1`
 	rows, err := r.DB.Query(query, userID)
 	if err != nil {
 		return nil, err
@@ -35,7 +50,7 @@ func (r *AccountRepository) GetAccountsByUserID(userID uint) ([]models.Account,
 
 	for rows.Next() {
 		var account models.Account
-		if err := rows.Scan(&account.ID, &account.UserID, &account.Balance, &account.Currency, &account.CreatedAt); err != nil {
+		if err := scanAccount(rows, &account); err != nil {
 			return nil, err
 		}
 		accounts = append(accounts, account)
@@ -45,16 +60,52 @@ func (r *AccountRepository) GetAccountsByUserID(userID uint) ([]models.Account,
 
 func (r *AccountRepository) GetAccountByID(accountID uint) (*models.Account, error) {
     var account models.Account
-    query := `SELECT id, user_id, balance, currency, created_at FROM accounts WHERE id=$1`
-    err := r.DB.QueryRow(query, accountID).Scan(&account.ID, &account.UserID, &account.Balance, &account.Currency, &account.CreatedAt)
+    query := `SELECT ` + accountColumns + ` FROM accounts a WHERE a.id=$1`
+    err := scanAccount(r.DB.QueryRow(query, accountID), &account)
     if err != nil {
         return nil, fmt.Errorf("failed to get account: %v", err)
     }
     return &account, nil
 }
 
-func (r *AccountRepository) UpdateAccount(account *models.Account) error {
-	query := `UPDATE accounts SET balance=$1, currency=$2 WHERE id=$3`
-	_, err := r.DB.Exec(query, account.Balance, account.Currency, account.ID)
-	return err
-}
\ No newline at end of file
+// GetAccountForUpdate читает счет с блокировкой строки до конца транзакции. Блокировки средств создаются
+// только под блокировкой счета, поэтому доступный остаток не меняется до конца транзакции
+func (r *AccountRepository) GetAccountForUpdate(accountID uint) (*models.Account, error) {
+	var account models.Account
+	query := `SELECT ` + accountColumns + ` FROM accounts a WHERE a.id=$1 FOR UPDATE OF a`
+	err := scanAccount(r.DB.QueryRow(query, accountID), &account)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("account %d not found", accountID)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to lock account %d: %v", accountID, err)
+	}
+	return &account, nil
+}
+
+// LockAccounts блокирует несколько счетов в порядке возрастания ID.
+// Единый порядок захвата блокировок исключает взаимоблокировку встречных переводов
+func (r *AccountRepository) LockAccounts(accountIDs ...uint) (map[uint]*models.Account, error) {
+	ids := slices.Clone(accountIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	accounts := make(map[uint]*models.Account, len(ids))
+	for _, id := range ids {
+		account, err := r.GetAccountForUpdate(id)
+		if err != nil {
+			return nil, err
+		}
+		accounts[id] = account
+	}
+	return accounts, nil
+}
+
+// AdjustBalance атомарно изменяет баланс счета на delta (отрицательное значение — списание)
+func (r *AccountRepository) AdjustBalance(accountID uint, delta models.Money) error {
+	query := `UPDATE accounts SET balance = balance + $1 WHERE id = $2`
+	_, err := r.DB.Exec(query, delta, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to update balance of account %d: %v", accountID, err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+)
+
+// LockManager выдает сессионные advisory-блокировки PostgreSQL. Блокировка держится на выделенном
+// соединении и снимается при Unlock или автоматически при разрыве соединения, например при падении
+// экземпляра, который ее захватил
+type LockManager struct {
+	DB *sql.DB
+}
+
+func NewLockManager(db *sql.DB) *LockManager {
+	return &LockManager{DB: db}
+}
+
+// AdvisoryLock — захваченная advisory-блокировка
+type AdvisoryLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// advisoryLockKey переводит имя блокировки в ключ pg_advisory_lock
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// TryLock пытается захватить блокировку name без ожидания. Возвращает nil, если она занята
+func (m *LockManager) TryLock(ctx context.Context, name string) (*AdvisoryLock, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for advisory lock: %v", err)
+	}
+
+	key := advisoryLockKey(name)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to acquire advisory lock %s: %v", name, err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+	return &AdvisoryLock{conn: conn, key: key}, nil
+}
+
+// Alive проверяет, что соединение, на котором держится блокировка, не разорвано
+func (l *AdvisoryLock) Alive(ctx context.Context) bool {
+	return l.conn.PingContext(ctx) == nil
+}
+
+// Unlock снимает блокировку и возвращает соединение в пул. Если снять блокировку не удалось,
+// соединение закрывается, чтобы блокировка не осталась у соединения из пула
+func (l *AdvisoryLock) Unlock() error {
+	defer l.conn.Close()
+	if _, err := l.conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		return fmt.Errorf("failed to release advisory lock: %v", err)
+	}
+	return nil
+}
@@ -20,22 +20,25 @@ func NewAnalyticsRepository(db *sql.DB) *AnalyticsRepository {
 func (r *AnalyticsRepository) GetIncomeExpenseStats(userID uint, startDate, endDate time.Time) (*models.IncomeExpenseStats, error) {
 	var stats models.IncomeExpenseStats
 
+	// Доходы и расходы считаем по проводкам главной книги: кредит счета клиента — поступление,
+	// дебет — списание. Входящие остатки не являются движением средств и не учитываются
+	query := `SELECT COALESCE(SUM(p.amount), 0)
+	          FROM postings p
+	          JOIN journal_entries e ON e.id = p.entry_id
+	          WHERE p.ledger_account = $1
+	          AND p.direction = $2
+	          AND e.type <> $3
+	          AND p.account_id IN (SELECT id FROM accounts WHERE user_id=$4)
+	          AND p.created_at BETWEEN $5 AND $6`
+
 	// Получаем доходы
-	incomeQuery := `SELECT COALESCE(SUM(amount), 0)
-	               FROM transfers
-	               WHERE to_account IN (SELECT id FROM accounts WHERE user_id=$1)
-	               AND created_at BETWEEN $2 AND $3`
-	err := r.DB.QueryRow(incomeQuery, userID, startDate, endDate).Scan(&stats.Income)
+	err := r.DB.QueryRow(query, models.LedgerCustomer, models.DirectionCredit, models.EntryOpeningBalance, userID, startDate, endDate).Scan(&stats.Income)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get income: %v", err)
 	}
 
 	// Получаем расходы
-	expenseQuery := `SELECT COALESCE(SUM(amount), 0)
-	               FROM transfers
-	               WHERE from_account IN (SELECT id FROM accounts WHERE user_id=$1)
-	               AND created_at BETWEEN $2 AND $3`
-	err = r.DB.QueryRow(expenseQuery, userID, startDate, endDate).Scan(&stats.Expense)
+	err = r.DB.QueryRow(query, models.LedgerCustomer, models.DirectionDebit, models.EntryOpeningBalance, userID, startDate, endDate).Scan(&stats.Expense)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get expense: %v", err)
 	}
@@ -74,69 +77,107 @@ func (r *AnalyticsRepository) GetBalanceForecast(userID uint, days int) ([]model
 		return nil, fmt.Errorf("failed to get payment schedules: %v", err)
 	}
 
-	// Вычисляем прогноз баланса
+	// Вычисляем прогноз баланса отдельно по каждой валюте: суммы в разных валютах не складываются
 	for i := 0; i < days; i++ {
 		date := time.Now().AddDate(0, 0, i)
-		balance := 0.0
+		balances := make(map[string]models.Money)
+		add := func(amount models.Money) {
+			balances[amount.Currency()] = balances[amount.Currency()].WithCurrency(amount.Currency()).Add(amount)
+		}
 
 		// Добавляем балансы всех счетов
 		for _, account := range accounts {
-			balance += account.Balance
+			add(account.Balance)
 		}
 
-		// Учитываем переводы
-
-
+		// Учитываем переводы: списание — в валюте счета отправителя, зачисление — в валюте счета получателя
 		for _, transfer := range transfers {
 			if transfer.CreatedAt.After(date) {
 				continue
 			}
 			if slices.Contains(accountIDs, transfer.FromAccount) {
-				balance -= transfer.Amount
-			} else {
-				balance += transfer.Amount
+				add(transfer.Amount.Neg())
+			}
+			if slices.Contains(accountIDs, transfer.ToAccount) {
+				add(transfer.ToAmount)
 			}
 		}
 
-		// Учитываем платежи по кредитам
+		// Учитываем платежи по кредитам, кредиты ведутся в валюте по умолчанию
 		for _, schedule := range paymentSchedules {
 			if schedule.DueDate.After(date) {
 				continue
 			}
-			balance -= schedule.Amount
+			add(schedule.Amount.WithCurrency(models.DefaultCurrency).Neg())
 		}
 
-		forecast = append(forecast, models.BalanceForecast{
-			Date:    date,
-			Balance: balance,
-		})
+		currencies := make([]string, 0, len(balances))
+		for currency := range balances {
+			currencies = append(currencies, currency)
+		}
+		slices.Sort(currencies)
+		for _, currency := range currencies {
+			forecast = append(forecast, models.BalanceForecast{
+				Date:     date,
+				Currency: currency,
+				Balance:  balances[currency],
+			})
+		}
 	}
 
 	return forecast, nil
 }
 
+// GetCreditLoad возвращает задолженность по активным кредитам пользователя
+// и сумму ближайших платежей по их графикам
 func (r *AnalyticsRepository) GetCreditLoad(userID uint) (*models.CreditLoad, error) {
 	var load models.CreditLoad
 
-	// Получаем кредиты пользователя
-	credits, err := r.getUserCredits(userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user credits: %v", err)
-	}
-
 	// Вычисляем общую задолженность
-	for _, credit := range credits {
-		load.TotalDebt += credit.Amount
+	query := `SELECT COALESCE(SUM(amount), 0) FROM credits WHERE user_id=$1 AND status=$2`
+	if err := r.DB.QueryRow(query, userID, models.CreditActive).Scan(&load.TotalDebt); err != nil {
+		return nil, fmt.Errorf("failed to get total debt: %v", err)
 	}
 
-	// Вычисляем средний ежемесячный платеж
-	if len(credits) > 0 {
-		load.MonthlyPayment = load.TotalDebt / float64(len(credits))
+	// Вычисляем ежемесячный платеж как сумму ближайших непогашенных платежей по каждому кредиту
+	query = `SELECT COALESCE(SUM(amount), 0)
+	         FROM payment_schedules
+	         WHERE id IN (
+	             SELECT DISTINCT ON (credit_id) id
+	             FROM payment_schedules
+	             WHERE NOT is_paid AND credit_id IN (SELECT id FROM credits WHERE user_id=$1 AND status=$2)
+	             ORDER BY credit_id, due_date
+	         )`
+	if err := r.DB.QueryRow(query, userID, models.CreditActive).Scan(&load.MonthlyPayment); err != nil {
+		return nil, fmt.Errorf("failed to get monthly payment: %v", err)
 	}
 
 	return &load, nil
 }
 
+// GetIncomingTurnover возвращает поступления на счета пользователя в валюте currency за период:
+// переводы со счетов других клиентов и операции пополнения
+func (r *AnalyticsRepository) GetIncomingTurnover(userID uint, currency string, startDate, endDate time.Time) (models.Money, error) {
+	var turnover models.Money
+	query := `SELECT
+	          (SELECT COALESCE(SUM(t.to_amount), 0)
+	           FROM transfers t
+	           WHERE t.to_currency = $2
+	           AND t.to_account IN (SELECT id FROM accounts WHERE user_id=$1)
+	           AND t.from_account NOT IN (SELECT id FROM accounts WHERE user_id=$1)
+	           AND t.created_at BETWEEN $3 AND $4)
+	          +
+	          (SELECT COALESCE(SUM(tr.amount), 0)
+	           FROM transactions tr
+	           JOIN accounts a ON a.id = tr.account_id
+	           WHERE a.user_id = $1 AND a.currency = $2 AND tr.type = 'income'
+	           AND tr.created_at BETWEEN $3 AND $4)`
+	if err := r.DB.QueryRow(query, userID, currency, startDate, endDate).Scan(&turnover); err != nil {
+		return models.Money{}, fmt.Errorf("failed to get incoming turnover: %v", err)
+	}
+	return turnover.WithCurrency(currency), nil
+}
+
 func (r *AnalyticsRepository) getUserAccounts(userID uint) ([]models.Account, error) {
 	var accounts []models.Account
 	query := `SELECT id, user_id, balance, currency, created_at
@@ -153,6 +194,7 @@ func (r *AnalyticsRepository) getUserAccounts(userID uint) ([]models.Account, er
 		if err := rows.Scan(&account.ID, &account.UserID, &account.Balance, &account.Currency, &account.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan account: %v", err)
 		}
+		account.Balance = account.Balance.WithCurrency(account.Currency)
 		accounts = append(accounts, account)
 	}
 	return accounts, nil
@@ -160,7 +202,7 @@ func (r *AnalyticsRepository) getUserAccounts(userID uint) ([]models.Account, er
 
 func (r *AnalyticsRepository) GetUserTransfers(userID uint) ([]models.Transfer, error) {
 	var transfers []models.Transfer
-	query := `SELECT id, from_account, to_account, amount, description, created_at
+	query := `SELECT id, from_account, to_account, amount, currency, to_amount, to_currency, exchange_rate, description, created_at
 	          FROM transfers
 	          WHERE from_account IN (SELECT id FROM accounts WHERE user_id=$1)
 	          OR to_account IN (SELECT id FROM accounts WHERE user_id=$1)`
@@ -172,7 +214,7 @@ func (r *AnalyticsRepository) GetUserTransfers(userID uint) ([]models.Transfer,
 
 	for rows.Next() {
 		var transfer models.Transfer
-		if err := rows.Scan(&transfer.ID, &transfer.FromAccount, &transfer.ToAccount, &transfer.Amount, &transfer.Description, &transfer.CreatedAt); err != nil {
+		if err := scanTransfer(rows, &transfer); err != nil {
 			return nil, fmt.Errorf("failed to scan transfer: %v", err)
 		}
 		transfers = append(transfers, transfer)
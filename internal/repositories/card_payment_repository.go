@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type CardPaymentRepository struct {
+	DB DBTX
+}
+
+func NewCardPaymentRepository(db *sql.DB) *CardPaymentRepository {
+	return &CardPaymentRepository{DB: db}
+}
+
+const cardAuthorizationColumns = `id, card_id, account_id, COALESCE(merchant_id, 0), COALESCE(hold_id, 0), amount,
+	captured_amount, refunded_amount, currency, COALESCE(description, ''), COALESCE(mcc, ''), status, expires_at, created_at, updated_at`
+
+func scanCardAuthorization(row rowScanner, authorization *models.CardAuthorization) error {
+	err := row.Scan(&authorization.ID, &authorization.CardID, &authorization.AccountID, &authorization.MerchantID,
+		&authorization.HoldID, &authorization.Amount, &authorization.Captured, &authorization.Refunded,
+		&authorization.Currency, &authorization.Description, &authorization.MCC, &authorization.Status, &authorization.ExpiresAt,
+		&authorization.CreatedAt, &authorization.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	authorization.Amount = authorization.Amount.WithCurrency(authorization.Currency)
+	authorization.Captured = authorization.Captured.WithCurrency(authorization.Currency)
+	authorization.Refunded = authorization.Refunded.WithCurrency(authorization.Currency)
+	return nil
+}
+
+func (r *CardPaymentRepository) CreateAuthorization(authorization *models.CardAuthorization) error {
+	query := `INSERT INTO card_authorizations (card_id, account_id, merchant_id, hold_id, amount, currency,
+	          description, mcc, status, expires_at, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $11) RETURNING id`
+	err := r.DB.QueryRow(query, authorization.CardID, authorization.AccountID, authorization.MerchantID,
+		authorization.HoldID, authorization.Amount, authorization.Currency, authorization.Description,
+		authorization.MCC, authorization.Status, authorization.ExpiresAt, authorization.CreatedAt).Scan(&authorization.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create card authorization: %v", err)
+	}
+	authorization.UpdatedAt = authorization.CreatedAt
+	return nil
+}
+
+// GetAuthorization возвращает авторизацию или nil, если ее нет
+func (r *CardPaymentRepository) GetAuthorization(authorizationID uint) (*models.CardAuthorization, error) {
+	return r.getAuthorization(`SELECT `+cardAuthorizationColumns+` FROM card_authorizations WHERE id=$1`, authorizationID)
+}
+
+// GetAuthorizationForUpdate возвращает авторизацию с блокировкой строки до конца транзакции или nil, если ее нет
+func (r *CardPaymentRepository) GetAuthorizationForUpdate(authorizationID uint) (*models.CardAuthorization, error) {
+	return r.getAuthorization(`SELECT `+cardAuthorizationColumns+` FROM card_authorizations WHERE id=$1 FOR UPDATE`, authorizationID)
+}
+
+func (r *CardPaymentRepository) getAuthorization(query string, authorizationID uint) (*models.CardAuthorization, error) {
+	var authorization models.CardAuthorization
+	err := scanCardAuthorization(r.DB.QueryRow(query, authorizationID), &authorization)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get card authorization: %v", err)
+	}
+	return &authorization, nil
+}
+
+// UpdateAuthorization сохраняет статус и суммы списания и возврата авторизации
+func (r *CardPaymentRepository) UpdateAuthorization(authorization *models.CardAuthorization) error {
+	query := `UPDATE card_authorizations SET status=$1, captured_amount=$2, refunded_amount=$3, updated_at=$4 WHERE id=$5`
+	_, err := r.DB.Exec(query, authorization.Status, authorization.Captured, authorization.Refunded,
+		authorization.UpdatedAt, authorization.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update card authorization: %v", err)
+	}
+	return nil
+}
+
+// GetExpiredAuthorizations блокирует и возвращает авторизации без списания, срок которых истек к now.
+// Авторизации, заблокированные параллельным списанием, пропускаются до следующего запуска
+func (r *CardPaymentRepository) GetExpiredAuthorizations(now time.Time) ([]models.CardAuthorization, error) {
+	var authorizations []models.CardAuthorization
+	query := `SELECT ` + cardAuthorizationColumns + `
+	          FROM card_authorizations
+	          WHERE status=$1 AND expires_at <= $2
+	          ORDER BY id
+	          FOR UPDATE SKIP LOCKED`
+	rows, err := r.DB.Query(query, models.CardAuthAuthorized, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired card authorizations: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var authorization models.CardAuthorization
+		if err := scanCardAuthorization(rows, &authorization); err != nil {
+			return nil, fmt.Errorf("failed to scan card authorization: %v", err)
+		}
+		authorizations = append(authorizations, authorization)
+	}
+	return authorizations, rows.Err()
+}
+
+func (r *CardPaymentRepository) CreateOperation(operation *models.CardPaymentOperation) error {
+	query := `INSERT INTO card_payment_operations (authorization_id, type, amount, created_at)
+	          VALUES ($1, $2, $3, $4) RETURNING id`
+	err := r.DB.QueryRow(query, operation.AuthorizationID, operation.Type, operation.Amount, operation.CreatedAt).Scan(&operation.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create card payment operation: %v", err)
+	}
+	return nil
+}
+
+// GetOperations возвращает операции по авторизации в хронологическом порядке
+func (r *CardPaymentRepository) GetOperations(authorizationID uint, currency string) ([]models.CardPaymentOperation, error) {
+	var operations []models.CardPaymentOperation
+	query := `SELECT id, authorization_id, type, amount, created_at
+	          FROM card_payment_operations
+	          WHERE authorization_id=$1
+	          ORDER BY id`
+	rows, err := r.DB.Query(query, authorizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card payment operations: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var operation models.CardPaymentOperation
+		if err := rows.Scan(&operation.ID, &operation.AuthorizationID, &operation.Type, &operation.Amount, &operation.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan card payment operation: %v", err)
+		}
+		operation.Amount = operation.Amount.WithCurrency(currency)
+		operations = append(operations, operation)
+	}
+	return operations, rows.Err()
+}
+
+// GetCardSpending возвращает сумму расходов по карте с момента since: действующие авторизации учитываются
+// полной суммой, списанные — за вычетом возвратов; отмененные и истекшие не учитываются
+func (r *CardPaymentRepository) GetCardSpending(cardID uint, since, now time.Time) (models.Money, error) {
+	var spent models.Money
+	query := `SELECT COALESCE(SUM(CASE
+	                     WHEN status=$3 AND expires_at > $4 THEN amount
+	                     WHEN status IN ($5, $6) THEN captured_amount - refunded_amount
+	                     ELSE 0 END), 0)
+	          FROM card_authorizations
+	          WHERE card_id=$1 AND created_at >= $2`
+	err := r.DB.QueryRow(query, cardID, since, models.CardAuthAuthorized, now,
+		models.CardAuthCaptured, models.CardAuthRefunded).Scan(&spent)
+	if err != nil {
+		return models.Money{}, fmt.Errorf("failed to get card spending: %v", err)
+	}
+	return spent, nil
+}
@@ -2,55 +2,268 @@ package repositories
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
 )
 
 type CardRepository struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func NewCardRepository(db *sql.DB) *CardRepository {
 	return &CardRepository{DB: db}
 }
 
+const cardColumns = `id, account_id, number, COALESCE(pan_masked, ''), cvv, COALESCE(cvv_encrypted, ''), expiry, hmac, status, status_changed_at, reissued_from, created_at`
+
+func scanCard(row rowScanner, card *models.Card) error {
+	var statusChangedAt sql.NullTime
+	var reissuedFrom sql.NullInt64
+	err := row.Scan(&card.ID, &card.AccountID, &card.Number, &card.MaskedNumber, &card.CVVHash, &card.CVVEncrypted, &card.Expiry, &card.HMAC, &card.Status,
+		&statusChangedAt, &reissuedFrom, &card.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if statusChangedAt.Valid {
+		card.StatusChangedAt = &statusChangedAt.Time
+	}
+	if reissuedFrom.Valid {
+		id := uint(reissuedFrom.Int64)
+		card.ReissuedFrom = &id
+	}
+	return nil
+}
+
 func (r *CardRepository) CreateCard(card *models.Card) error {
-    query := `INSERT INTO cards (account_id, number, cvv, expiry, hmac, created_at)
-              VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	if card.Status == "" {
+		card.Status = models.CardActive
+	}
+	query := `INSERT INTO cards (account_id, number, cvv, cvv_encrypted, expiry, hmac, pan_hash, pan_masked, status, reissued_from, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
 
-    return r.DB.QueryRow(query, card.AccountID, card.Number, card.CVV, card.Expiry, card.HMAC, card.CreatedAt).Scan(&card.ID)
+	return r.DB.QueryRow(query, card.AccountID, card.Number, card.CVVHash, card.CVVEncrypted, card.Expiry, card.HMAC, card.PANHash, card.MaskedNumber, card.Status, card.ReissuedFrom, card.CreatedAt).Scan(&card.ID)
 }
 
+// GetCardsByAccountID возвращает все карты счета, включая закрытые, в порядке выпуска
 func (r *CardRepository) GetCardsByAccountID(accountID uint) ([]models.Card, error) {
-    var cards []models.Card
-    query := `SELECT id, account_id, number, expiry, hmac, created_at FROM cards WHERE account_id=$1`
-    rows, err := r.DB.Query(query, accountID)
-    if err != nil {
-        return nil, err
-    }
-    defer rows.Close()
-
-    for rows.Next() {
-        var card models.Card
-        if err := rows.Scan(&card.ID, &card.AccountID, &card.Number, &card.Expiry, &card.HMAC, &card.CreatedAt); err != nil {
-            return nil, err
-        }
-        cards = append(cards, card)
-    }
-    return cards, nil
+	var cards []models.Card
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE account_id=$1 ORDER BY id`
+	rows, err := r.DB.Query(query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var card models.Card
+		if err := scanCard(rows, &card); err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
 }
 
 func (r *CardRepository) GetCardByID(cardID uint) (*models.Card, error) {
-    var card models.Card
-    query := `SELECT id, account_id, number, cvv, expiry, hmac, created_at FROM cards WHERE id=$1`
-    err := r.DB.QueryRow(query, cardID).Scan(&card.ID, &card.AccountID, &card.Number, &card.CVV, &card.Expiry, &card.HMAC, &card.CreatedAt)
-    if err != nil {
-        return nil, err
-    }
-    return &card, nil
-}
-
-func (r *CardRepository) DeleteCard(cardID uint) error {
-	query := `DELETE FROM cards WHERE id=$1`
-	_, err := r.DB.Exec(query, cardID)
-	return err
-}
\ No newline at end of file
+	var card models.Card
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE id=$1`
+	if err := scanCard(r.DB.QueryRow(query, cardID), &card); err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+// GetCardForUpdate возвращает карту и блокирует ее строку до конца транзакции
+func (r *CardRepository) GetCardForUpdate(cardID uint) (*models.Card, error) {
+	var card models.Card
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE id=$1 FOR UPDATE`
+	if err := scanCard(r.DB.QueryRow(query, cardID), &card); err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+// UpdateCardStatus меняет статус карты
+func (r *CardRepository) UpdateCardStatus(cardID uint, status string, changedAt time.Time) error {
+	query := `UPDATE cards SET status=$1, status_changed_at=$2 WHERE id=$3`
+	if _, err := r.DB.Exec(query, status, changedAt, cardID); err != nil {
+		return fmt.Errorf("failed to update card status: %v", err)
+	}
+	return nil
+}
+
+// ExpireCards переводит в статус expired действующие и заблокированные карты, срок действия которых
+// (последний день месяца MM/YY) истек к моменту now. Возвращает число таких карт
+func (r *CardRepository) ExpireCards(now time.Time) (int64, error) {
+	query := `UPDATE cards SET status=$1, status_changed_at=$2
+	          WHERE status IN ($3, $4) AND to_date(expiry, 'MM/YY') + INTERVAL '1 month' <= $2`
+	result, err := r.DB.Exec(query, models.CardExpired, now, models.CardActive, models.CardBlocked)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire cards: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetCardsByPANHash возвращает карты с указанным хешем номера
+func (r *CardRepository) GetCardsByPANHash(panHash string) ([]models.Card, error) {
+	var cards []models.Card
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE pan_hash=$1 ORDER BY id`
+	rows, err := r.DB.Query(query, panHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find card: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var card models.Card
+		if err := scanCard(rows, &card); err != nil {
+			return nil, fmt.Errorf("failed to scan card: %v", err)
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// GetCardsWithoutPANIndex возвращает карты, выпущенные до появления хеша или маскированного номера
+func (r *CardRepository) GetCardsWithoutPANIndex() ([]models.Card, error) {
+	var cards []models.Card
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE pan_hash IS NULL OR pan_masked IS NULL ORDER BY id`
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cards without pan index: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var card models.Card
+		if err := scanCard(rows, &card); err != nil {
+			return nil, fmt.Errorf("failed to scan card: %v", err)
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// SetPANIndex сохраняет хеш и маскированный номер карты
+func (r *CardRepository) SetPANIndex(cardID uint, panHash, maskedNumber string) error {
+	query := `UPDATE cards SET pan_hash=$1, pan_masked=$2 WHERE id=$3`
+	if _, err := r.DB.Exec(query, panHash, maskedNumber, cardID); err != nil {
+		return fmt.Errorf("failed to set pan index: %v", err)
+	}
+	return nil
+}
+
+// AddFailedVerification увеличивает счетчик неверных реквизитов карты подряд и возвращает его новое значение
+func (r *CardRepository) AddFailedVerification(cardID uint) (int, error) {
+	var failures int
+	query := `UPDATE cards SET failed_verifications = failed_verifications + 1 WHERE id=$1 RETURNING failed_verifications`
+	if err := r.DB.QueryRow(query, cardID).Scan(&failures); err != nil {
+		return 0, fmt.Errorf("failed to count failed card verification: %v", err)
+	}
+	return failures, nil
+}
+
+// ResetFailedVerifications обнуляет счетчик неверных реквизитов карты
+func (r *CardRepository) ResetFailedVerifications(cardID uint) error {
+	query := `UPDATE cards SET failed_verifications = 0 WHERE id=$1 AND failed_verifications > 0`
+	if _, err := r.DB.Exec(query, cardID); err != nil {
+		return fmt.Errorf("failed to reset failed card verifications: %v", err)
+	}
+	return nil
+}
+
+// GetCardLimits возвращает лимиты карты в валюте ее счета. Если лимиты не заданы, возвращаются пустые лимиты
+func (r *CardRepository) GetCardLimits(cardID uint) (*models.CardLimits, error) {
+	limits := models.CardLimits{CardID: cardID}
+	var perTransaction, daily, monthly models.Money
+	var allowed, blocked pq.StringArray
+	var updatedAt sql.NullTime
+	query := `SELECT a.currency, l.per_transaction_limit, l.daily_limit, l.monthly_limit,
+	                 COALESCE(l.allowed_mccs, '{}'), COALESCE(l.blocked_mccs, '{}'), l.updated_at
+	          FROM cards c
+	          JOIN accounts a ON a.id = c.account_id
+	          LEFT JOIN card_limits l ON l.card_id = c.id
+	          WHERE c.id=$1`
+	err := r.DB.QueryRow(query, cardID).Scan(&limits.Currency, &perTransaction, &daily, &monthly, &allowed, &blocked, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	limits.PerTransaction = limitOrNil(perTransaction, limits.Currency)
+	limits.Daily = limitOrNil(daily, limits.Currency)
+	limits.Monthly = limitOrNil(monthly, limits.Currency)
+	limits.AllowedMCCs = []string(allowed)
+	limits.BlockedMCCs = []string(blocked)
+	if updatedAt.Valid {
+		limits.UpdatedAt = &updatedAt.Time
+	}
+	return &limits, nil
+}
+
+// limitOrNil возвращает nil для незаданного (NULL) лимита
+func limitOrNil(limit models.Money, currency string) *models.Money {
+	if limit.IsZero() {
+		return nil
+	}
+	limit = limit.WithCurrency(currency)
+	return &limit
+}
+
+// SaveCardLimits сохраняет лимиты карты
+func (r *CardRepository) SaveCardLimits(limits *models.CardLimits) error {
+	query := `INSERT INTO card_limits (card_id, per_transaction_limit, daily_limit, monthly_limit, allowed_mccs, blocked_mccs, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          ON CONFLICT (card_id) DO UPDATE SET per_transaction_limit = EXCLUDED.per_transaction_limit,
+	              daily_limit = EXCLUDED.daily_limit, monthly_limit = EXCLUDED.monthly_limit,
+	              allowed_mccs = EXCLUDED.allowed_mccs, blocked_mccs = EXCLUDED.blocked_mccs,
+	              updated_at = EXCLUDED.updated_at`
+	_, err := r.DB.Exec(query, limits.CardID, limits.PerTransaction, limits.Daily, limits.Monthly,
+		pq.StringArray(limits.AllowedMCCs), pq.StringArray(limits.BlockedMCCs), limits.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save card limits: %v", err)
+	}
+	return nil
+}
+
+// CreateDecline сохраняет отказ в оплате картой. CardID = 0 — карта по переданным реквизитам не найдена
+func (r *CardRepository) CreateDecline(decline *models.CardDecline) error {
+	query := `INSERT INTO card_declines (card_id, merchant_id, amount, mcc, reason, created_at)
+	          VALUES (NULLIF($1, 0), $2, $3, NULLIF($4, ''), $5, $6) RETURNING id`
+	err := r.DB.QueryRow(query, decline.CardID, decline.MerchantID, decline.Amount, decline.MCC,
+		decline.Reason, decline.CreatedAt).Scan(&decline.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create card decline: %v", err)
+	}
+	return nil
+}
+
+// GetDeclinesByCardID возвращает отказы в оплате картой, начиная с последних
+func (r *CardRepository) GetDeclinesByCardID(cardID uint) ([]models.CardDecline, error) {
+	var declines []models.CardDecline
+	query := `SELECT d.id, d.card_id, COALESCE(d.merchant_id, 0), d.amount, a.currency, COALESCE(d.mcc, ''), d.reason, d.created_at
+	          FROM card_declines d
+	          JOIN cards c ON c.id = d.card_id
+	          JOIN accounts a ON a.id = c.account_id
+	          WHERE d.card_id=$1
+	          ORDER BY d.created_at DESC, d.id DESC`
+	rows, err := r.DB.Query(query, cardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card declines: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var decline models.CardDecline
+		var currency string
+		if err := rows.Scan(&decline.ID, &decline.CardID, &decline.MerchantID, &decline.Amount, &currency,
+			&decline.MCC, &decline.Reason, &decline.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan card decline: %v", err)
+		}
+		decline.Amount = decline.Amount.WithCurrency(currency)
+		declines = append(declines, decline)
+	}
+	return declines, nil
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type CardRevealRepository struct {
+	DB DBTX
+}
+
+func NewCardRevealRepository(db *sql.DB) *CardRevealRepository {
+	return &CardRevealRepository{DB: db}
+}
+
+// CreateEvent добавляет запись в журнал показа реквизитов карты
+func (r *CardRevealRepository) CreateEvent(event *models.CardRevealEvent) error {
+	query := `INSERT INTO card_reveal_audit (card_id, user_id, method, result, ip, user_agent, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err := r.DB.QueryRow(query, event.CardID, event.UserID, event.Method, event.Result, event.IP,
+		event.UserAgent, event.CreatedAt).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create card reveal event: %v", err)
+	}
+	return nil
+}
+
+// CountAttempts возвращает число попыток показа реквизитов карты пользователем с момента since,
+// включая запросы одноразовых кодов. Попытки, отклоненные ограничением частоты, не учитываются
+func (r *CardRevealRepository) CountAttempts(cardID, userID uint, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM card_reveal_audit
+	          WHERE card_id=$1 AND user_id=$2 AND created_at >= $3 AND result <> $4`
+	if err := r.DB.QueryRow(query, cardID, userID, since, models.CardRevealRateLimited).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count card reveal attempts: %v", err)
+	}
+	return count, nil
+}
+
+// GetEvents возвращает журнал показа реквизитов карты, начиная с последних записей
+func (r *CardRevealRepository) GetEvents(cardID uint) ([]models.CardRevealEvent, error) {
+	var events []models.CardRevealEvent
+	query := `SELECT id, card_id, COALESCE(user_id, 0), method, result, COALESCE(ip, ''), COALESCE(user_agent, ''), created_at
+	          FROM card_reveal_audit
+	          WHERE card_id=$1
+	          ORDER BY created_at DESC, id DESC`
+	rows, err := r.DB.Query(query, cardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card reveal events: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.CardRevealEvent
+		if err := rows.Scan(&event.ID, &event.CardID, &event.UserID, &event.Method, &event.Result, &event.IP,
+			&event.UserAgent, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan card reveal event: %v", err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// CreateOTP сохраняет одноразовый код. Ранее выданные неиспользованные коды карты для пользователя перестают действовать
+func (r *CardRevealRepository) CreateOTP(otp *models.CardRevealOTP) error {
+	invalidate := `UPDATE card_reveal_otps SET used_at=$1 WHERE card_id=$2 AND user_id=$3 AND used_at IS NULL`
+	if _, err := r.DB.Exec(invalidate, otp.CreatedAt, otp.CardID, otp.UserID); err != nil {
+		return fmt.Errorf("failed to invalidate card reveal codes: %v", err)
+	}
+
+	query := `INSERT INTO card_reveal_otps (card_id, user_id, code_hash, expires_at, created_at)
+	          VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err := r.DB.QueryRow(query, otp.CardID, otp.UserID, otp.CodeHash, otp.ExpiresAt, otp.CreatedAt).Scan(&otp.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create card reveal code: %v", err)
+	}
+	return nil
+}
+
+// GetActiveOTP возвращает действующий одноразовый код карты для пользователя или nil, если его нет
+func (r *CardRevealRepository) GetActiveOTP(cardID, userID uint, now time.Time) (*models.CardRevealOTP, error) {
+	var otp models.CardRevealOTP
+	query := `SELECT id, card_id, user_id, code_hash, expires_at, created_at
+	          FROM card_reveal_otps
+	          WHERE card_id=$1 AND user_id=$2 AND used_at IS NULL AND expires_at > $3
+	          ORDER BY created_at DESC, id DESC
+	          LIMIT 1`
+	err := r.DB.QueryRow(query, cardID, userID, now).Scan(&otp.ID, &otp.CardID, &otp.UserID, &otp.CodeHash,
+		&otp.ExpiresAt, &otp.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get card reveal code: %v", err)
+	}
+	return &otp, nil
+}
+
+// UseOTP отмечает одноразовый код использованным
+func (r *CardRevealRepository) UseOTP(otpID uint, usedAt time.Time) error {
+	query := `UPDATE card_reveal_otps SET used_at=$1 WHERE id=$2`
+	if _, err := r.DB.Exec(query, usedAt, otpID); err != nil {
+		return fmt.Errorf("failed to use card reveal code: %v", err)
+	}
+	return nil
+}
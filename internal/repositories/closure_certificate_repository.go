@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type ClosureCertificateRepository struct {
+	DB DBTX
+}
+
+func NewClosureCertificateRepository(db *sql.DB) *ClosureCertificateRepository {
+	return &ClosureCertificateRepository{DB: db}
+}
+
+const closureCertificateColumns = `id, credit_id, number, closed_at, total_paid, document, emailed_at, created_at`
+
+func scanClosureCertificate(row rowScanner, certificate *models.ClosureCertificate) error {
+	var emailedAt sql.NullTime
+	err := row.Scan(&certificate.ID, &certificate.CreditID, &certificate.Number, &certificate.ClosedAt, &certificate.TotalPaid,
+		&certificate.Document, &emailedAt, &certificate.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if emailedAt.Valid {
+		certificate.EmailedAt = &emailedAt.Time
+	}
+	return nil
+}
+
+func (r *ClosureCertificateRepository) CreateCertificate(certificate *models.ClosureCertificate) error {
+	query := `INSERT INTO credit_closure_certificates (credit_id, number, closed_at, total_paid, document, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err := r.DB.QueryRow(query, certificate.CreditID, certificate.Number, certificate.ClosedAt, certificate.TotalPaid,
+		certificate.Document, certificate.CreatedAt).Scan(&certificate.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create closure certificate: %v", err)
+	}
+	return nil
+}
+
+// GetCertificateByCreditID возвращает справку о погашении кредита или nil, если кредит не погашен
+func (r *ClosureCertificateRepository) GetCertificateByCreditID(creditID uint) (*models.ClosureCertificate, error) {
+	var certificate models.ClosureCertificate
+	query := `SELECT ` + closureCertificateColumns + `
+	          FROM credit_closure_certificates
+	          WHERE credit_id=$1`
+	err := scanClosureCertificate(r.DB.QueryRow(query, creditID), &certificate)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get closure certificate: %v", err)
+	}
+	return &certificate, nil
+}
+
+// MarkEmailed отмечает, что справка отправлена заемщику
+func (r *ClosureCertificateRepository) MarkEmailed(certificateID uint, emailedAt time.Time) error {
+	query := `UPDATE credit_closure_certificates SET emailed_at=$1 WHERE id=$2`
+	_, err := r.DB.Exec(query, emailedAt, certificateID)
+	if err != nil {
+		return fmt.Errorf("failed to mark closure certificate emailed: %v", err)
+	}
+	return nil
+}
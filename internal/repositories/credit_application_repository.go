@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type CreditApplicationRepository struct {
+	DB DBTX
+}
+
+func NewCreditApplicationRepository(db *sql.DB) *CreditApplicationRepository {
+	return &CreditApplicationRepository{DB: db}
+}
+
+const applicationColumns = `id, user_id, product_id, account_id, amount, term, schedule_type, status,
+	score, debt_to_income, reject_reason, credit_id, created_at, updated_at`
+
+func scanApplication(row rowScanner, application *models.CreditApplication) error {
+	var score, creditID sql.NullInt64
+	var debtToIncome sql.NullFloat64
+	var rejectReason sql.NullString
+	err := row.Scan(&application.ID, &application.UserID, &application.ProductID, &application.AccountID,
+		&application.Amount, &application.Term, &application.ScheduleType, &application.Status,
+		&score, &debtToIncome, &rejectReason, &creditID, &application.CreatedAt, &application.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if score.Valid {
+		value := int(score.Int64)
+		application.Score = &value
+	}
+	if debtToIncome.Valid {
+		application.DebtToIncome = &debtToIncome.Float64
+	}
+	application.RejectReason = rejectReason.String
+	if creditID.Valid {
+		id := uint(creditID.Int64)
+		application.CreditID = &id
+	}
+	return nil
+}
+
+func (r *CreditApplicationRepository) CreateApplication(application *models.CreditApplication) error {
+	query := `INSERT INTO credit_applications (user_id, product_id, account_id, amount, term, schedule_type, status, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+	err := r.DB.QueryRow(query, application.UserID, application.ProductID, application.AccountID, application.Amount,
+		application.Term, application.ScheduleType, application.Status, application.CreatedAt, application.UpdatedAt).Scan(&application.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create credit application: %v", err)
+	}
+	return nil
+}
+
+// GetApplicationByID возвращает заявку или nil, если она не найдена
+func (r *CreditApplicationRepository) GetApplicationByID(applicationID uint) (*models.CreditApplication, error) {
+	query := `SELECT ` + applicationColumns + ` FROM credit_applications WHERE id=$1`
+	return r.getApplication(query, applicationID)
+}
+
+// GetApplicationForUpdate возвращает заявку с блокировкой строки до конца транзакции,
+// чтобы по одной заявке нельзя было выдать два кредита
+func (r *CreditApplicationRepository) GetApplicationForUpdate(applicationID uint) (*models.CreditApplication, error) {
+	query := `SELECT ` + applicationColumns + ` FROM credit_applications WHERE id=$1 FOR UPDATE`
+	return r.getApplication(query, applicationID)
+}
+
+func (r *CreditApplicationRepository) getApplication(query string, applicationID uint) (*models.CreditApplication, error) {
+	var application models.CreditApplication
+	err := scanApplication(r.DB.QueryRow(query, applicationID), &application)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get credit application: %v", err)
+	}
+	return &application, nil
+}
+
+func (r *CreditApplicationRepository) GetApplicationsByUserID(userID uint) ([]models.CreditApplication, error) {
+	var applications []models.CreditApplication
+	query := `SELECT ` + applicationColumns + `
+	          FROM credit_applications
+	          WHERE user_id=$1
+	          ORDER BY created_at DESC`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credit applications: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var application models.CreditApplication
+		if err := scanApplication(rows, &application); err != nil {
+			return nil, fmt.Errorf("failed to scan credit application: %v", err)
+		}
+		applications = append(applications, application)
+	}
+	return applications, nil
+}
+
+// GetApplicationsByStatus возвращает заявки в статусе status в порядке подачи
+func (r *CreditApplicationRepository) GetApplicationsByStatus(status string) ([]models.CreditApplication, error) {
+	var applications []models.CreditApplication
+	query := `SELECT ` + applicationColumns + `
+	          FROM credit_applications
+	          WHERE status=$1
+	          ORDER BY created_at`
+	rows, err := r.DB.Query(query, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credit applications: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var application models.CreditApplication
+		if err := scanApplication(rows, &application); err != nil {
+			return nil, fmt.Errorf("failed to scan credit application: %v", err)
+		}
+		applications = append(applications, application)
+	}
+	return applications, nil
+}
+
+// TransitionStatus переводит заявку из статуса from в статус to.
+// Возвращает false, если заявка уже находится в другом статусе
+func (r *CreditApplicationRepository) TransitionStatus(applicationID uint, from, to string) (bool, error) {
+	query := `UPDATE credit_applications SET status=$1, updated_at=$2 WHERE id=$3 AND status=$4`
+	result, err := r.DB.Exec(query, to, time.Now(), applicationID, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update credit application status: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SaveDecision сохраняет результат оценки и переводит заявку из статуса "scoring" в одобренную или отклоненную
+func (r *CreditApplicationRepository) SaveDecision(application *models.CreditApplication) error {
+	application.UpdatedAt = time.Now()
+	query := `UPDATE credit_applications SET status=$1, score=$2, debt_to_income=$3, reject_reason=$4, updated_at=$5
+	          WHERE id=$6 AND status=$7`
+	_, err := r.DB.Exec(query, application.Status, application.Score, application.DebtToIncome,
+		sql.NullString{String: application.RejectReason, Valid: application.RejectReason != ""},
+		application.UpdatedAt, application.ID, models.ApplicationScoring)
+	if err != nil {
+		return fmt.Errorf("failed to save credit application decision: %v", err)
+	}
+	return nil
+}
+
+// MarkIssued связывает одобренную заявку с выданным кредитом
+func (r *CreditApplicationRepository) MarkIssued(applicationID, creditID uint) error {
+	query := `UPDATE credit_applications SET status=$1, credit_id=$2, updated_at=$3 WHERE id=$4 AND status=$5`
+	result, err := r.DB.Exec(query, models.ApplicationIssued, creditID, time.Now(), applicationID, models.ApplicationApproved)
+	if err != nil {
+		return fmt.Errorf("failed to mark credit application as issued: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to mark credit application as issued: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("credit application %d is not approved", applicationID)
+	}
+	return nil
+}
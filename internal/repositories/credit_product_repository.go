@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type CreditProductRepository struct {
+	DB DBTX
+}
+
+func NewCreditProductRepository(db *sql.DB) *CreditProductRepository {
+	return &CreditProductRepository{DB: db}
+}
+
+// GetActiveProducts возвращает доступные для оформления продукты вместе с таблицами спредов
+func (r *CreditProductRepository) GetActiveProducts() ([]models.CreditProduct, error) {
+	var products []models.CreditProduct
+	query := `SELECT id, code, name, min_amount, max_amount, terms, issue_fee_percent, issue_fee_fixed, is_active, created_at
+	          FROM credit_products
+	          WHERE is_active
+	          ORDER BY id`
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credit products: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product models.CreditProduct
+		if err := scanCreditProduct(rows, &product); err != nil {
+			return nil, fmt.Errorf("failed to scan credit product: %v", err)
+		}
+		products = append(products, product)
+	}
+	rows.Close()
+
+	for i := range products {
+		if products[i].Spreads, err = r.getSpreads(products[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return products, nil
+}
+
+// GetProductByID возвращает продукт с таблицей спредов или nil, если продукт не найден
+func (r *CreditProductRepository) GetProductByID(productID uint) (*models.CreditProduct, error) {
+	var product models.CreditProduct
+	query := `SELECT id, code, name, min_amount, max_amount, terms, issue_fee_percent, issue_fee_fixed, is_active, created_at
+	          FROM credit_products
+	          WHERE id=$1`
+	err := scanCreditProduct(r.DB.QueryRow(query, productID), &product)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get credit product: %v", err)
+	}
+
+	if product.Spreads, err = r.getSpreads(product.ID); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// GetProductByCode возвращает продукт по коду или nil, если продукт не найден
+func (r *CreditProductRepository) GetProductByCode(code string) (*models.CreditProduct, error) {
+	var productID uint
+	err := r.DB.QueryRow(`SELECT id FROM credit_products WHERE code=$1`, code).Scan(&productID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get credit product: %v", err)
+	}
+	return r.GetProductByID(productID)
+}
+
+func (r *CreditProductRepository) getSpreads(productID uint) ([]models.CreditProductSpread, error) {
+	var spreads []models.CreditProductSpread
+	query := `SELECT id, product_id, min_term, max_term, min_amount, max_amount, spread
+	          FROM credit_product_spreads
+	          WHERE product_id=$1
+	          ORDER BY min_term, min_amount`
+	rows, err := r.DB.Query(query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credit product spreads: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var spread models.CreditProductSpread
+		if err := rows.Scan(&spread.ID, &spread.ProductID, &spread.MinTerm, &spread.MaxTerm, &spread.MinAmount, &spread.MaxAmount, &spread.Spread); err != nil {
+			return nil, fmt.Errorf("failed to scan credit product spread: %v", err)
+		}
+		spreads = append(spreads, spread)
+	}
+	return spreads, nil
+}
+
+func scanCreditProduct(row rowScanner, product *models.CreditProduct) error {
+	var terms pq.Int64Array
+	err := row.Scan(&product.ID, &product.Code, &product.Name, &product.MinAmount, &product.MaxAmount, &terms,
+		&product.IssueFeePercent, &product.IssueFeeFixed, &product.IsActive, &product.CreatedAt)
+	if err != nil {
+		return err
+	}
+	product.Terms = make([]int, len(terms))
+	for i, term := range terms {
+		product.Terms[i] = int(term)
+	}
+	return nil
+}
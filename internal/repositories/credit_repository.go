@@ -3,63 +3,96 @@ package repositories
 import (
 	"database/sql"
 	"fmt"
-	"math"
+	"math/big"
 	"time"
 
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
 )
 
 type CreditRepository struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func NewCreditRepository(db *sql.DB) *CreditRepository {
 	return &CreditRepository{DB: db}
 }
 
-func (r *CreditRepository) CreateCredit(credit *models.Credit) error {
-	tx, err := r.DB.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %v", err)
-	}
-	defer tx.Rollback()
+const creditColumns = `id, user_id, product_id, amount, interest_rate, term, schedule_type, status, fee, account_id, repayment_account_id,
+	principal_outstanding, interest_outstanding, penalties_outstanding, fees_outstanding, created_at`
 
-	// Создание записи о кредите
-	query := `INSERT INTO credits (user_id, amount, interest_rate, term, created_at)
-	          VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	err = tx.QueryRow(query, credit.UserID, credit.Amount, credit.InterestRate, credit.Term, credit.CreatedAt).Scan(&credit.ID)
+func scanCredit(row rowScanner, credit *models.Credit) error {
+	var productID, accountID, repaymentAccountID sql.NullInt64
+	err := row.Scan(&credit.ID, &credit.UserID, &productID, &credit.Amount, &credit.InterestRate, &credit.Term, &credit.ScheduleType, &credit.Status, &credit.Fee, &accountID, &repaymentAccountID,
+		&credit.Outstanding.Principal, &credit.Outstanding.Interest, &credit.Outstanding.Penalties, &credit.Outstanding.Fees, &credit.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to create credit: %v", err)
+		return err
+	}
+	if productID.Valid {
+		id := uint(productID.Int64)
+		credit.ProductID = &id
 	}
+	if accountID.Valid {
+		id := uint(accountID.Int64)
+		credit.AccountID = &id
+	}
+	if repaymentAccountID.Valid {
+		id := uint(repaymentAccountID.Int64)
+		credit.RepaymentAccountID = &id
+	}
+	return nil
+}
+
+func (r *CreditRepository) CreateCredit(credit *models.Credit) error {
+	return inTx(r.DB, func(q DBTX) error {
+		// Создание записи о кредите
+		if credit.ScheduleType == "" {
+			credit.ScheduleType = models.ScheduleAnnuity
+		}
+		if credit.Status == "" {
+			credit.Status = models.CreditActive
+		}
+		// Вся задолженность нового кредита — основной долг
+		credit.Outstanding = models.CreditBalance{Principal: credit.Amount}
+		query := `INSERT INTO credits (user_id, product_id, amount, interest_rate, term, schedule_type, status, fee,
+		          account_id, repayment_account_id, principal_outstanding, created_at)
+		          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
+		err := q.QueryRow(query, credit.UserID, credit.ProductID, credit.Amount, credit.InterestRate, credit.Term,
+			credit.ScheduleType, credit.Status, credit.Fee, credit.AccountID, credit.RepaymentAccountID,
+			credit.Outstanding.Principal, credit.CreatedAt).Scan(&credit.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create credit: %v", err)
+		}
+
+		// Создание графика платежей и платежей со статусом "pending"
+		return insertSchedule(q, generatePaymentSchedule(credit))
+	})
+}
 
-	// Создание графика платежей
-	schedule := generatePaymentSchedule(credit)
-	for _, payment := range schedule {
-		query = `INSERT INTO payment_schedules (credit_id, due_date, amount, is_paid, created_at)
-		          VALUES ($1, $2, $3, $4, $5)`
-		_, err = tx.Exec(query, credit.ID, payment.DueDate, payment.Amount, payment.IsPaid, payment.CreatedAt)
+// insertSchedule сохраняет строки графика и для каждой создает ожидающий платеж
+func insertSchedule(q DBTX, schedule []models.PaymentSchedule) error {
+	for i := range schedule {
+		row := &schedule[i]
+		query := `INSERT INTO payment_schedules (credit_id, due_date, amount, principal, interest, remaining_balance, is_paid, created_at)
+		          VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+		err := q.QueryRow(query, row.CreditID, row.DueDate, row.Amount, row.Principal, row.Interest,
+			row.RemainingBalance, row.IsPaid, row.CreatedAt).Scan(&row.ID)
 		if err != nil {
 			return fmt.Errorf("failed to create payment schedule: %v", err)
 		}
-	}
 
-	// Создание платежей со статусом "pending"
-	for _, payment := range schedule {
-		query = `INSERT INTO payments (credit_id, amount, payment_date, status, created_at)
-		          VALUES ($1, $2, $3, $4, $5)`
-		_, err = tx.Exec(query, credit.ID, payment.Amount, payment.DueDate, "pending", time.Now())
+		query = `INSERT INTO payments (credit_id, schedule_id, type, amount, payment_date, status, created_at)
+		          VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		_, err = q.Exec(query, row.CreditID, row.ID, models.PaymentScheduled, row.Amount, row.DueDate, "pending", time.Now())
 		if err != nil {
 			return fmt.Errorf("failed to create pending payment: %v", err)
 		}
 	}
-
-	return tx.Commit()
+	return nil
 }
 
-
 func (r *CreditRepository) GetCreditsByUserID(userID uint) ([]models.Credit, error) {
 	var credits []models.Credit
-	query := `SELECT id, user_id, amount, interest_rate, term, created_at
+	query := `SELECT ` + creditColumns + `
 	          FROM credits
 	          WHERE user_id=$1
 	          ORDER BY created_at DESC`
@@ -71,7 +104,7 @@ func (r *CreditRepository) GetCreditsByUserID(userID uint) ([]models.Credit, err
 
 	for rows.Next() {
 		var credit models.Credit
-		if err := rows.Scan(&credit.ID, &credit.UserID, &credit.Amount, &credit.InterestRate, &credit.Term, &credit.CreatedAt); err != nil {
+		if err := scanCredit(rows, &credit); err != nil {
 			return nil, fmt.Errorf("failed to scan credit: %v", err)
 		}
 		credits = append(credits, credit)
@@ -81,19 +114,269 @@ func (r *CreditRepository) GetCreditsByUserID(userID uint) ([]models.Credit, err
 
 func (r *CreditRepository) GetCreditByID(creditID uint) (*models.Credit, error) {
 	var credit models.Credit
-	query := `SELECT id, user_id, amount, interest_rate, term, created_at
+	query := `SELECT ` + creditColumns + `
 	          FROM credits
 	          WHERE id=$1`
-	err := r.DB.QueryRow(query, creditID).Scan(&credit.ID, &credit.UserID, &credit.Amount, &credit.InterestRate, &credit.Term, &credit.CreatedAt)
+	err := scanCredit(r.DB.QueryRow(query, creditID), &credit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get credit: %v", err)
 	}
 	return &credit, nil
 }
 
+// GetCreditForUpdate читает кредит с блокировкой строки до конца транзакции.
+// Если кредита нет, возвращается sql.ErrNoRows
+func (r *CreditRepository) GetCreditForUpdate(creditID uint) (*models.Credit, error) {
+	var credit models.Credit
+	query := `SELECT ` + creditColumns + `
+	          FROM credits
+	          WHERE id=$1
+	          FOR UPDATE`
+	err := scanCredit(r.DB.QueryRow(query, creditID), &credit)
+	if err == sql.ErrNoRows {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get credit: %v", err)
+	}
+	return &credit, nil
+}
+
+// AdjustBalance изменяет составляющие задолженности по кредиту на delta, общая задолженность amount
+// меняется на сумму изменений (отрицательные значения — погашение)
+func (r *CreditRepository) AdjustBalance(creditID uint, delta models.CreditBalance) error {
+	query := `UPDATE credits SET
+	          principal_outstanding = principal_outstanding + $1,
+	          interest_outstanding = interest_outstanding + $2,
+	          penalties_outstanding = penalties_outstanding + $3,
+	          fees_outstanding = fees_outstanding + $4,
+	          amount = amount + $5
+	          WHERE id = $6`
+	_, err := r.DB.Exec(query, delta.Principal, delta.Interest, delta.Penalties, delta.Fees, delta.Total(), creditID)
+	if err != nil {
+		return fmt.Errorf("failed to update credit balance: %v", err)
+	}
+	return nil
+}
+
+// MarkInterestAccrued отмечает, что проценты по строке графика включены в задолженность.
+// Возвращает false, если проценты уже были начислены
+func (r *CreditRepository) MarkInterestAccrued(scheduleID uint) (bool, error) {
+	query := `UPDATE payment_schedules SET interest_accrued=TRUE WHERE id=$1 AND NOT interest_accrued`
+	result, err := r.DB.Exec(query, scheduleID)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark interest accrued: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetDueUnaccruedSchedule возвращает наступившие непогашенные строки графика активных кредитов,
+// проценты по которым еще не начислены
+func (r *CreditRepository) GetDueUnaccruedSchedule() ([]models.PaymentSchedule, error) {
+	var schedule []models.PaymentSchedule
+	query := `SELECT ` + scheduleColumns + `
+	          FROM payment_schedules
+	          WHERE due_date <= NOW() AND NOT is_paid AND NOT interest_accrued
+	          AND credit_id IN (SELECT id FROM credits WHERE status=$1)
+	          ORDER BY credit_id, due_date`
+	rows, err := r.DB.Query(query, models.CreditActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due payment schedule: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.PaymentSchedule
+		if err := scanSchedule(rows, &row); err != nil {
+			return nil, fmt.Errorf("failed to scan payment schedule: %v", err)
+		}
+		schedule = append(schedule, row)
+	}
+	return schedule, nil
+}
+
+// SetRepaymentAccount привязывает к кредиту счет для автоматического списания платежей (nil — отвязать)
+func (r *CreditRepository) SetRepaymentAccount(creditID uint, accountID *uint) error {
+	query := `UPDATE credits SET repayment_account_id=$1 WHERE id=$2`
+	_, err := r.DB.Exec(query, accountID, creditID)
+	if err != nil {
+		return fmt.Errorf("failed to set repayment account: %v", err)
+	}
+	return nil
+}
+
+// CloseCredit переводит кредит в статус "closed"
+func (r *CreditRepository) CloseCredit(creditID uint) error {
+	query := `UPDATE credits SET status=$1 WHERE id=$2`
+	_, err := r.DB.Exec(query, models.CreditClosed, creditID)
+	if err != nil {
+		return fmt.Errorf("failed to close credit: %v", err)
+	}
+	return nil
+}
+
+// DeleteUnpaidSchedule удаляет непогашенные строки графика вместе с ожидающими по ним платежами
+func (r *CreditRepository) DeleteUnpaidSchedule(creditID uint) error {
+	query := `DELETE FROM payments
+	          WHERE status='pending' AND schedule_id IN (SELECT id FROM payment_schedules WHERE credit_id=$1 AND NOT is_paid)`
+	if _, err := r.DB.Exec(query, creditID); err != nil {
+		return fmt.Errorf("failed to delete pending payments: %v", err)
+	}
+	query = `DELETE FROM payment_schedules WHERE credit_id=$1 AND NOT is_paid`
+	if _, err := r.DB.Exec(query, creditID); err != nil {
+		return fmt.Errorf("failed to delete unpaid schedule: %v", err)
+	}
+	return nil
+}
+
+// RescheduleUnpaid заменяет непогашенную часть графика новой, построенной от остатка основного долга principal.
+// При reduce_term сохраняется размер платежа (для дифференцированного графика — доля основного долга)
+// и сокращается число платежей, при reduce_payment сохраняется число платежей и уменьшается платеж.
+// Даты платежей продолжают прежний график
+func (r *CreditRepository) RescheduleUnpaid(credit *models.Credit, principal models.Money, mode string) error {
+	return inTx(r.DB, func(q DBTX) error {
+		repo := &CreditRepository{DB: q}
+		schedule, err := repo.GetPaymentSchedule(credit.ID)
+		if err != nil {
+			return err
+		}
+
+		var paidCount int
+		var unpaid []models.PaymentSchedule
+		for _, row := range schedule {
+			if row.IsPaid {
+				paidCount++
+			} else {
+				unpaid = append(unpaid, row)
+			}
+		}
+		rows, err := rescheduleRows(credit, unpaid, principal, mode)
+		if err != nil {
+			return err
+		}
+
+		if err := repo.DeleteUnpaidSchedule(credit.ID); err != nil {
+			return err
+		}
+		if err := insertSchedule(q, rows); err != nil {
+			return err
+		}
+
+		credit.Term = paidCount + len(rows)
+		if _, err := q.Exec(`UPDATE credits SET term=$1 WHERE id=$2`, credit.Term, credit.ID); err != nil {
+			return fmt.Errorf("failed to update credit term: %v", err)
+		}
+		return nil
+	})
+}
+
+// RestructureSchedule заменяет будущие строки графика (срок платежа не наступил) новыми: остаток основного долга
+// по ним распределяется на extendMonths платежей больше по ставке credit.InterestRate, а первый платеж
+// переносится на graceMonths месяцев. Проценты за месяцы каникул не начисляются. Наступившие и просроченные
+// строки не меняются. Ставка и срок кредита обновляются, возвращаются новые строки графика
+func (r *CreditRepository) RestructureSchedule(credit *models.Credit, graceMonths, extendMonths int, now time.Time) ([]models.PaymentSchedule, error) {
+	var rows []models.PaymentSchedule
+	err := inTx(r.DB, func(q DBTX) error {
+		repo := &CreditRepository{DB: q}
+		schedule, err := repo.GetPaymentSchedule(credit.ID)
+		if err != nil {
+			return err
+		}
+
+		var kept int
+		rows, kept, err = restructureRows(credit, schedule, graceMonths, extendMonths, now)
+		if err != nil {
+			return err
+		}
+
+		query := `DELETE FROM payments
+		          WHERE status='pending' AND schedule_id IN (SELECT id FROM payment_schedules
+		          WHERE credit_id=$1 AND NOT is_paid AND NOT interest_accrued AND due_date > $2)`
+		if _, err := q.Exec(query, credit.ID, now); err != nil {
+			return fmt.Errorf("failed to delete pending payments: %v", err)
+		}
+		query = `DELETE FROM payment_schedules WHERE credit_id=$1 AND NOT is_paid AND NOT interest_accrued AND due_date > $2`
+		if _, err := q.Exec(query, credit.ID, now); err != nil {
+			return fmt.Errorf("failed to delete future schedule: %v", err)
+		}
+		if err := insertSchedule(q, rows); err != nil {
+			return err
+		}
+
+		credit.Term = kept + len(rows)
+		if _, err := q.Exec(`UPDATE credits SET term=$1, interest_rate=$2 WHERE id=$3`, credit.Term, credit.InterestRate, credit.ID); err != nil {
+			return fmt.Errorf("failed to update credit terms: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// restructureRows строит будущие строки графика по правилам RestructureSchedule.
+// kept — число строк schedule, которые остаются без изменений
+func restructureRows(credit *models.Credit, schedule []models.PaymentSchedule, graceMonths, extendMonths int, now time.Time) (rows []models.PaymentSchedule, kept int, err error) {
+	var future []models.PaymentSchedule
+	principal := models.Money{}
+	for _, row := range schedule {
+		if row.IsPaid || row.InterestAccrued || !row.DueDate.After(now) {
+			kept++
+			continue
+		}
+		future = append(future, row)
+		principal = principal.Add(row.Principal)
+	}
+	if len(future) == 0 {
+		return nil, 0, fmt.Errorf("credit %d has no future installments", credit.ID)
+	}
+
+	rows = amortize(credit, principal, future[0].DueDate.AddDate(0, graceMonths, 0), len(future)+extendMonths, models.Money{})
+	return rows, kept, nil
+}
+
+// rescheduleRows строит новую непогашенную часть графика от остатка основного долга principal
+// по правилам RescheduleUnpaid, при reduce_term число платежей не превышает прежнее. unpaid — текущие непогашенные строки графика по возрастанию даты
+func rescheduleRows(credit *models.Credit, unpaid []models.PaymentSchedule, principal models.Money, mode string) ([]models.PaymentSchedule, error) {
+	if len(unpaid) == 0 {
+		return nil, fmt.Errorf("credit %d has no unpaid installments", credit.ID)
+	}
+
+	switch mode {
+	case models.EarlyRepaymentReduceTerm:
+		installment := unpaid[0].Amount
+		if credit.ScheduleType == models.ScheduleDifferentiated {
+			installment = unpaid[0].Principal
+		}
+		rows := amortize(credit, principal, unpaid[0].DueDate, 0, installment)
+		// Последний платеж прежнего графика включает копейки округления, поэтому при небольшом
+		// досрочном погашении прежним платежом долг может не погаситься за оставшийся срок.
+		// Срок при этом не увеличивается: график строится на прежнее число платежей
+		if len(rows) > len(unpaid) {
+			rows = amortize(credit, principal, unpaid[0].DueDate, len(unpaid), models.Money{})
+		}
+		return rows, nil
+	case models.EarlyRepaymentReducePayment:
+		return amortize(credit, principal, unpaid[0].DueDate, len(unpaid), models.Money{}), nil
+	}
+	return nil, fmt.Errorf("unknown early repayment mode: %s", mode)
+}
+
+const scheduleColumns =`id, credit_id, due_date, amount, COALESCE(principal, 0), COALESCE(interest, 0),
+	COALESCE(remaining_balance, 0), is_paid, interest_accrued, created_at`
+
+func scanSchedule(row rowScanner, payment *models.PaymentSchedule) error {
+	return row.Scan(&payment.ID, &payment.CreditID, &payment.DueDate, &payment.Amount, &payment.Principal,
+		&payment.Interest, &payment.RemainingBalance, &payment.IsPaid, &payment.InterestAccrued, &payment.CreatedAt)
+}
+
 func (r *CreditRepository) GetPaymentSchedule(creditID uint) ([]models.PaymentSchedule, error) {
 	var schedule []models.PaymentSchedule
-	query := `SELECT id, credit_id, due_date, amount, is_paid, created_at
+	query := `SELECT ` + scheduleColumns + `
 	          FROM payment_schedules
 	          WHERE credit_id=$1
 	          ORDER BY due_date ASC`
@@ -105,7 +388,7 @@ func (r *CreditRepository) GetPaymentSchedule(creditID uint) ([]models.PaymentSc
 
 	for rows.Next() {
 		var payment models.PaymentSchedule
-		if err := rows.Scan(&payment.ID, &payment.CreditID, &payment.DueDate, &payment.Amount, &payment.IsPaid, &payment.CreatedAt); err != nil {
+		if err := scanSchedule(rows, &payment); err != nil {
 			return nil, fmt.Errorf("failed to scan payment schedule: %v", err)
 		}
 		schedule = append(schedule, payment)
@@ -113,25 +396,94 @@ func (r *CreditRepository) GetPaymentSchedule(creditID uint) ([]models.PaymentSc
 	return schedule, nil
 }
 
+// generatePaymentSchedule строит график платежей по типу графика кредита с первым платежом через месяц
+// PreviewPaymentSchedule рассчитывает график платежей кредита без сохранения,
+// например для оценки ежемесячной нагрузки по заявке
+func PreviewPaymentSchedule(credit *models.Credit) []models.PaymentSchedule {
+	return generatePaymentSchedule(credit)
+}
+
 func generatePaymentSchedule(credit *models.Credit) []models.PaymentSchedule {
+	return amortize(credit, credit.Amount, time.Now().AddDate(0, 1, 0), credit.Term, models.Money{})
+}
+
+// amortize строит ежемесячный график погашения principal начиная с даты firstDue.
+// Если term > 0, график состоит из term платежей, размер которых рассчитывается по типу графика.
+// Иначе используется заданный installment (аннуитетный платеж или доля основного долга),
+// а число платежей определяется тем, когда будет погашен долг.
+// Остаток долга ведется в копейках, последний платеж гасит его полностью, чтобы график закрывал кредит без расхождений
+func amortize(credit *models.Credit, principal models.Money, firstDue time.Time, term int, installment models.Money) []models.PaymentSchedule {
 	var schedule []models.PaymentSchedule
-	monthlyPayment := calculateMonthlyPayment(credit.Amount, credit.InterestRate, credit.Term)
-	for i := 0; i < credit.Term; i++ {
-		dueDate := time.Now().AddDate(0, i+1, 0)
+	monthlyRate := monthlyInterestRate(credit.InterestRate)
+	if term > 0 {
+		installment = calculateMonthlyPayment(principal, credit.InterestRate, term)
+		if credit.ScheduleType == models.ScheduleDifferentiated {
+			installment = calculatePrincipalPart(principal, term)
+		}
+	}
+
+	remaining := principal
+	for i := 0; remaining.IsPositive(); i++ {
+		interest := remaining.MulRat(monthlyRate, models.DefaultRounding)
+
+		principalPart := installment.Sub(interest)
+		if credit.ScheduleType == models.ScheduleDifferentiated {
+			principalPart = installment
+		}
+		// Последний платеж гасит остаток целиком. Платеж, не покрывающий проценты, также закрывает долг,
+		// чтобы график не стал бесконечным
+		if (term > 0 && i == term-1) || !principalPart.LessThan(remaining) || !principalPart.IsPositive() {
+			principalPart = remaining
+		}
+		remaining = remaining.Sub(principalPart)
+
 		schedule = append(schedule, models.PaymentSchedule{
-			CreditID:  credit.ID,
-			DueDate:   dueDate,
-			Amount:    monthlyPayment,
-			IsPaid:    false,
-			CreatedAt: time.Now(),
+			CreditID:         credit.ID,
+			DueDate:          firstDue.AddDate(0, i, 0),
+			Amount:           principalPart.Add(interest),
+			Principal:        principalPart,
+			Interest:         interest,
+			RemainingBalance: remaining,
+			IsPaid:           false,
+			CreatedAt:        time.Now(),
 		})
 	}
 	return schedule
 }
 
-func calculateMonthlyPayment(amount, interestRate float64, term int) float64 {
-	monthlyRate := interestRate / 12 / 100
-	return amount * monthlyRate * math.Pow(1+monthlyRate, float64(term)) / (math.Pow(1+monthlyRate, float64(term)) - 1)
+// calculatePrincipalPart вычисляет ежемесячную долю основного долга для дифференцированного графика
+func calculatePrincipalPart(amount models.Money, term int) models.Money {
+	if term <= 0 {
+		return amount
+	}
+	return amount.DivInt(int64(term), models.DefaultRounding)
+}
+
+// monthlyInterestRate переводит годовую ставку в процентах в месячную долю
+func monthlyInterestRate(interestRate float64) *big.Rat {
+	return new(big.Rat).Quo(models.DecimalRat(interestRate), big.NewRat(12*100, 1))
+}
+
+// calculateMonthlyPayment вычисляет аннуитетный платеж A = P * r * (1+r)^n / ((1+r)^n - 1).
+// Расчет ведется в рациональных числах, округление до копеек — только у итоговой суммы
+func calculateMonthlyPayment(amount models.Money, interestRate float64, term int) models.Money {
+	if term <= 0 {
+		return amount
+	}
+	monthlyRate := monthlyInterestRate(interestRate)
+	if monthlyRate.Sign() == 0 {
+		return amount.DivInt(int64(term), models.DefaultRounding)
+	}
+
+	growth := new(big.Rat).Add(big.NewRat(1, 1), monthlyRate)
+	factor := big.NewRat(1, 1)
+	for i := 0; i < term; i++ {
+		factor.Mul(factor, growth)
+	}
+
+	numerator := new(big.Rat).Mul(monthlyRate, factor)
+	denominator := new(big.Rat).Sub(factor, big.NewRat(1, 1))
+	return amount.MulRat(numerator.Quo(numerator, denominator), models.DefaultRounding)
 }
 
 func (r *CreditRepository) UpdatePaymentScheduleStatus(paymentID uint, isPaid bool) error {
@@ -0,0 +1,265 @@
+package repositories
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+var testFirstDue = time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+// checkSchedule проверяет общие свойства графика: основной долг по строкам в сумме равен principal,
+// остаток после каждого платежа убывает и после последнего равен нулю, платеж равен сумме долей
+func checkSchedule(t *testing.T, schedule []models.PaymentSchedule, principal models.Money) {
+	t.Helper()
+	if len(schedule) == 0 {
+		t.Fatal("schedule is empty")
+	}
+
+	total := models.Money{}
+	remaining := principal
+	for i, row := range schedule {
+		total = total.Add(row.Principal)
+		remaining = remaining.Sub(row.Principal)
+		if row.RemainingBalance != remaining {
+			t.Errorf("row %d: remaining balance = %s, want %s", i, row.RemainingBalance, remaining)
+		}
+		if !row.Principal.IsPositive() || row.Interest.IsNegative() {
+			t.Errorf("row %d: principal = %s, interest = %s", i, row.Principal, row.Interest)
+		}
+		if row.Amount != row.Principal.Add(row.Interest) {
+			t.Errorf("row %d: amount %s != principal %s + interest %s", i, row.Amount, row.Principal, row.Interest)
+		}
+	}
+	if total != principal {
+		t.Errorf("principal sum = %s, want %s", total, principal)
+	}
+	if last := schedule[len(schedule)-1].RemainingBalance; !last.IsZero() {
+		t.Errorf("last remaining balance = %s, want 0", last)
+	}
+}
+
+func TestAmortize(t *testing.T) {
+	tests := []struct {
+		amount int64
+		rate   float64
+		term   int
+	}{
+		{amount: 100_000_00, rate: 12, term: 12},
+		{amount: 100_000_00, rate: 0, term: 12},
+		{amount: 1_000_000_01, rate: 19.9, term: 7},
+		{amount: 5_000_000_00, rate: 8.5, term: 360},
+		{amount: 1_00, rate: 25, term: 3},
+		{amount: 50_000_00, rate: 15, term: 1},
+	}
+	for _, scheduleType := range []string{models.ScheduleAnnuity, models.ScheduleDifferentiated} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/%d/%v/%d", scheduleType, tt.amount, tt.rate, tt.term), func(t *testing.T) {
+				credit := &models.Credit{ID: 7, InterestRate: tt.rate, ScheduleType: scheduleType}
+				principal := models.NewMoney(tt.amount, models.DefaultCurrency)
+
+				schedule := amortize(credit, principal, testFirstDue, tt.term, models.Money{})
+
+				if len(schedule) != tt.term {
+					t.Fatalf("schedule has %d rows, want %d", len(schedule), tt.term)
+				}
+				checkSchedule(t, schedule, principal)
+				for i, row := range schedule {
+					if want := testFirstDue.AddDate(0, i, 0); !row.DueDate.Equal(want) {
+						t.Errorf("row %d: due date = %s, want %s", i, row.DueDate.Format("2006-01-02"), want.Format("2006-01-02"))
+					}
+					if row.CreditID != credit.ID {
+						t.Errorf("row %d: credit id = %d, want %d", i, row.CreditID, credit.ID)
+					}
+				}
+
+				// Все платежи, кроме последнего, одинаковы: аннуитетный платеж или доля основного долга
+				for i := 1; i < len(schedule)-1; i++ {
+					if scheduleType == models.ScheduleAnnuity && schedule[i].Amount != schedule[0].Amount {
+						t.Errorf("row %d: annuity payment = %s, want %s", i, schedule[i].Amount, schedule[0].Amount)
+					}
+					if scheduleType == models.ScheduleDifferentiated && schedule[i].Principal != schedule[0].Principal {
+						t.Errorf("row %d: principal part = %s, want %s", i, schedule[i].Principal, schedule[0].Principal)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCalculateMonthlyPayment(t *testing.T) {
+	tests := []struct {
+		amount int64
+		rate   float64
+		term   int
+		want   int64
+	}{
+		// 100 000 под 12% на год: 8 884.88 в месяц
+		{amount: 100_000_00, rate: 12, term: 12, want: 8_884_88},
+		{amount: 100_000_00, rate: 0, term: 12, want: 8_333_33},
+		{amount: 100_000_00, rate: 12, term: 1, want: 101_000_00},
+		{amount: 100_000_00, rate: 12, term: 0, want: 100_000_00},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v/%d", tt.rate, tt.term), func(t *testing.T) {
+			got := calculateMonthlyPayment(models.NewMoney(tt.amount, models.DefaultCurrency), tt.rate, tt.term)
+			if got.Minor() != tt.want {
+				t.Errorf("calculateMonthlyPayment = %s, want %d minor units", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRescheduleRows(t *testing.T) {
+	for _, scheduleType := range []string{models.ScheduleAnnuity, models.ScheduleDifferentiated} {
+		t.Run(scheduleType, func(t *testing.T) {
+			credit := &models.Credit{ID: 7, InterestRate: 12, ScheduleType: scheduleType}
+			schedule := amortize(credit, models.NewMoney(120_000_00, models.DefaultCurrency), testFirstDue, 12, models.Money{})
+			// Три платежа внесены, после них досрочно погашено 30 000
+			unpaid := schedule[3:]
+			principal := schedule[2].RemainingBalance.Sub(models.NewMoney(30_000_00, models.DefaultCurrency))
+			installment := func(row models.PaymentSchedule) models.Money {
+				if scheduleType == models.ScheduleDifferentiated {
+					return row.Principal
+				}
+				return row.Amount
+			}
+
+			t.Run("reduce term", func(t *testing.T) {
+				rows, err := rescheduleRows(credit, unpaid, principal, models.EarlyRepaymentReduceTerm)
+				if err != nil {
+					t.Fatalf("rescheduleRows error: %v", err)
+				}
+				checkSchedule(t, rows, principal)
+				if len(rows) >= len(unpaid) {
+					t.Fatalf("schedule has %d rows, want fewer than %d", len(rows), len(unpaid))
+				}
+				if !rows[0].DueDate.Equal(unpaid[0].DueDate) {
+					t.Errorf("first due date = %s, want %s", rows[0].DueDate, unpaid[0].DueDate)
+				}
+				// Размер платежа сохраняется, последний платеж не больше прежнего
+				for i, row := range rows[:len(rows)-1] {
+					if installment(row) != installment(unpaid[0]) {
+						t.Errorf("row %d: installment = %s, want %s", i, installment(row), installment(unpaid[0]))
+					}
+				}
+				if last := rows[len(rows)-1]; last.Principal.GreaterThan(installment(unpaid[0])) {
+					t.Errorf("last principal part %s exceeds installment %s", last.Principal, installment(unpaid[0]))
+				}
+			})
+
+			t.Run("reduce payment", func(t *testing.T) {
+				rows, err := rescheduleRows(credit, unpaid, principal, models.EarlyRepaymentReducePayment)
+				if err != nil {
+					t.Fatalf("rescheduleRows error: %v", err)
+				}
+				checkSchedule(t, rows, principal)
+				// Число платежей и даты сохраняются, платеж уменьшается
+				if len(rows) != len(unpaid) {
+					t.Fatalf("schedule has %d rows, want %d", len(rows), len(unpaid))
+				}
+				for i := range rows {
+					if !rows[i].DueDate.Equal(unpaid[i].DueDate) {
+						t.Errorf("row %d: due date = %s, want %s", i, rows[i].DueDate, unpaid[i].DueDate)
+					}
+				}
+				if !installment(rows[0]).LessThan(installment(unpaid[0])) {
+					t.Errorf("installment = %s, want less than %s", installment(rows[0]), installment(unpaid[0]))
+				}
+			})
+
+			t.Run("reduce term never extends the term", func(t *testing.T) {
+				// Копейки округления в последнем платеже прежнего графика не должны давать лишний месяц
+				for _, prepaid := range []int64{0, 1, 2} {
+					reduced := schedule[2].RemainingBalance.Sub(models.NewMoney(prepaid, models.DefaultCurrency))
+					rows, err := rescheduleRows(credit, unpaid, reduced, models.EarlyRepaymentReduceTerm)
+					if err != nil {
+						t.Fatalf("rescheduleRows error: %v", err)
+					}
+					checkSchedule(t, rows, reduced)
+					if len(rows) > len(unpaid) {
+						t.Errorf("prepaid %d: schedule has %d rows, want at most %d", prepaid, len(rows), len(unpaid))
+					}
+				}
+			})
+		})
+	}
+
+	credit := &models.Credit{ID: 7, InterestRate: 12, ScheduleType: models.ScheduleAnnuity}
+	principal := models.NewMoney(1_000_00, models.DefaultCurrency)
+	if _, err := rescheduleRows(credit, nil, principal, models.EarlyRepaymentReduceTerm); err == nil {
+		t.Error("rescheduleRows without unpaid installments must fail")
+	}
+	unpaid := amortize(credit, principal, testFirstDue, 3, models.Money{})
+	if _, err := rescheduleRows(credit, unpaid, principal, "reduce_everything"); err == nil {
+		t.Error("rescheduleRows with unknown mode must fail")
+	}
+}
+
+func TestRestructureRows(t *testing.T) {
+	principal := models.NewMoney(120_000_00, models.DefaultCurrency)
+	// Прошли сроки трех платежей, второй из них не внесен и по нему начислены проценты
+	now := testFirstDue.AddDate(0, 2, 10)
+
+	tests := []struct {
+		name         string
+		scheduleType string
+		graceMonths  int
+		extendMonths int
+		rate         float64 // ставка после реструктуризации
+		wantRows     int
+	}{
+		{name: "grace", scheduleType: models.ScheduleAnnuity, graceMonths: 3, rate: 12, wantRows: 8},
+		{name: "extend", scheduleType: models.ScheduleAnnuity, extendMonths: 6, rate: 12, wantRows: 14},
+		{name: "grace and extend", scheduleType: models.ScheduleDifferentiated, graceMonths: 2, extendMonths: 12, rate: 12, wantRows: 20},
+		{name: "new rate", scheduleType: models.ScheduleAnnuity, rate: 6, wantRows: 8},
+		{name: "zero rate", scheduleType: models.ScheduleDifferentiated, extendMonths: 3, rate: 0, wantRows: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credit := &models.Credit{ID: 7, InterestRate: 12, ScheduleType: tt.scheduleType}
+			schedule := amortize(credit, principal, testFirstDue, 12, models.Money{})
+			schedule[0].IsPaid = true
+			schedule[1].InterestAccrued = true
+			// Строка с будущей датой, проценты по которой уже начислены, тоже не меняется
+			schedule[3].InterestAccrued = true
+			credit.InterestRate = tt.rate
+
+			rows, kept, err := restructureRows(credit, schedule, tt.graceMonths, tt.extendMonths, now)
+			if err != nil {
+				t.Fatalf("restructureRows error: %v", err)
+			}
+			if kept != 4 {
+				t.Errorf("kept = %d, want 4", kept)
+			}
+			if len(rows) != tt.wantRows {
+				t.Fatalf("schedule has %d rows, want %d", len(rows), tt.wantRows)
+			}
+
+			// Перестраивается основной долг будущих строк, и он гасится полностью
+			future := models.Money{}
+			for _, row := range schedule[4:] {
+				future = future.Add(row.Principal)
+			}
+			checkSchedule(t, rows, future)
+
+			// Первый платеж переносится на срок каникул, проценты за каникулы не начисляются
+			if want := schedule[4].DueDate.AddDate(0, tt.graceMonths, 0); !rows[0].DueDate.Equal(want) {
+				t.Errorf("first due date = %s, want %s", rows[0].DueDate.Format("2006-01-02"), want.Format("2006-01-02"))
+			}
+			wantInterest := future.MulRat(monthlyInterestRate(credit.InterestRate), models.DefaultRounding)
+			if rows[0].Interest != wantInterest {
+				t.Errorf("first interest = %s, want %s at %v%%", rows[0].Interest, wantInterest, credit.InterestRate)
+			}
+		})
+	}
+
+	// Все строки в прошлом: реструктурировать нечего
+	credit := &models.Credit{ID: 7, InterestRate: 12, ScheduleType: models.ScheduleAnnuity}
+	schedule := amortize(credit, principal, testFirstDue, 3, models.Money{})
+	if _, _, err := restructureRows(credit, schedule, 1, 0, testFirstDue.AddDate(0, 3, 0)); err == nil {
+		t.Error("restructureRows without future installments must fail")
+	}
+}
@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type CreditRestructuringRepository struct {
+	DB DBTX
+}
+
+func NewCreditRestructuringRepository(db *sql.DB) *CreditRestructuringRepository {
+	return &CreditRestructuringRepository{DB: db}
+}
+
+// CreateRestructuring сохраняет реструктуризацию и архивирует действовавший до нее график restructuring.PreviousSchedule.
+// Номер версии назначается следующим за последней реструктуризацией кредита: первый график имеет версию 1
+func (r *CreditRestructuringRepository) CreateRestructuring(restructuring *models.CreditRestructuring) error {
+	return inTx(r.DB, func(q DBTX) error {
+		query := `INSERT INTO credit_restructurings (credit_id, operator_id, version, grace_months, extend_months,
+		          old_interest_rate, new_interest_rate, old_term, new_term, reason, created_at)
+		          VALUES ($1, $2, (SELECT COALESCE(MAX(version), 0) + 1 FROM credit_restructurings WHERE credit_id=$1),
+		          $3, $4, $5, $6, $7, $8, $9, $10)
+		          RETURNING id, version`
+		err := q.QueryRow(query, restructuring.CreditID, restructuring.OperatorID, restructuring.GraceMonths,
+			restructuring.ExtendMonths, restructuring.OldInterestRate, restructuring.NewInterestRate,
+			restructuring.OldTerm, restructuring.NewTerm, restructuring.Reason, restructuring.CreatedAt).
+			Scan(&restructuring.ID, &restructuring.Version)
+		if err != nil {
+			return fmt.Errorf("failed to create credit restructuring: %v", err)
+		}
+
+		for _, row := range restructuring.PreviousSchedule {
+			query = `INSERT INTO payment_schedule_versions (restructuring_id, schedule_id, due_date, amount, principal,
+			          interest, remaining_balance, is_paid)
+			          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+			_, err := q.Exec(query, restructuring.ID, row.ID, row.DueDate, row.Amount, row.Principal,
+				row.Interest, row.RemainingBalance, row.IsPaid)
+			if err != nil {
+				return fmt.Errorf("failed to archive payment schedule: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetRestructuringsByCreditID возвращает реструктуризации кредита вместе с архивными графиками в порядке версий
+func (r *CreditRestructuringRepository) GetRestructuringsByCreditID(creditID uint) ([]models.CreditRestructuring, error) {
+	var restructurings []models.CreditRestructuring
+	query := `SELECT id, credit_id, operator_id, version, grace_months, extend_months, old_interest_rate,
+	          new_interest_rate, old_term, new_term, COALESCE(reason, ''), created_at
+	          FROM credit_restructurings
+	          WHERE credit_id=$1
+	          ORDER BY version`
+	rows, err := r.DB.Query(query, creditID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credit restructurings: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var restructuring models.CreditRestructuring
+		var operatorID sql.NullInt64
+		err := rows.Scan(&restructuring.ID, &restructuring.CreditID, &operatorID, &restructuring.Version,
+			&restructuring.GraceMonths, &restructuring.ExtendMonths, &restructuring.OldInterestRate,
+			&restructuring.NewInterestRate, &restructuring.OldTerm, &restructuring.NewTerm,
+			&restructuring.Reason, &restructuring.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan credit restructuring: %v", err)
+		}
+		if operatorID.Valid {
+			id := uint(operatorID.Int64)
+			restructuring.OperatorID = &id
+		}
+		restructurings = append(restructurings, restructuring)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get credit restructurings: %v", err)
+	}
+
+	for i := range restructurings {
+		schedule, err := r.getScheduleVersion(restructurings[i].ID, creditID)
+		if err != nil {
+			return nil, err
+		}
+		restructurings[i].PreviousSchedule = schedule
+	}
+	return restructurings, nil
+}
+
+// getScheduleVersion возвращает график, архивированный при реструктуризации
+func (r *CreditRestructuringRepository) getScheduleVersion(restructuringID, creditID uint) ([]models.PaymentSchedule, error) {
+	var schedule []models.PaymentSchedule
+	query := `SELECT COALESCE(schedule_id, 0), due_date, amount, COALESCE(principal, 0), COALESCE(interest, 0),
+	          COALESCE(remaining_balance, 0), is_paid
+	          FROM payment_schedule_versions
+	          WHERE restructuring_id=$1
+	          ORDER BY due_date, id`
+	rows, err := r.DB.Query(query, restructuringID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment schedule version: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := models.PaymentSchedule{CreditID: creditID}
+		err := rows.Scan(&row.ID, &row.DueDate, &row.Amount, &row.Principal, &row.Interest, &row.RemainingBalance, &row.IsPaid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment schedule version: %v", err)
+		}
+		schedule = append(schedule, row)
+	}
+	return schedule, nil
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type FXQuoteRepository struct {
+	DB DBTX
+}
+
+func NewFXQuoteRepository(db *sql.DB) *FXQuoteRepository {
+	return &FXQuoteRepository{DB: db}
+}
+
+const fxQuoteColumns = `id, user_id, from_account, to_account, amount, from_currency, to_amount, to_currency,
+	          market_rate, rate, spread_percent, status, expires_at, executed_at, created_at`
+
+func (r *FXQuoteRepository) CreateQuote(quote *models.FXQuote) error {
+	query := `INSERT INTO fx_quotes (user_id, from_account, to_account, amount, from_currency, to_amount, to_currency,
+	          market_rate, rate, spread_percent, status, expires_at, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id`
+	err := r.DB.QueryRow(query, quote.UserID, quote.FromAccount, quote.ToAccount, quote.Amount, quote.FromCurrency,
+		quote.ToAmount, quote.ToCurrency, quote.MarketRate, quote.Rate, quote.SpreadPercent, quote.Status,
+		quote.ExpiresAt, quote.CreatedAt).Scan(&quote.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create fx quote: %v", err)
+	}
+	return nil
+}
+
+// GetQuoteByID возвращает котировку или nil, если она не найдена
+func (r *FXQuoteRepository) GetQuoteByID(quoteID uint) (*models.FXQuote, error) {
+	query := `SELECT ` + fxQuoteColumns + ` FROM fx_quotes WHERE id=$1`
+	return r.getQuote(query, quoteID)
+}
+
+// GetQuoteForUpdate возвращает котировку с блокировкой строки до конца транзакции,
+// чтобы одну котировку нельзя было исполнить дважды
+func (r *FXQuoteRepository) GetQuoteForUpdate(quoteID uint) (*models.FXQuote, error) {
+	query := `SELECT ` + fxQuoteColumns + ` FROM fx_quotes WHERE id=$1 FOR UPDATE`
+	return r.getQuote(query, quoteID)
+}
+
+func (r *FXQuoteRepository) getQuote(query string, quoteID uint) (*models.FXQuote, error) {
+	var quote models.FXQuote
+	var executedAt sql.NullTime
+	err := r.DB.QueryRow(query, quoteID).Scan(&quote.ID, &quote.UserID, &quote.FromAccount, &quote.ToAccount,
+		&quote.Amount, &quote.FromCurrency, &quote.ToAmount, &quote.ToCurrency, &quote.MarketRate, &quote.Rate,
+		&quote.SpreadPercent, &quote.Status, &quote.ExpiresAt, &executedAt, &quote.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get fx quote: %v", err)
+	}
+	if executedAt.Valid {
+		quote.ExecutedAt = &executedAt.Time
+	}
+	quote.Amount = quote.Amount.WithCurrency(quote.FromCurrency)
+	quote.ToAmount = quote.ToAmount.WithCurrency(quote.ToCurrency)
+	return &quote, nil
+}
+
+// MarkExecuted помечает активную котировку исполненной
+func (r *FXQuoteRepository) MarkExecuted(quoteID uint, executedAt time.Time) error {
+	query := `UPDATE fx_quotes SET status=$1, executed_at=$2 WHERE id=$3 AND status=$4`
+	result, err := r.DB.Exec(query, models.FXQuoteExecuted, executedAt, quoteID, models.FXQuoteActive)
+	if err != nil {
+		return fmt.Errorf("failed to mark fx quote as executed: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to mark fx quote as executed: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("fx quote %d is not active", quoteID)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type HoldRepository struct {
+	DB DBTX
+}
+
+func NewHoldRepository(db *sql.DB) *HoldRepository {
+	return &HoldRepository{DB: db}
+}
+
+// CreateHold блокирует сумму на счете. Остаток счета должен быть проверен вызывающим под блокировкой счета
+func (r *HoldRepository) CreateHold(hold *models.Hold) error {
+	hold.Status = models.HoldActive
+	query := `INSERT INTO account_holds (account_id, amount, currency, source, description, reference_id, status, expires_at, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+	err := r.DB.QueryRow(query, hold.AccountID, hold.Amount, hold.Currency, hold.Source, hold.Description,
+		hold.ReferenceID, hold.Status, hold.ExpiresAt, hold.CreatedAt).Scan(&hold.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create hold: %v", err)
+	}
+	return nil
+}
+
+// SetHoldReference привязывает блокировку к операции, под которую она создана
+func (r *HoldRepository) SetHoldReference(holdID, referenceID uint) error {
+	query := `UPDATE account_holds SET reference_id=$1 WHERE id=$2`
+	if _, err := r.DB.Exec(query, referenceID, holdID); err != nil {
+		return fmt.Errorf("failed to update hold: %v", err)
+	}
+	return nil
+}
+
+// FinishHold снимает действующую блокировку со статусом status (captured, released или expired)
+func (r *HoldRepository) FinishHold(holdID uint, status string, finishedAt time.Time) error {
+	query := `UPDATE account_holds SET status=$1, released_at=$2 WHERE id=$3 AND status=$4`
+	if _, err := r.DB.Exec(query, status, finishedAt, holdID, models.HoldActive); err != nil {
+		return fmt.Errorf("failed to release hold: %v", err)
+	}
+	return nil
+}
+
+const holdColumns = `id, account_id, amount, currency, source, COALESCE(description, ''), reference_id, status, expires_at, released_at, created_at`
+
+func scanHold(row rowScanner, hold *models.Hold) error {
+	var referenceID sql.NullInt64
+	var releasedAt sql.NullTime
+	err := row.Scan(&hold.ID, &hold.AccountID, &hold.Amount, &hold.Currency, &hold.Source, &hold.Description,
+		&referenceID, &hold.Status, &hold.ExpiresAt, &releasedAt, &hold.CreatedAt)
+	if err != nil {
+		return err
+	}
+	hold.Amount = hold.Amount.WithCurrency(hold.Currency)
+	if referenceID.Valid {
+		id := uint(referenceID.Int64)
+		hold.ReferenceID = &id
+	}
+	if releasedAt.Valid {
+		hold.ReleasedAt = &releasedAt.Time
+	}
+	return nil
+}
+
+// GetHoldsByAccountID возвращает блокировки счета, начиная с последних
+func (r *HoldRepository) GetHoldsByAccountID(accountID uint) ([]models.Hold, error) {
+	var holds []models.Hold
+	query := `SELECT ` + holdColumns + ` FROM account_holds WHERE account_id=$1 ORDER BY created_at DESC, id DESC`
+	rows, err := r.DB.Query(query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account holds: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hold models.Hold
+		if err := scanHold(rows, &hold); err != nil {
+			return nil, fmt.Errorf("failed to scan hold: %v", err)
+		}
+		holds = append(holds, hold)
+	}
+	return holds, rows.Err()
+}
+
+// GetHoldForUpdate возвращает блокировку с блокировкой строки до конца транзакции или nil, если ее нет
+func (r *HoldRepository) GetHoldForUpdate(holdID uint) (*models.Hold, error) {
+	var hold models.Hold
+	query := `SELECT ` + holdColumns + ` FROM account_holds WHERE id=$1 FOR UPDATE`
+	err := scanHold(r.DB.QueryRow(query, holdID), &hold)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get hold: %v", err)
+	}
+	return &hold, nil
+}
+
+// ExpireHolds отмечает истекшими действующие блокировки, срок которых наступил к now, и возвращает их число.
+// Истекшая блокировка перестает уменьшать доступный остаток сразу по наступлении срока, задача лишь обновляет статус
+func (r *HoldRepository) ExpireHolds(now time.Time) (int64, error) {
+	query := `UPDATE account_holds SET status=$1, released_at=$2 WHERE status=$3 AND expires_at <= $2`
+	result, err := r.DB.Exec(query, models.HoldExpired, now, models.HoldActive)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire holds: %v", err)
+	}
+	return result.RowsAffected()
+}
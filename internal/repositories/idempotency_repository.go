@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type IdempotencyRepository struct {
+	DB DBTX
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{DB: db}
+}
+
+// CreateKey резервирует ключ идемпотентности за пользователем.
+// Возвращает false, если такой ключ уже существует
+func (r *IdempotencyRepository) CreateKey(key *models.IdempotencyKey) (bool, error) {
+	query := `INSERT INTO idempotency_keys (user_id, key, method, path, request_hash, locked_until, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          ON CONFLICT (user_id, key) DO NOTHING
+	          RETURNING id`
+	err := r.DB.QueryRow(query, key.UserID, key.Key, key.Method, key.Path, key.RequestHash, key.LockedUntil, key.CreatedAt).Scan(&key.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to create idempotency key: %v", err)
+	}
+	return true, nil
+}
+
+func (r *IdempotencyRepository) GetKey(userID uint, key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	var statusCode sql.NullInt64
+	var contentType sql.NullString
+	var lockedUntil sql.NullTime
+	query := `SELECT id, user_id, key, method, path, request_hash, status_code, content_type, response_body, locked_until, created_at
+	          FROM idempotency_keys
+	          WHERE user_id=$1 AND key=$2`
+	err := r.DB.QueryRow(query, userID, key).Scan(&record.ID, &record.UserID, &record.Key, &record.Method, &record.Path,
+		&record.RequestHash, &statusCode, &contentType, &record.ResponseBody, &lockedUntil, &record.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %v", err)
+	}
+	record.StatusCode = int(statusCode.Int64)
+	record.ContentType = contentType.String
+	// Ключи, созданные до появления аренды, считаются арендованными до момента создания
+	record.LockedUntil = record.CreatedAt
+	if lockedUntil.Valid {
+		record.LockedUntil = lockedUntil.Time
+	}
+	return &record, nil
+}
+
+// ReclaimKey занимает ключ keyID, аренда которого истекла к моменту now, а ответ так и не был сохранен
+// (процесс, обрабатывавший запрос, завершился аварийно). Условие проверяется в самом UPDATE,
+// поэтому из нескольких параллельных повторов ключ займет только один. Возвращает false, если ключ занят
+func (r *IdempotencyRepository) ReclaimKey(keyID uint, lockedUntil, now time.Time) (bool, error) {
+	query := `UPDATE idempotency_keys SET locked_until=$1
+	          WHERE id=$2 AND status_code IS NULL AND COALESCE(locked_until, created_at) < $3`
+	result, err := r.DB.Exec(query, lockedUntil, keyID, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to reclaim idempotency key: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to reclaim idempotency key: %v", err)
+	}
+	return rows == 1, nil
+}
+
+// SaveResponse сохраняет ответ на исходный запрос для повторной выдачи
+func (r *IdempotencyRepository) SaveResponse(keyID uint, statusCode int, contentType string, body []byte) error {
+	query := `UPDATE idempotency_keys SET status_code=$1, content_type=$2, response_body=$3 WHERE id=$4`
+	_, err := r.DB.Exec(query, statusCode, contentType, body, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to save idempotent response: %v", err)
+	}
+	return nil
+}
+
+// DeleteKey освобождает ключ, чтобы запрос можно было повторить
+func (r *IdempotencyRepository) DeleteKey(keyID uint) error {
+	query := `DELETE FROM idempotency_keys WHERE id=$1`
+	_, err := r.DB.Exec(query, keyID)
+	return err
+}
@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type JobRunRepository struct {
+	DB DBTX
+}
+
+func NewJobRunRepository(db *sql.DB) *JobRunRepository {
+	return &JobRunRepository{DB: db}
+}
+
+const jobRunColumns = `id, job_name, triggered_by, status, COALESCE(instance, ''), started_at, finished_at, duration_ms, COALESCE(error, '')`
+
+func scanJobRun(row rowScanner, run *models.JobRun) error {
+	var finishedAt sql.NullTime
+	var durationMs sql.NullInt64
+	err := row.Scan(&run.ID, &run.JobName, &run.TriggeredBy, &run.Status, &run.Instance, &run.StartedAt, &finishedAt, &durationMs, &run.Error)
+	if err != nil {
+		return err
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	if durationMs.Valid {
+		run.DurationMs = &durationMs.Int64
+	}
+	return nil
+}
+
+// StartRun сохраняет начало запуска задачи со статусом "running"
+func (r *JobRunRepository) StartRun(run *models.JobRun) error {
+	run.Status = models.JobRunRunning
+	query := `INSERT INTO job_runs (job_name, triggered_by, status, instance, started_at)
+	          VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err := r.DB.QueryRow(query, run.JobName, run.TriggeredBy, run.Status, run.Instance, run.StartedAt).Scan(&run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to start job run: %v", err)
+	}
+	return nil
+}
+
+// FinishRun сохраняет результат запуска: статус, время окончания, длительность и ошибку
+func (r *JobRunRepository) FinishRun(run *models.JobRun) error {
+	query := `UPDATE job_runs SET status=$1, finished_at=$2, duration_ms=$3, error=NULLIF($4, '') WHERE id=$5`
+	_, err := r.DB.Exec(query, run.Status, run.FinishedAt, run.DurationMs, run.Error, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to finish job run: %v", err)
+	}
+	return nil
+}
+
+// FailInterruptedRuns отмечает неудачными запуски задачи, оставшиеся в статусе "running" после остановки
+// экземпляра. Вызывается только под блокировкой задачи, когда других ее запусков нет
+func (r *JobRunRepository) FailInterruptedRuns(jobName string, finishedAt time.Time) error {
+	query := `UPDATE job_runs SET status=$1, finished_at=$2, error='interrupted'
+	          WHERE job_name=$3 AND status=$4`
+	_, err := r.DB.Exec(query, models.JobRunFailed, finishedAt, jobName, models.JobRunRunning)
+	if err != nil {
+		return fmt.Errorf("failed to mark interrupted job runs: %v", err)
+	}
+	return nil
+}
+
+// GetLastRun возвращает последний запуск задачи способом triggeredBy (любым, если пусто) или nil
+func (r *JobRunRepository) GetLastRun(jobName, triggeredBy string) (*models.JobRun, error) {
+	var run models.JobRun
+	query := `SELECT ` + jobRunColumns + `
+	          FROM job_runs
+	          WHERE job_name=$1 AND ($2 = '' OR triggered_by=$2)
+	          ORDER BY started_at DESC, id DESC
+	          LIMIT 1`
+	err := scanJobRun(r.DB.QueryRow(query, jobName, triggeredBy), &run)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get last job run: %v", err)
+	}
+	return &run, nil
+}
+
+// GetRuns возвращает последние limit запусков задачи, начиная с последнего
+func (r *JobRunRepository) GetRuns(jobName string, limit int) ([]models.JobRun, error) {
+	var runs []models.JobRun
+	query := `SELECT ` + jobRunColumns + `
+	          FROM job_runs
+	          WHERE job_name=$1
+	          ORDER BY started_at DESC, id DESC
+	          LIMIT $2`
+	rows, err := r.DB.Query(query, jobName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job runs: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run models.JobRun
+		if err := scanJobRun(rows, &run); err != nil {
+			return nil, fmt.Errorf("failed to scan job run: %v", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
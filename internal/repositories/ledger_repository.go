@@ -0,0 +1,190 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type LedgerRepository struct {
+	DB DBTX
+}
+
+func NewLedgerRepository(db *sql.DB) *LedgerRepository {
+	return &LedgerRepository{DB: db}
+}
+
+// PostEntry сохраняет запись журнала вместе с проводками.
+// Запись отклоняется, если по какой-либо валюте дебет не равен кредиту
+func (r *LedgerRepository) PostEntry(entry *models.JournalEntry) error {
+	if len(entry.Postings) == 0 {
+		return fmt.Errorf("journal entry has no postings")
+	}
+
+	// Проверяем баланс проводок по каждой валюте
+	totals := make(map[string]models.Money)
+	for i := range entry.Postings {
+		posting := &entry.Postings[i]
+		if posting.Currency == "" {
+			posting.Currency = models.DefaultCurrency
+		}
+		if !posting.Amount.IsPositive() {
+			return fmt.Errorf("posting amount must be positive")
+		}
+		switch posting.Direction {
+		case models.DirectionDebit:
+			totals[posting.Currency] = totals[posting.Currency].Add(posting.Amount)
+		case models.DirectionCredit:
+			totals[posting.Currency] = totals[posting.Currency].Sub(posting.Amount)
+		default:
+			return fmt.Errorf("invalid posting direction: %s", posting.Direction)
+		}
+	}
+	for currency, total := range totals {
+		if !total.IsZero() {
+			return fmt.Errorf("unbalanced journal entry in %s: debit and credit differ by %s", currency, total)
+		}
+	}
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	return inTx(r.DB, func(q DBTX) error {
+		query := `INSERT INTO journal_entries (type, reference_id, description, created_at)
+		          VALUES ($1, $2, $3, $4) RETURNING id`
+		err := q.QueryRow(query, entry.Type, entry.ReferenceID, entry.Description, entry.CreatedAt).Scan(&entry.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create journal entry: %v", err)
+		}
+
+		for i := range entry.Postings {
+			posting := &entry.Postings[i]
+			posting.EntryID = entry.ID
+			posting.CreatedAt = entry.CreatedAt
+			query = `INSERT INTO postings (entry_id, ledger_account, account_id, credit_id, direction, amount, currency, created_at)
+			          VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+			err = q.QueryRow(query, posting.EntryID, posting.LedgerAccount, posting.AccountID, posting.CreditID,
+				posting.Direction, posting.Amount, posting.Currency, posting.CreatedAt).Scan(&posting.ID)
+			if err != nil {
+				return fmt.Errorf("failed to create posting: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetAccountHistory возвращает движения по счету клиента, начиная с последних
+func (r *LedgerRepository) GetAccountHistory(accountID uint) ([]models.AccountHistoryItem, error) {
+	var history []models.AccountHistoryItem
+	query := `SELECT e.id, e.type, e.reference_id, COALESCE(e.description, ''), p.direction, p.amount, p.currency, p.created_at
+	          FROM postings p
+	          JOIN journal_entries e ON e.id = p.entry_id
+	          WHERE p.ledger_account = $1 AND p.account_id = $2
+	          ORDER BY p.created_at DESC, p.id DESC`
+	rows, err := r.DB.Query(query, models.LedgerCustomer, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account history: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.AccountHistoryItem
+		if err := rows.Scan(&item.EntryID, &item.Type, &item.ReferenceID, &item.Description, &item.Direction, &item.Amount, &item.Currency, &item.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan account history: %v", err)
+		}
+		item.Amount = item.Amount.WithCurrency(item.Currency)
+		history = append(history, item)
+	}
+	return history, nil
+}
+
+// GetAccountBalanceMismatches находит счета клиентов, остаток которых не совпадает с проводками.
+// Для счета клиента остаток равен сумме кредитовых проводок за вычетом дебетовых
+func (r *LedgerRepository) GetAccountBalanceMismatches() ([]models.BalanceMismatch, error) {
+	query := `SELECT a.id, a.balance, COALESCE(SUM(CASE p.direction WHEN 'credit' THEN p.amount ELSE -p.amount END), 0) AS ledger_balance
+	          FROM accounts a
+	          LEFT JOIN postings p ON p.ledger_account = $1 AND p.account_id = a.id
+	          GROUP BY a.id, a.balance
+	          HAVING a.balance <> COALESCE(SUM(CASE p.direction WHEN 'credit' THEN p.amount ELSE -p.amount END), 0)`
+	return r.queryMismatches(models.LedgerCustomer, query)
+}
+
+// GetCreditBalanceMismatches находит кредиты, задолженность по которым не совпадает с проводками.
+// Для ссудного счета остаток равен сумме дебетовых проводок за вычетом кредитовых
+func (r *LedgerRepository) GetCreditBalanceMismatches() ([]models.BalanceMismatch, error) {
+	query := `SELECT c.id, c.amount, COALESCE(SUM(CASE p.direction WHEN 'debit' THEN p.amount ELSE -p.amount END), 0) AS ledger_balance
+	          FROM credits c
+	          LEFT JOIN postings p ON p.ledger_account = $1 AND p.credit_id = c.id
+	          GROUP BY c.id, c.amount
+	          HAVING c.amount <> COALESCE(SUM(CASE p.direction WHEN 'debit' THEN p.amount ELSE -p.amount END), 0)`
+	return r.queryMismatches(models.LedgerLoan, query)
+}
+
+func (r *LedgerRepository) queryMismatches(ledgerAccount, query string) ([]models.BalanceMismatch, error) {
+	var mismatches []models.BalanceMismatch
+	rows, err := r.DB.Query(query, ledgerAccount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile %s balances: %v", ledgerAccount, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		mismatch := models.BalanceMismatch{LedgerAccount: ledgerAccount}
+		if err := rows.Scan(&mismatch.ID, &mismatch.Stored, &mismatch.Ledger); err != nil {
+			return nil, fmt.Errorf("failed to scan balance mismatch: %v", err)
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	return mismatches, nil
+}
+
+// GetAccountsWithoutPostings возвращает счета с ненулевым остатком, по которым еще нет проводок
+// (созданные до ведения главной книги)
+func (r *LedgerRepository) GetAccountsWithoutPostings() ([]models.Account, error) {
+	var accounts []models.Account
+	query := `SELECT id, user_id, balance, currency, created_at
+	          FROM accounts a
+	          WHERE balance <> 0
+	          AND NOT EXISTS (SELECT 1 FROM postings p WHERE p.ledger_account = $1 AND p.account_id = a.id)`
+	rows, err := r.DB.Query(query, models.LedgerCustomer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts without postings: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var account models.Account
+		if err := rows.Scan(&account.ID, &account.UserID, &account.Balance, &account.Currency, &account.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan account: %v", err)
+		}
+		account.Balance = account.Balance.WithCurrency(account.Currency)
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+// GetCreditsWithoutPostings возвращает кредиты с ненулевой задолженностью, по которым еще нет проводок
+func (r *LedgerRepository) GetCreditsWithoutPostings() ([]models.Credit, error) {
+	var credits []models.Credit
+	query := `SELECT id, user_id, amount, interest_rate, term, created_at
+	          FROM credits c
+	          WHERE amount <> 0
+	          AND NOT EXISTS (SELECT 1 FROM postings p WHERE p.ledger_account = $1 AND p.credit_id = c.id)`
+	rows, err := r.DB.Query(query, models.LedgerLoan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credits without postings: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var credit models.Credit
+		if err := rows.Scan(&credit.ID, &credit.UserID, &credit.Amount, &credit.InterestRate, &credit.Term, &credit.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan credit: %v", err)
+		}
+		credits = append(credits, credit)
+	}
+	return credits, nil
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type PaymentReminderRepository struct {
+	DB DBTX
+}
+
+func NewPaymentReminderRepository(db *sql.DB) *PaymentReminderRepository {
+	return &PaymentReminderRepository{DB: db}
+}
+
+// GetDueReminders возвращает непогашенные строки графика активных кредитов, до даты платежа по которым
+// осталось не больше дней, чем задано у заемщика (defaultDays, если не задано), и напоминание еще не отправлялось
+func (r *PaymentReminderRepository) GetDueReminders(defaultDays int, now time.Time) ([]models.PaymentReminder, error) {
+	var reminders []models.PaymentReminder
+	query := `SELECT s.id, s.credit_id, u.email, s.due_date, s.amount
+	          FROM payment_schedules s
+	          JOIN credits c ON c.id = s.credit_id
+	          JOIN users u ON u.id = c.user_id
+	          WHERE NOT s.is_paid AND c.status=$1 AND s.due_date > $2
+	          AND s.due_date <= $2 + COALESCE(u.payment_reminder_days, $3) * INTERVAL '1 day'
+	          AND NOT EXISTS (SELECT 1 FROM payment_reminders pr WHERE pr.schedule_id = s.id)
+	          ORDER BY s.due_date, s.id`
+	rows, err := r.DB.Query(query, models.CreditActive, now, defaultDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due payment reminders: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reminder models.PaymentReminder
+		if err := rows.Scan(&reminder.ScheduleID, &reminder.CreditID, &reminder.Email, &reminder.DueDate, &reminder.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan payment reminder: %v", err)
+		}
+		reminders = append(reminders, reminder)
+	}
+	return reminders, nil
+}
+
+// ClaimReminder отмечает напоминание по строке графика отправленным. Возвращает false, если его уже
+// отметил другой запуск. Вызывается в транзакции вместе с отправкой: при ошибке отправки отметка откатывается
+func (r *PaymentReminderRepository) ClaimReminder(reminder *models.PaymentReminder, sentAt time.Time) (bool, error) {
+	query := `INSERT INTO payment_reminders (schedule_id, credit_id, sent_at)
+	          VALUES ($1, $2, $3)
+	          ON CONFLICT (schedule_id) DO NOTHING`
+	result, err := r.DB.Exec(query, reminder.ScheduleID, reminder.CreditID, sentAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim payment reminder: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
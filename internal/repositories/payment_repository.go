@@ -3,28 +3,78 @@ package repositories
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
 )
 
 type PaymentRepository struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func NewPaymentRepository(db *sql.DB) *PaymentRepository {
 	return &PaymentRepository{DB: db}
 }
 
+const paymentColumns = `id, credit_id, schedule_id, account_id, type, amount, payment_date, status, created_at`
+
+func scanPayment(row rowScanner, payment *models.Payment) error {
+	var scheduleID, accountID sql.NullInt64
+	err := row.Scan(&payment.ID, &payment.CreditID, &scheduleID, &accountID, &payment.Type, &payment.Amount, &payment.PaymentDate, &payment.Status, &payment.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if scheduleID.Valid {
+		id := uint(scheduleID.Int64)
+		payment.ScheduleID = &id
+	}
+	if accountID.Valid {
+		id := uint(accountID.Int64)
+		payment.AccountID = &id
+	}
+	return nil
+}
+
 func (r *PaymentRepository) CreatePayment(payment *models.Payment) error {
-	query := `INSERT INTO payments (credit_id, amount, payment_date, status, created_at)
-	          VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	if payment.Type == "" {
+		payment.Type = models.PaymentScheduled
+	}
+	query := `INSERT INTO payments (credit_id, schedule_id, account_id, type, amount, payment_date, status, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+
+	return r.DB.QueryRow(query, payment.CreditID, payment.ScheduleID, payment.AccountID, payment.Type, payment.Amount, payment.PaymentDate, payment.Status, payment.CreatedAt).Scan(&payment.ID)
+}
+
+// CompleteScheduledPayment отмечает платеж по строке графика как исполненный.
+// Просроченный (failed) платеж также может быть исполнен. amount — фактически внесенная сумма,
+// accountID — счет клиента, с которого списан платеж. Возвращает nil, если платеж уже исполнен или отменен
+func (r *PaymentRepository) CompleteScheduledPayment(scheduleID uint, amount models.Money, accountID *uint, paidAt time.Time) (*models.Payment, error) {
+	var payment models.Payment
+	query := `UPDATE payments SET status='completed', amount=$1, payment_date=$2, account_id=$3
+	          WHERE schedule_id=$4 AND status IN ('pending', 'failed')
+	          RETURNING ` + paymentColumns
+	err := scanPayment(r.DB.QueryRow(query, amount, paidAt, accountID, scheduleID), &payment)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to complete scheduled payment: %v", err)
+	}
+	return &payment, nil
+}
 
-	return r.DB.QueryRow(query, payment.CreditID, payment.Amount, payment.PaymentDate, payment.Status, payment.CreatedAt).Scan(&payment.ID)
+// CancelOpenPayments отменяет ожидающие и просроченные платежи по кредиту, возвращает число отмененных платежей
+func (r *PaymentRepository) CancelOpenPayments(creditID uint) (int64, error) {
+	query := `UPDATE payments SET status='cancelled' WHERE credit_id=$1 AND status IN ('pending', 'failed')`
+	result, err := r.DB.Exec(query, creditID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel pending payments: %v", err)
+	}
+	return result.RowsAffected()
 }
 
 func (r *PaymentRepository) GetPaymentsByCreditID(creditID uint) ([]models.Payment, error) {
 	var payments []models.Payment
-	query := `SELECT id, credit_id, amount, payment_date, status, created_at
+	query := `SELECT ` + paymentColumns + `
 	          FROM payments
 	          WHERE credit_id=$1
 	          ORDER BY payment_date DESC`
@@ -36,7 +86,7 @@ func (r *PaymentRepository) GetPaymentsByCreditID(creditID uint) ([]models.Payme
 
 	for rows.Next() {
 		var payment models.Payment
-		if err := rows.Scan(&payment.ID, &payment.CreditID, &payment.Amount, &payment.PaymentDate, &payment.Status, &payment.CreatedAt); err != nil {
+		if err := scanPayment(rows, &payment); err != nil {
 			return nil, fmt.Errorf("failed to scan payment: %v", err)
 		}
 		payments = append(payments, payment)
@@ -46,10 +96,10 @@ func (r *PaymentRepository) GetPaymentsByCreditID(creditID uint) ([]models.Payme
 
 func (r *PaymentRepository) GetPaymentByID(paymentID uint) (*models.Payment, error) {
 	var payment models.Payment
-	query := `SELECT id, credit_id, amount, payment_date, status, created_at
+	query := `SELECT ` + paymentColumns + `
 	          FROM payments
 	          WHERE id=$1`
-	err := r.DB.QueryRow(query, paymentID).Scan(&payment.ID, &payment.CreditID, &payment.Amount, &payment.PaymentDate, &payment.Status, &payment.CreatedAt)
+	err := scanPayment(r.DB.QueryRow(query, paymentID), &payment)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get payment: %v", err)
 	}
@@ -62,11 +112,51 @@ func (r *PaymentRepository) UpdatePaymentStatus(paymentID uint, status string) e
 	return err
 }
 
+// TransitionPaymentStatus меняет статус платежа, только если текущий статус равен fromStatus.
+// Возвращает false, если платеж уже был обработан
+func (r *PaymentRepository) TransitionPaymentStatus(paymentID uint, fromStatus, toStatus string) (bool, error) {
+	query := `UPDATE payments SET status=$1 WHERE id=$2 AND status=$3`
+	result, err := r.DB.Exec(query, toStatus, paymentID, fromStatus)
+	if err != nil {
+		return false, fmt.Errorf("failed to update payment status: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update payment status: %v", err)
+	}
+	return affected > 0, nil
+}
+
+// GetAutoDebitPayments возвращает наступившие и просроченные платежи по графику для активных кредитов
+// с привязанным счетом автоматического списания, в порядке дат платежей
+func (r *PaymentRepository) GetAutoDebitPayments() ([]models.Payment, error) {
+	var payments []models.Payment
+	query := `SELECT ` + paymentColumns + `
+	          FROM payments
+	          WHERE payment_date <= NOW() AND status IN ('pending', 'failed') AND schedule_id IS NOT NULL
+	          AND credit_id IN (SELECT id FROM credits WHERE status=$1 AND repayment_account_id IS NOT NULL)
+	          ORDER BY payment_date, id`
+	rows, err := r.DB.Query(query, models.CreditActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auto-debit payments: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var payment models.Payment
+		if err := scanPayment(rows, &payment); err != nil {
+			return nil, fmt.Errorf("failed to scan auto-debit payment: %v", err)
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
+
 func (r *PaymentRepository) GetOverduePayments() ([]models.Payment, error) {
 	var payments []models.Payment
-	query := `SELECT id, credit_id, amount, payment_date, status, created_at
+	query := `SELECT ` + paymentColumns + `
 	          FROM payments
-	          WHERE payment_date < NOW() AND status='pending'`
+	          WHERE payment_date < NOW() AND status IN ('pending', 'failed') AND schedule_id IS NOT NULL`
 	rows, err := r.DB.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get overdue payments: %v", err)
@@ -75,10 +165,34 @@ func (r *PaymentRepository) GetOverduePayments() ([]models.Payment, error) {
 
 	for rows.Next() {
 		var payment models.Payment
-		if err := rows.Scan(&payment.ID, &payment.CreditID, &payment.Amount, &payment.PaymentDate, &payment.Status, &payment.CreatedAt); err != nil {
+		if err := scanPayment(rows, &payment); err != nil {
 			return nil, fmt.Errorf("failed to scan overdue payment: %v", err)
 		}
 		payments = append(payments, payment)
 	}
 	return payments, nil
 }
+
+// GetOpenScheduledPayments возвращает неисполненные (ожидающие и просроченные) платежи по графику кредита
+// в порядке дат платежей
+func (r *PaymentRepository) GetOpenScheduledPayments(creditID uint) ([]models.Payment, error) {
+	var payments []models.Payment
+	query := `SELECT ` + paymentColumns + `
+	          FROM payments
+	          WHERE credit_id=$1 AND status IN ('pending', 'failed') AND schedule_id IS NOT NULL
+	          ORDER BY payment_date, id`
+	rows, err := r.DB.Query(query, creditID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open payments: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var payment models.Payment
+		if err := scanPayment(rows, &payment); err != nil {
+			return nil, fmt.Errorf("failed to scan open payment: %v", err)
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
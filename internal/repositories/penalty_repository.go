@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+type PenaltyRepository struct {
+	DB DBTX
+}
+
+func NewPenaltyRepository(db *sql.DB) *PenaltyRepository {
+	return &PenaltyRepository{DB: db}
+}
+
+// AddAccrual сохраняет начисление за день. Повторное начисление за тот же день по тому же платежу
+// игнорируется, в этом случае возвращается false
+func (r *PenaltyRepository) AddAccrual(penalty *models.Penalty) (bool, error) {
+	query := `INSERT INTO penalties (credit_id, payment_id, accrual_date, amount, created_at)
+	          VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (payment_id, accrual_date) DO NOTHING
+	          RETURNING id`
+	err := r.DB.QueryRow(query, penalty.CreditID, penalty.PaymentID, penalty.AccrualDate, penalty.Amount, penalty.CreatedAt).Scan(&penalty.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to add penalty accrual: %v", err)
+	}
+	return true, nil
+}
+
+// GetAccrued возвращает сумму начисленной по платежу неустойки и дату последнего начисления (nil, если начислений нет)
+func (r *PenaltyRepository) GetAccrued(paymentID uint) (models.Money, *time.Time, error) {
+	var total models.Money
+	var lastDate sql.NullTime
+	query := `SELECT COALESCE(SUM(amount), 0), MAX(accrual_date) FROM penalties WHERE payment_id=$1`
+	if err := r.DB.QueryRow(query, paymentID).Scan(&total, &lastDate); err != nil {
+		return models.Money{}, nil, fmt.Errorf("failed to get accrued penalties: %v", err)
+	}
+	if !lastDate.Valid {
+		return total, nil, nil
+	}
+	return total, &lastDate.Time, nil
+}
+
+func (r *PenaltyRepository) GetPenaltiesByCreditID(creditID uint) ([]models.Penalty, error) {
+	var penalties []models.Penalty
+	query := `SELECT id, credit_id, payment_id, accrual_date, amount, created_at
+	          FROM penalties
+	          WHERE credit_id=$1
+	          ORDER BY accrual_date, payment_id`
+	rows, err := r.DB.Query(query, creditID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get penalties: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var penalty models.Penalty
+		if err := rows.Scan(&penalty.ID, &penalty.CreditID, &penalty.PaymentID, &penalty.AccrualDate, &penalty.Amount, &penalty.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan penalty: %v", err)
+		}
+		penalties = append(penalties, penalty)
+	}
+	return penalties, nil
+}
@@ -0,0 +1,160 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+// Источники данных, загрузка которых учитывается в rate_fetch_ranges
+const (
+	RateSourceKeyRate  = "key_rate"
+	RateSourceCurrency = "currency"
+)
+
+// RateRepository хранит историю ключевой ставки и курсов валют ЦБ РФ, полученных из внешнего сервиса,
+// а также периоды, за которые данные уже загружены
+type RateRepository struct {
+	DB DBTX
+}
+
+func NewRateRepository(db *sql.DB) *RateRepository {
+	return &RateRepository{DB: db}
+}
+
+// SaveKeyRates сохраняет значения ключевой ставки; уже известные даты перезаписываются
+func (r *RateRepository) SaveKeyRates(rates []models.KeyRate) error {
+	return inTx(r.DB, func(q DBTX) error {
+		query := `INSERT INTO key_rates (date, rate) VALUES ($1, $2)
+		          ON CONFLICT (date) DO UPDATE SET rate = EXCLUDED.rate, fetched_at = CURRENT_TIMESTAMP`
+		for _, rate := range rates {
+			if _, err := q.Exec(query, rate.Date, rate.Rate); err != nil {
+				return fmt.Errorf("failed to save key rate: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetLatestKeyRate возвращает последнее сохраненное значение ключевой ставки или nil, если истории нет
+func (r *RateRepository) GetLatestKeyRate() (*models.KeyRate, error) {
+	var rate models.KeyRate
+	query := `SELECT date, rate FROM key_rates ORDER BY date DESC LIMIT 1`
+	err := r.DB.QueryRow(query).Scan(&rate.Date, &rate.Rate)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get latest key rate: %v", err)
+	}
+	return &rate, nil
+}
+
+// GetKeyRates возвращает значения ключевой ставки за период по возрастанию даты
+func (r *RateRepository) GetKeyRates(from, to time.Time) ([]models.KeyRate, error) {
+	var rates []models.KeyRate
+	query := `SELECT date, rate FROM key_rates WHERE date BETWEEN $1 AND $2 ORDER BY date`
+	rows, err := r.DB.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key rates: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rate models.KeyRate
+		if err := rows.Scan(&rate.Date, &rate.Rate); err != nil {
+			return nil, fmt.Errorf("failed to scan key rate: %v", err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// SaveCurrencyRates сохраняет курсы валют; уже известные значения перезаписываются
+func (r *RateRepository) SaveCurrencyRates(rates []models.CurrencyRate) error {
+	return inTx(r.DB, func(q DBTX) error {
+		query := `INSERT INTO currency_rates (date, code, nominal, value) VALUES ($1, $2, $3, $4)
+		          ON CONFLICT (date, code) DO UPDATE SET nominal = EXCLUDED.nominal, value = EXCLUDED.value,
+		          fetched_at = CURRENT_TIMESTAMP`
+		for _, rate := range rates {
+			if _, err := q.Exec(query, rate.Date, rate.Code, rate.Nominal, rate.Value); err != nil {
+				return fmt.Errorf("failed to save currency rate: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetCurrencyRates возвращает курсы валюты за период по возрастанию даты
+func (r *RateRepository) GetCurrencyRates(code string, from, to time.Time) ([]models.CurrencyRate, error) {
+	var rates []models.CurrencyRate
+	query := `SELECT date, code, nominal, value FROM currency_rates
+	          WHERE code = $1 AND date BETWEEN $2 AND $3 ORDER BY date`
+	rows, err := r.DB.Query(query, code, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get currency rates: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rate models.CurrencyRate
+		if err := rows.Scan(&rate.Date, &rate.Code, &rate.Nominal, &rate.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan currency rate: %v", err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// GetLatestCurrencyRate возвращает последний сохраненный курс валюты на дату date или раньше
+// либо nil, если истории курсов этой валюты нет
+func (r *RateRepository) GetLatestCurrencyRate(code string, date time.Time) (*models.CurrencyRate, error) {
+	var rate models.CurrencyRate
+	query := `SELECT date, code, nominal, value FROM currency_rates
+	          WHERE code = $1 AND date <= $2 ORDER BY date DESC LIMIT 1`
+	err := r.DB.QueryRow(query, code, date).Scan(&rate.Date, &rate.Code, &rate.Nominal, &rate.Value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get latest currency rate: %v", err)
+	}
+	return &rate, nil
+}
+
+// MarkFetched запоминает, что данные источника за период загружены из сервиса ЦБ РФ
+func (r *RateRepository) MarkFetched(source string, from, to time.Time) error {
+	query := `INSERT INTO rate_fetch_ranges (source, from_date, to_date, fetched_at) VALUES ($1, $2, $3, $4)`
+	if _, err := r.DB.Exec(query, source, from, to, time.Now()); err != nil {
+		return fmt.Errorf("failed to save rate fetch range: %v", err)
+	}
+	return nil
+}
+
+// GetMissingDates возвращает даты периода, данные за которые еще не загружались.
+// Прошедшие на момент загрузки даты считаются окончательными, а данные
+// за день загрузки и более поздние — актуальными только если загружены после freshSince
+func (r *RateRepository) GetMissingDates(source string, from, to, freshSince time.Time) ([]time.Time, error) {
+	var dates []time.Time
+	query := `SELECT d::date FROM generate_series($2::date, $3::date, interval '1 day') d
+	          WHERE NOT EXISTS (
+	              SELECT 1 FROM rate_fetch_ranges f
+	              WHERE f.source = $1 AND d BETWEEN f.from_date AND f.to_date
+	              AND (d < f.fetched_at::date OR f.fetched_at > $4)
+	          )
+	          ORDER BY d`
+	rows, err := r.DB.Query(query, source, from, to, freshSince)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get missing rate dates: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("failed to scan missing rate date: %v", err)
+		}
+		dates = append(dates, date)
+	}
+	return dates, nil
+}
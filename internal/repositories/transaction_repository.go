@@ -8,7 +8,7 @@ import (
 )
 
 type TransactionRepository struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func NewTransactionRepository(db *sql.DB) *TransactionRepository {
@@ -56,6 +56,20 @@ func (r *TransactionRepository) GetTransactionByID(transactionID uint) (*models.
 	return &transaction, nil
 }
 
+// GetTransactionForUpdate читает операцию с блокировкой строки до конца транзакции
+func (r *TransactionRepository) GetTransactionForUpdate(transactionID uint) (*models.Transaction, error) {
+	var transaction models.Transaction
+	query := `SELECT id, account_id, amount, type, category, description, created_at
+	          FROM transactions
+	          WHERE id=$1
+	          FOR UPDATE`
+	err := r.DB.QueryRow(query, transactionID).Scan(&transaction.ID, &transaction.AccountID, &transaction.Amount, &transaction.Type, &transaction.Category, &transaction.Description, &transaction.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction: %v", err)
+	}
+	return &transaction, nil
+}
+
 func (r *TransactionRepository) UpdateTransaction(transaction *models.Transaction) error {
 	query := `UPDATE transactions SET amount=$1, type=$2, category=$3, description=$4 WHERE id=$5`
 	_, err := r.DB.Exec(query, transaction.Amount, transaction.Type, transaction.Category, transaction.Description, transaction.ID)
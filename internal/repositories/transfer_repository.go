@@ -8,48 +8,47 @@ import (
 )
 
 type TransferRepository struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func NewTransferRepository(db *sql.DB) *TransferRepository {
 	return &TransferRepository{DB: db}
 }
 
+// CreateTransfer сохраняет перевод и переносит сумму между счетами.
+// Проверки баланса и блокировка счетов выполняются вызывающей стороной в той же транзакции
 func (r *TransferRepository) CreateTransfer(transfer *models.Transfer) error {
-	tx, err := r.DB.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %v", err)
-	}
-	defer tx.Rollback()
-
-	// Создание записи о переводе
-	query := `INSERT INTO transfers (from_account, to_account, amount, description, created_at)
-	          VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	err = tx.QueryRow(query, transfer.FromAccount, transfer.ToAccount, transfer.Amount, transfer.Description, transfer.CreatedAt).Scan(&transfer.ID)
-	if err != nil {
-		return fmt.Errorf("failed to create transfer: %v", err)
-	}
+	return inTx(r.DB, func(q DBTX) error {
+		// Создание записи о переводе
+		query := `INSERT INTO transfers (from_account, to_account, amount, currency, to_amount, to_currency, exchange_rate, description, created_at)
+		          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+		err := q.QueryRow(query, transfer.FromAccount, transfer.ToAccount, transfer.Amount, transfer.Currency,
+			transfer.ToAmount, transfer.ToCurrency, transfer.ExchangeRate, transfer.Description, transfer.CreatedAt).Scan(&transfer.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create transfer: %v", err)
+		}
 
-	// Обновление баланса отправителя
-	query = `UPDATE accounts SET balance = balance - $1 WHERE id = $2`
-	_, err = tx.Exec(query, transfer.Amount, transfer.FromAccount)
-	if err != nil {
-		return fmt.Errorf("failed to update sender balance: %v", err)
-	}
+		// Обновление баланса отправителя
+		query = `UPDATE accounts SET balance = balance - $1 WHERE id = $2`
+		_, err = q.Exec(query, transfer.Amount, transfer.FromAccount)
+		if err != nil {
+			return fmt.Errorf("failed to update sender balance: %v", err)
+		}
 
-	// Обновление баланса получателя
-	query = `UPDATE accounts SET balance = balance + $1 WHERE id = $2`
-	_, err = tx.Exec(query, transfer.Amount, transfer.ToAccount)
-	if err != nil {
-		return fmt.Errorf("failed to update receiver balance: %v", err)
-	}
+		// Обновление баланса получателя (в валюте его счета)
+		query = `UPDATE accounts SET balance = balance + $1 WHERE id = $2`
+		_, err = q.Exec(query, transfer.ToAmount, transfer.ToAccount)
+		if err != nil {
+			return fmt.Errorf("failed to update receiver balance: %v", err)
+		}
 
-	return tx.Commit()
+		return nil
+	})
 }
 
 func (r *TransferRepository) GetTransfersByAccountID(accountID uint) ([]models.Transfer, error) {
 	var transfers []models.Transfer
-	query := `SELECT id, from_account, to_account, amount, description, created_at
+	query := `SELECT id, from_account, to_account, amount, currency, to_amount, to_currency, exchange_rate, description, created_at
 	          FROM transfers
 	          WHERE from_account=$1 OR to_account=$1
 	          ORDER BY created_at DESC`
@@ -61,7 +60,7 @@ func (r *TransferRepository) GetTransfersByAccountID(accountID uint) ([]models.T
 
 	for rows.Next() {
 		var transfer models.Transfer
-		if err := rows.Scan(&transfer.ID, &transfer.FromAccount, &transfer.ToAccount, &transfer.Amount, &transfer.Description, &transfer.CreatedAt); err != nil {
+		if err := scanTransfer(rows, &transfer); err != nil {
 			return nil, fmt.Errorf("failed to scan transfer: %v", err)
 		}
 		transfers = append(transfers, transfer)
@@ -71,12 +70,24 @@ func (r *TransferRepository) GetTransfersByAccountID(accountID uint) ([]models.T
 
 func (r *TransferRepository) GetTransferByID(transferID uint) (*models.Transfer, error) {
 	var transfer models.Transfer
-	query := `SELECT id, from_account, to_account, amount, description, created_at
+	query := `SELECT id, from_account, to_account, amount, currency, to_amount, to_currency, exchange_rate, description, created_at
 	          FROM transfers
 	          WHERE id=$1`
-	err := r.DB.QueryRow(query, transferID).Scan(&transfer.ID, &transfer.FromAccount, &transfer.ToAccount, &transfer.Amount, &transfer.Description, &transfer.CreatedAt)
+	err := scanTransfer(r.DB.QueryRow(query, transferID), &transfer)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get transfer: %v", err)
 	}
 	return &transfer, nil
-}
\ No newline at end of file
+}
+
+// scanTransfer читает строку перевода и проставляет валюты сумм
+func scanTransfer(row rowScanner, transfer *models.Transfer) error {
+	err := row.Scan(&transfer.ID, &transfer.FromAccount, &transfer.ToAccount, &transfer.Amount, &transfer.Currency,
+		&transfer.ToAmount, &transfer.ToCurrency, &transfer.ExchangeRate, &transfer.Description, &transfer.CreatedAt)
+	if err != nil {
+		return err
+	}
+	transfer.Amount = transfer.Amount.WithCurrency(transfer.Currency)
+	transfer.ToAmount = transfer.ToAmount.WithCurrency(transfer.ToCurrency)
+	return nil
+}
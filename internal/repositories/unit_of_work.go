@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DBTX — общий интерфейс *sql.DB и *sql.Tx, через который работают репозитории.
+// Благодаря ему один и тот же репозиторий используется как самостоятельно,
+// так и внутри транзакции единицы работы
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// rowScanner — общий интерфейс *sql.Row и *sql.Rows для функций сканирования строк,
+// чтобы одна функция разбирала и результат QueryRow, и строки Query
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// UnitOfWork выполняет операции нескольких репозиториев в одной транзакции БД
+type UnitOfWork struct {
+	DB *sql.DB
+}
+
+func NewUnitOfWork(db *sql.DB) *UnitOfWork {
+	return &UnitOfWork{DB: db}
+}
+
+// Tx — набор репозиториев, привязанных к одной транзакции
+type Tx struct {
+	Accounts       *AccountRepository
+	Transfers      *TransferRepository
+	Transactions   *TransactionRepository
+	Credits        *CreditRepository
+	Payments       *PaymentRepository
+	Ledger         *LedgerRepository
+	FXQuotes       *FXQuoteRepository
+	Penalties      *PenaltyRepository
+	Applications   *CreditApplicationRepository
+	Restructurings *CreditRestructuringRepository
+	Certificates   *ClosureCertificateRepository
+	Reminders      *PaymentReminderRepository
+	Cards          *CardRepository
+	Holds          *HoldRepository
+	CardPayments   *CardPaymentRepository
+	CardReveals    *CardRevealRepository
+}
+
+func newTx(tx *sql.Tx) *Tx {
+	return &Tx{
+		Accounts:       &AccountRepository{DB: tx},
+		Transfers:      &TransferRepository{DB: tx},
+		Transactions:   &TransactionRepository{DB: tx},
+		Credits:        &CreditRepository{DB: tx},
+		Payments:       &PaymentRepository{DB: tx},
+		Ledger:         &LedgerRepository{DB: tx},
+		FXQuotes:       &FXQuoteRepository{DB: tx},
+		Penalties:      &PenaltyRepository{DB: tx},
+		Applications:   &CreditApplicationRepository{DB: tx},
+		Restructurings: &CreditRestructuringRepository{DB: tx},
+		Certificates:   &ClosureCertificateRepository{DB: tx},
+		Reminders:      &PaymentReminderRepository{DB: tx},
+		Cards:          &CardRepository{DB: tx},
+		Holds:          &HoldRepository{DB: tx},
+		CardPayments:   &CardPaymentRepository{DB: tx},
+		CardReveals:    &CardRevealRepository{DB: tx},
+	}
+}
+
+// Do открывает транзакцию, выполняет fn и фиксирует изменения.
+// Если fn вернула ошибку, транзакция откатывается
+func (u *UnitOfWork) Do(fn func(tx *Tx) error) error {
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(newTx(tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// inTx выполняет fn в собственной транзакции репозитория.
+// Если репозиторий уже работает внутри единицы работы, fn выполняется в ее транзакции
+func inTx(db DBTX, fn func(q DBTX) error) error {
+	sqlDB, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
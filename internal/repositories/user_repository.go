@@ -4,6 +4,8 @@ package repositories
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
 )
 
@@ -25,25 +27,45 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 // Получение пользователя по email
 func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, email, password, username, created_at FROM users WHERE email=$1`
-	err := r.DB.QueryRow(query, email).Scan(&user.ID, &user.Email, &user.Password, &user.Username, &user.CreatedAt)
+	var reminderDays sql.NullInt64
+	query := `SELECT id, email, password, username, role, payment_reminder_days, created_at FROM users WHERE email=$1`
+	err := r.DB.QueryRow(query, email).Scan(&user.ID, &user.Email, &user.Password, &user.Username, &user.Role, &reminderDays, &user.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("user not found")
 	} else if err != nil {
 		return nil, err
 	}
+	if reminderDays.Valid {
+		days := int(reminderDays.Int64)
+		user.PaymentReminderDays = &days
+	}
 	return &user, nil
 }
 
 // Получение пользователя по ID
 func (r *UserRepository) GetUserByID(id uint) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, email, password, username, created_at FROM users WHERE id=$1`
-	err := r.DB.QueryRow(query, id).Scan(&user.ID, &user.Email, &user.Password, &user.Username, &user.CreatedAt)
+	var reminderDays sql.NullInt64
+	query := `SELECT id, email, password, username, role, payment_reminder_days, created_at FROM users WHERE id=$1`
+	err := r.DB.QueryRow(query, id).Scan(&user.ID, &user.Email, &user.Password, &user.Username, &user.Role, &reminderDays, &user.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("user not found")
 	} else if err != nil {
 		return nil, err
 	}
+	if reminderDays.Valid {
+		days := int(reminderDays.Int64)
+		user.PaymentReminderDays = &days
+	}
 	return &user, nil
 }
+
+// SetPaymentReminderDays сохраняет, за сколько дней до платежа напоминать пользователю (nil — значение по умолчанию)
+func (r *UserRepository) SetPaymentReminderDays(userID uint, days *int) error {
+	query := `UPDATE users SET payment_reminder_days=$1 WHERE id=$2`
+	_, err := r.DB.Exec(query, days, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update payment reminder settings: %v", err)
+	}
+	return nil
+}
@@ -16,10 +16,11 @@ func NewAccountService(repo *repositories.AccountRepository) *AccountService {
 
 func (s *AccountService) CreateAccount(userID uint, currency string) (*models.Account, error) {
 	account := &models.Account{
-		UserID:    userID,
-		Balance:   0,
-		Currency:  currency,
-		CreatedAt: time.Now(),
+		UserID:           userID,
+		Balance:          models.NewMoney(0, currency),
+		AvailableBalance: models.NewMoney(0, currency),
+		Currency:         currency,
+		CreatedAt:        time.Now(),
 	}
 	if err := s.repo.CreateAccount(account); err != nil {
 		return nil, err
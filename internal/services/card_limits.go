@@ -0,0 +1,198 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+)
+
+// CardLimitsUpdate — изменение лимитов карты. Отсутствующие поля не меняются, нулевой лимит снимает
+// ограничение, пустой список MCC снимает ограничение по категориям
+type CardLimitsUpdate struct {
+	PerTransaction *models.Money `json:"per_transaction_limit"`
+	Daily          *models.Money `json:"daily_limit"`
+	Monthly        *models.Money `json:"monthly_limit"`
+	AllowedMCCs    *[]string     `json:"allowed_mccs"`
+	BlockedMCCs    *[]string     `json:"blocked_mccs"`
+}
+
+// GetCardLimits возвращает лимиты карты
+func (s *CardService) GetCardLimits(cardID uint) (*models.CardLimits, error) {
+	limits, err := s.repo.GetCardLimits(cardID)
+	if err == sql.ErrNoRows {
+		return nil, ErrCardNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get card limits: %v", err)
+	}
+	return limits, nil
+}
+
+// UpdateCardLimits изменяет лимиты расходов и ограничения по категориям торговых предприятий.
+// Лимиты можно менять у действующей или временно заблокированной карты
+func (s *CardService) UpdateCardLimits(cardID uint, update CardLimitsUpdate) (*models.CardLimits, error) {
+	var limits *models.CardLimits
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		if _, err := s.getCardForUpdate(tx, cardID, models.CardActive, models.CardBlocked); err != nil {
+			return err
+		}
+		var err error
+		limits, err = tx.Cards.GetCardLimits(cardID)
+		if err != nil {
+			return err
+		}
+
+		if limits.PerTransaction, err = updateLimit(limits.PerTransaction, update.PerTransaction, limits.Currency, "per_transaction_limit"); err != nil {
+			return err
+		}
+		if limits.Daily, err = updateLimit(limits.Daily, update.Daily, limits.Currency, "daily_limit"); err != nil {
+			return err
+		}
+		if limits.Monthly, err = updateLimit(limits.Monthly, update.Monthly, limits.Currency, "monthly_limit"); err != nil {
+			return err
+		}
+		if limits.Daily != nil && limits.Monthly != nil && limits.Daily.GreaterThan(*limits.Monthly) {
+			return validationError("daily_limit must not exceed monthly_limit")
+		}
+
+		if update.AllowedMCCs != nil {
+			if limits.AllowedMCCs, err = normalizeMCCs(*update.AllowedMCCs, "allowed_mccs"); err != nil {
+				return err
+			}
+		}
+		if update.BlockedMCCs != nil {
+			if limits.BlockedMCCs, err = normalizeMCCs(*update.BlockedMCCs, "blocked_mccs"); err != nil {
+				return err
+			}
+		}
+		for _, mcc := range limits.AllowedMCCs {
+			if containsMCC(limits.BlockedMCCs, mcc) {
+				return validationError("mcc %s can not be both allowed and blocked", mcc)
+			}
+		}
+
+		now := time.Now()
+		limits.UpdatedAt = &now
+		return tx.Cards.SaveCardLimits(limits)
+	})
+	if errors.Is(err, ErrValidation) || errors.Is(err, ErrCardNotFound) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to update card limits: %v", err)
+	}
+	return limits, nil
+}
+
+// GetCardDeclines возвращает отказы в оплате картой с их причинами
+func (s *CardService) GetCardDeclines(cardID uint) ([]models.CardDecline, error) {
+	return s.repo.GetDeclinesByCardID(cardID)
+}
+
+// copyCardLimits переносит лимиты заменяемой карты на перевыпущенную
+func copyCardLimits(tx *repositories.Tx, fromCardID, toCardID uint) error {
+	limits, err := tx.Cards.GetCardLimits(fromCardID)
+	if err != nil {
+		return err
+	}
+	if limits.UpdatedAt == nil {
+		return nil
+	}
+	limits.CardID = toCardID
+	return tx.Cards.SaveCardLimits(limits)
+}
+
+// updateLimit применяет новое значение лимита: nil — без изменения, 0 — снять ограничение
+func updateLimit(current, update *models.Money, currency, field string) (*models.Money, error) {
+	if update == nil {
+		return current, nil
+	}
+	if update.IsNegative() {
+		return nil, validationError("%s must not be negative", field)
+	}
+	if update.IsZero() {
+		return nil, nil
+	}
+	limit := update.WithCurrency(currency)
+	return &limit, nil
+}
+
+// normalizeMCCs проверяет коды категорий (четыре цифры) и возвращает их без повторов по возрастанию
+func normalizeMCCs(codes []string, field string) ([]string, error) {
+	normalized := []string{}
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		if !validMCC(code) {
+			return nil, validationError("%s: invalid mcc %q, expected 4 digits", field, code)
+		}
+		if !containsMCC(normalized, code) {
+			normalized = append(normalized, code)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func validMCC(code string) bool {
+	if len(code) != 4 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func containsMCC(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCardLimits проверяет оплату суммой amount в категории mcc по ограничениям карты. Расходы за день
+// и месяц считаются по авторизациям карты; вызывающий держит блокировку карты, поэтому параллельные
+// оплаты той же картой не превышают лимиты
+func checkCardLimits(tx *repositories.Tx, limits *models.CardLimits, amount models.Money, mcc string, now time.Time) error {
+	if len(limits.AllowedMCCs) > 0 && !containsMCC(limits.AllowedMCCs, mcc) {
+		return declineError(DeclineMCCNotAllowed)
+	}
+	if mcc != "" && containsMCC(limits.BlockedMCCs, mcc) {
+		return declineError(DeclineMCCBlocked)
+	}
+	if limits.PerTransaction != nil && amount.GreaterThan(*limits.PerTransaction) {
+		return declineError(DeclinePerTransactionLimit)
+	}
+
+	windows := []struct {
+		limit  *models.Money
+		since  time.Time
+		reason string
+	}{
+		{limits.Daily, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), DeclineDailyLimit},
+		{limits.Monthly, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), DeclineMonthlyLimit},
+	}
+	for _, window := range windows {
+		if window.limit == nil {
+			continue
+		}
+		spent, err := tx.CardPayments.GetCardSpending(limits.CardID, window.since, now)
+		if err != nil {
+			return err
+		}
+		// Сумма, не помещающаяся в DECIMAL(15, 2), заведомо превышает лимит
+		total, err := spent.WithCurrency(limits.Currency).AddChecked(amount)
+		if err != nil || total.GreaterThan(*window.limit) {
+			return declineError(window.reason)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,502 @@
+package services
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrCardDeclined — банк отказал в авторизации оплаты картой; причина указывается в тексте ошибки
+	ErrCardDeclined = errors.New("card declined")
+	// ErrCardAuthorizationNotFound — авторизация не существует или принадлежит другому торговому предприятию
+	ErrCardAuthorizationNotFound = errors.New("card authorization not found")
+)
+
+// Причины отказа в авторизации
+const (
+	DeclineInvalidCard       = "invalid_card" // номер, срок действия или CVV не совпадают с данными карты
+	DeclineCardNotActive     = "card_not_active"
+	DeclineCardExpired       = "card_expired"
+	DeclineInsufficientFunds = "insufficient_funds"
+
+	// Отказы по лимитам и ограничениям по категориям, установленным владельцем карты
+	DeclineMCCNotAllowed       = "mcc_not_allowed" // категория не входит в список разрешенных
+	DeclineMCCBlocked          = "mcc_blocked"
+	DeclinePerTransactionLimit = "per_transaction_limit_exceeded"
+	DeclineDailyLimit          = "daily_limit_exceeded"
+	DeclineMonthlyLimit        = "monthly_limit_exceeded"
+)
+
+// declinedError — отказ в авторизации с причиной; errors.Is(err, ErrCardDeclined) для него истинно
+type declinedError struct {
+	reason string
+}
+
+func (e *declinedError) Error() string { return ErrCardDeclined.Error() + ": " + e.reason }
+func (e *declinedError) Unwrap() error { return ErrCardDeclined }
+
+func declineError(reason string) error {
+	return &declinedError{reason: reason}
+}
+
+// declineReason возвращает причину отказа или пустую строку, если err не является отказом
+func declineReason(err error) string {
+	var declined *declinedError
+	if errors.As(err, &declined) {
+		return declined.reason
+	}
+	return ""
+}
+
+// CardAuthorizationRequest — данные карты и сумма оплаты, которые передает торговое предприятие
+type CardAuthorizationRequest struct {
+	PAN         string       `json:"pan"`
+	Expiry      string       `json:"expiry"`
+	CVV         string       `json:"cvv"`
+	Amount      models.Money `json:"amount"`
+	MCC         string       `json:"mcc"` // код категории торгового предприятия, четыре цифры
+	Description string       `json:"description"`
+}
+
+// CardAuthorizationFingerprint — отпечаток запроса авторизации для проверки повторов по Idempotency-Key.
+// В него входят только несекретные поля: вместо номера карты — его HMAC, срок действия и CVV не входят вовсе,
+// поэтому в таблице ключей идемпотентности не остается производных от реквизитов карты
+func CardAuthorizationFingerprint(body []byte) []byte {
+	var request CardAuthorizationRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil
+	}
+	fingerprint, _ := json.Marshal(struct {
+		PANHash     string       `json:"pan_hash"`
+		Amount      models.Money `json:"amount"`
+		MCC         string       `json:"mcc"`
+		Description string       `json:"description"`
+	}{panHash(request.PAN), request.Amount, request.MCC, request.Description})
+	return fingerprint
+}
+
+// maxFailedVerifications — число неверных CVV или сроков действия подряд, после которого карта блокируется,
+// чтобы торговое предприятие не могло подобрать CVV перебором
+func maxFailedVerifications() int {
+	if n := envInt("CARD_MAX_FAILED_VERIFICATIONS", 3); n > 0 {
+		return n
+	}
+	return 3
+}
+
+// cardAuthorizationTTL — срок, в течение которого торговое предприятие может подтвердить авторизацию
+func cardAuthorizationTTL() time.Duration {
+	return envDuration("CARD_AUTHORIZATION_TTL", 7*24*time.Hour)
+}
+
+type CardPaymentService struct {
+	repo        *repositories.CardPaymentRepository
+	cardRepo    *repositories.CardRepository
+	cardService *CardService
+	uow         *repositories.UnitOfWork
+}
+
+func NewCardPaymentService(repo *repositories.CardPaymentRepository, cardRepo *repositories.CardRepository, cardService *CardService, uow *repositories.UnitOfWork) *CardPaymentService {
+	return &CardPaymentService{
+		repo:        repo,
+		cardRepo:    cardRepo,
+		cardService: cardService,
+		uow:         uow,
+	}
+}
+
+// Authorize проверяет данные карты, ее лимиты и блокирует сумму оплаты на ее счете. Списание выполняется
+// отдельно через Capture; неподтвержденная авторизация истекает через CARD_AUTHORIZATION_TTL.
+// Отказы сохраняются с причиной и доступны владельцу карты
+func (s *CardPaymentService) Authorize(merchantID uint, request CardAuthorizationRequest) (*models.CardAuthorization, error) {
+	if request.PAN == "" || request.Expiry == "" || request.CVV == "" {
+		return nil, validationError("pan, expiry and cvv are required")
+	}
+	if !request.Amount.IsPositive() {
+		return nil, validationError("amount must be positive")
+	}
+	if request.MCC != "" && !validMCC(request.MCC) {
+		return nil, validationError("mcc must be 4 digits")
+	}
+
+	card, err := s.verifyCard(request)
+	if err != nil {
+		s.recordDecline(merchantID, card, request, err)
+		if card != nil && declineReason(err) == DeclineInvalidCard {
+			s.registerFailedVerification(card.ID)
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	authorization := &models.CardAuthorization{
+		CardID:      card.ID,
+		AccountID:   card.AccountID,
+		MerchantID:  merchantID,
+		Description: request.Description,
+		MCC:         request.MCC,
+		Status:      models.CardAuthAuthorized,
+		ExpiresAt:   now.Add(cardAuthorizationTTL()),
+		CreatedAt:   now,
+	}
+	err = s.uow.Do(func(tx *repositories.Tx) error {
+		// Карту могли заблокировать после проверки: статус перечитывается под блокировкой
+		locked, err := tx.Cards.GetCardForUpdate(card.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkCardUsable(locked, now); err != nil {
+			return err
+		}
+		// Реквизиты верны: счетчик ошибок считает только ошибки подряд
+		if err := tx.Cards.ResetFailedVerifications(card.ID); err != nil {
+			return err
+		}
+		limits, err := tx.Cards.GetCardLimits(card.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkCardLimits(tx, limits, request.Amount.WithCurrency(limits.Currency), request.MCC, now); err != nil {
+			return err
+		}
+
+		account, err := tx.Accounts.GetAccountForUpdate(card.AccountID)
+		if err != nil {
+			return err
+		}
+		amount := request.Amount.WithCurrency(account.Currency)
+		if account.AvailableBalance.LessThan(amount) {
+			return declineError(DeclineInsufficientFunds)
+		}
+
+		hold := &models.Hold{
+			AccountID: account.ID,
+			Amount:    amount,
+			Currency:  account.Currency,
+			Source:    models.HoldSourceCardAuthorization,
+			ExpiresAt: authorization.ExpiresAt,
+			CreatedAt: now,
+		}
+		if err := tx.Holds.CreateHold(hold); err != nil {
+			return err
+		}
+
+		authorization.HoldID = hold.ID
+		authorization.Amount = amount
+		authorization.Captured = models.NewMoney(0, account.Currency)
+		authorization.Refunded = models.NewMoney(0, account.Currency)
+		authorization.Currency = account.Currency
+		if err := tx.CardPayments.CreateAuthorization(authorization); err != nil {
+			return err
+		}
+		if err := tx.Holds.SetHoldReference(hold.ID, authorization.ID); err != nil {
+			return err
+		}
+		return s.addOperation(tx, authorization, models.CardOperationAuthorize, amount, now)
+	})
+	if errors.Is(err, ErrCardDeclined) {
+		s.recordDecline(merchantID, card, request, err)
+		return nil, err
+	} else if errors.Is(err, ErrValidation) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to authorize card payment: %v", err)
+	}
+
+	return authorization, nil
+}
+
+// verifyCard находит карту по номеру и сверяет номер с зашифрованным, срок действия и CVV с bcrypt-хешем.
+// Несовпадение любого из реквизитов отклоняется одной и той же причиной, чтобы не раскрывать, какой из них неверен.
+// При неверных сроке действия или CVV вместе с отказом возвращается найденная карта, чтобы отказ попал в ее историю
+func (s *CardPaymentService) verifyCard(request CardAuthorizationRequest) (*models.Card, error) {
+	cards, err := s.cardRepo.GetCardsByPANHash(panHash(request.PAN))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range cards {
+		card := &cards[i]
+		if err := s.cardService.DecryptCardData(card); err != nil {
+			utils.Log.WithField("card_id", card.ID).WithError(err).Error("Failed to decrypt card for authorization")
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(card.Number), []byte(request.PAN)) != 1 {
+			continue
+		}
+		// CVV недействующей карты не проверяется: иначе разные причины отказа позволили бы
+		// продолжать перебор CVV заблокированной карты
+		if err := checkCardUsable(card, time.Now()); err != nil {
+			return card, err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(card.CVVHash), []byte(request.CVV)) != nil || card.Expiry != request.Expiry {
+			return card, declineError(DeclineInvalidCard)
+		}
+		return card, nil
+	}
+	return nil, declineError(DeclineInvalidCard)
+}
+
+// registerFailedVerification учитывает неверные CVV или срок действия и блокирует карту, когда ошибок подряд
+// становится CARD_MAX_FAILED_VERIFICATIONS. Владелец может разблокировать карту, счетчик при этом обнуляется.
+// Ошибки только логируются: отказ уже возвращен торговому предприятию
+func (s *CardPaymentService) registerFailedVerification(cardID uint) {
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		card, err := tx.Cards.GetCardForUpdate(cardID)
+		if err != nil {
+			return err
+		}
+		failures, err := tx.Cards.AddFailedVerification(cardID)
+		if err != nil {
+			return err
+		}
+		if failures < maxFailedVerifications() || card.Status != models.CardActive {
+			return nil
+		}
+		if err := tx.Cards.UpdateCardStatus(cardID, models.CardBlocked, time.Now()); err != nil {
+			return err
+		}
+		utils.Log.WithFields(logrus.Fields{
+			"card_id":  cardID,
+			"failures": failures,
+		}).Warn("Card blocked after repeated failed verifications")
+		return nil
+	})
+	if err != nil {
+		utils.Log.WithField("card_id", cardID).WithError(err).Error("Failed to register failed card verification")
+	}
+}
+
+// recordDecline сохраняет отказ в авторизации. Ошибки только логируются: отказ уже возвращен торговому предприятию
+func (s *CardPaymentService) recordDecline(merchantID uint, card *models.Card, request CardAuthorizationRequest, err error) {
+	reason := declineReason(err)
+	if reason == "" {
+		return
+	}
+
+	decline := &models.CardDecline{
+		MerchantID: merchantID,
+		Amount:     request.Amount,
+		MCC:        request.MCC,
+		Reason:     reason,
+		CreatedAt:  time.Now(),
+	}
+	if card != nil {
+		decline.CardID = card.ID
+	}
+	if err := s.cardRepo.CreateDecline(decline); err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"card_id": decline.CardID,
+			"reason":  reason,
+			"error":   err.Error(),
+		}).Error("Failed to record card decline")
+	}
+}
+
+// checkCardUsable проверяет, что картой можно оплачивать
+func checkCardUsable(card *models.Card, now time.Time) error {
+	if card.Status == models.CardExpired || cardExpired(card.Expiry, now) {
+		return declineError(DeclineCardExpired)
+	}
+	if card.Status != models.CardActive {
+		return declineError(DeclineCardNotActive)
+	}
+	return nil
+}
+
+// Capture списывает со счета карты сумму авторизации или ее часть (amount, nil — всю сумму).
+// Частичное списание завершает авторизацию: остаток блокировки снимается
+func (s *CardPaymentService) Capture(merchantID, authorizationID uint, amount *models.Money) (*models.CardAuthorization, error) {
+	return s.update(merchantID, authorizationID, func(tx *repositories.Tx, authorization *models.CardAuthorization, account *models.Account, now time.Time) error {
+		if authorization.Status != models.CardAuthAuthorized {
+			return validationError("authorization is %s", authorization.Status)
+		}
+		if !now.Before(authorization.ExpiresAt) {
+			return validationError("authorization has expired")
+		}
+
+		captured := authorization.Amount
+		if amount != nil {
+			captured = amount.WithCurrency(authorization.Currency)
+		}
+		if !captured.IsPositive() || captured.GreaterThan(authorization.Amount) {
+			return validationError("capture amount must be positive and not exceed %s", authorization.Amount)
+		}
+		if account.Balance.LessThan(captured) {
+			return validationError("insufficient funds")
+		}
+
+		if err := tx.Accounts.AdjustBalance(account.ID, captured.Neg()); err != nil {
+			return err
+		}
+		if err := tx.Holds.FinishHold(authorization.HoldID, models.HoldCaptured, now); err != nil {
+			return err
+		}
+		authorization.Captured = captured
+		authorization.Status = models.CardAuthCaptured
+		if err := s.addOperation(tx, authorization, models.CardOperationCapture, captured, now); err != nil {
+			return err
+		}
+		return tx.Ledger.PostEntry(cardCaptureEntry(authorization, captured, now))
+	})
+}
+
+// Void отменяет авторизацию без списания и снимает блокировку средств
+func (s *CardPaymentService) Void(merchantID, authorizationID uint) (*models.CardAuthorization, error) {
+	return s.update(merchantID, authorizationID, func(tx *repositories.Tx, authorization *models.CardAuthorization, account *models.Account, now time.Time) error {
+		if authorization.Status != models.CardAuthAuthorized {
+			return validationError("authorization is %s", authorization.Status)
+		}
+
+		if err := tx.Holds.FinishHold(authorization.HoldID, models.HoldReleased, now); err != nil {
+			return err
+		}
+		authorization.Status = models.CardAuthVoided
+		return s.addOperation(tx, authorization, models.CardOperationVoid, authorization.Amount, now)
+	})
+}
+
+// Refund возвращает на счет карты списанную сумму или ее часть (amount, nil — весь невозвращенный остаток).
+// Возвратов может быть несколько, но в сумме не больше списанного
+func (s *CardPaymentService) Refund(merchantID, authorizationID uint, amount *models.Money) (*models.CardAuthorization, error) {
+	return s.update(merchantID, authorizationID, func(tx *repositories.Tx, authorization *models.CardAuthorization, account *models.Account, now time.Time) error {
+		if authorization.Status != models.CardAuthCaptured {
+			return validationError("authorization is %s", authorization.Status)
+		}
+
+		refundable := authorization.Captured.Sub(authorization.Refunded)
+		refund := refundable
+		if amount != nil {
+			refund = amount.WithCurrency(authorization.Currency)
+		}
+		if !refund.IsPositive() || refund.GreaterThan(refundable) {
+			return validationError("refund amount must be positive and not exceed %s", refundable)
+		}
+
+		if err := tx.Accounts.AdjustBalance(account.ID, refund); err != nil {
+			return err
+		}
+		authorization.Refunded = authorization.Refunded.Add(refund)
+		if authorization.Refunded.Cmp(authorization.Captured) == 0 {
+			authorization.Status = models.CardAuthRefunded
+		}
+		if err := s.addOperation(tx, authorization, models.CardOperationRefund, refund, now); err != nil {
+			return err
+		}
+		return tx.Ledger.PostEntry(cardRefundEntry(authorization, refund, now))
+	})
+}
+
+// update выполняет операцию fn над авторизацией торгового предприятия merchantID и сохраняет авторизацию.
+// Счет блокируется раньше авторизации — в том же порядке, что и при ее создании
+func (s *CardPaymentService) update(merchantID, authorizationID uint, fn func(tx *repositories.Tx, authorization *models.CardAuthorization, account *models.Account, now time.Time) error) (*models.CardAuthorization, error) {
+	authorization, err := s.repo.GetAuthorization(authorizationID)
+	if err != nil {
+		return nil, err
+	}
+	if authorization == nil || authorization.MerchantID != merchantID {
+		return nil, ErrCardAuthorizationNotFound
+	}
+
+	err = s.uow.Do(func(tx *repositories.Tx) error {
+		account, err := tx.Accounts.GetAccountForUpdate(authorization.AccountID)
+		if err != nil {
+			return err
+		}
+		authorization, err = tx.CardPayments.GetAuthorizationForUpdate(authorizationID)
+		if err != nil {
+			return err
+		}
+		if authorization == nil {
+			return ErrCardAuthorizationNotFound
+		}
+
+		now := time.Now()
+		if err := fn(tx, authorization, account, now); err != nil {
+			return err
+		}
+		authorization.UpdatedAt = now
+		return tx.CardPayments.UpdateAuthorization(authorization)
+	})
+	if errors.Is(err, ErrValidation) || errors.Is(err, ErrCardAuthorizationNotFound) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to update card authorization: %v", err)
+	}
+
+	return s.withOperations(authorization)
+}
+
+func (s *CardPaymentService) addOperation(tx *repositories.Tx, authorization *models.CardAuthorization, operationType string, amount models.Money, now time.Time) error {
+	return tx.CardPayments.CreateOperation(&models.CardPaymentOperation{
+		AuthorizationID: authorization.ID,
+		Type:            operationType,
+		Amount:          amount,
+		CreatedAt:       now,
+	})
+}
+
+// GetAuthorization возвращает авторизацию торгового предприятия вместе с историей операций
+func (s *CardPaymentService) GetAuthorization(merchantID, authorizationID uint) (*models.CardAuthorization, error) {
+	authorization, err := s.repo.GetAuthorization(authorizationID)
+	if err != nil {
+		return nil, err
+	}
+	if authorization == nil || authorization.MerchantID != merchantID {
+		return nil, ErrCardAuthorizationNotFound
+	}
+	return s.withOperations(authorization)
+}
+
+func (s *CardPaymentService) withOperations(authorization *models.CardAuthorization) (*models.CardAuthorization, error) {
+	operations, err := s.repo.GetOperations(authorization.ID, authorization.Currency)
+	if err != nil {
+		return nil, err
+	}
+	authorization.Operations = operations
+	return authorization, nil
+}
+
+// ExpireAuthorizations завершает авторизации, не подтвержденные до истечения срока, и снимает их блокировки
+func (s *CardPaymentService) ExpireAuthorizations() error {
+	var expired int
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		now := time.Now()
+		authorizations, err := tx.CardPayments.GetExpiredAuthorizations(now)
+		if err != nil {
+			return err
+		}
+
+		for i := range authorizations {
+			authorization := &authorizations[i]
+			if err := tx.Holds.FinishHold(authorization.HoldID, models.HoldExpired, now); err != nil {
+				return err
+			}
+			authorization.Status = models.CardAuthExpired
+			authorization.UpdatedAt = now
+			if err := tx.CardPayments.UpdateAuthorization(authorization); err != nil {
+				return err
+			}
+			if err := s.addOperation(tx, authorization, models.CardOperationExpire, authorization.Amount, now); err != nil {
+				return err
+			}
+		}
+		expired = len(authorizations)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to expire card authorizations: %v", err)
+	}
+
+	utils.Log.Infof("Card authorizations expired: %d", expired)
+	return nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+)
+
+func TestCardAuthorizationFingerprint(t *testing.T) {
+	base := `{"pan":"4000001234567899","expiry":"12/30","cvv":"123","amount":"10.00","mcc":"5411","description":"order-1"}`
+	fingerprint := CardAuthorizationFingerprint([]byte(base))
+
+	for _, secret := range []string{"4000001234567899", "12/30", `"123"`} {
+		if bytes.Contains(fingerprint, []byte(secret)) {
+			t.Errorf("fingerprint %s contains card data %s", fingerprint, secret)
+		}
+	}
+
+	// Повтор с другими сроком действия и CVV — тот же запрос
+	retry := `{"cvv":"999","expiry":"01/29","pan":"4000001234567899","amount":10,"mcc":"5411","description":"order-1"}`
+	if !bytes.Equal(fingerprint, CardAuthorizationFingerprint([]byte(retry))) {
+		t.Error("fingerprint must not depend on expiry, cvv and field order")
+	}
+
+	others := []string{
+		`{"pan":"4000001234567881","expiry":"12/30","cvv":"123","amount":"10.00","mcc":"5411","description":"order-1"}`,
+		`{"pan":"4000001234567899","expiry":"12/30","cvv":"123","amount":"10.01","mcc":"5411","description":"order-1"}`,
+		`{"pan":"4000001234567899","expiry":"12/30","cvv":"123","amount":"10.00","mcc":"5812","description":"order-1"}`,
+		`{"pan":"4000001234567899","expiry":"12/30","cvv":"123","amount":"10.00","mcc":"5411","description":"order-2"}`,
+	}
+	for _, other := range others {
+		if bytes.Equal(fingerprint, CardAuthorizationFingerprint([]byte(other))) {
+			t.Errorf("fingerprint of %s must differ from %s", other, base)
+		}
+	}
+}
+
+// TestAuthorizeBlocksCardAfterFailedVerifications подбирает CVV карты: после CARD_MAX_FAILED_VERIFICATIONS
+// неверных попыток подряд карта блокируется, и даже верный CVV больше не принимается
+func TestAuthorizeBlocksCardAfterFailedVerifications(t *testing.T) {
+	db := openTestDB(t)
+	t.Setenv("CARD_MAX_FAILED_VERIFICATIONS", "3")
+
+	merchantID := createTestUser(t, db)
+	account := createTestAccount(t, db, createTestUser(t, db))
+	if err := repositories.NewAccountRepository(db).AdjustBalance(account.ID, models.NewMoney(1000_00, models.DefaultCurrency)); err != nil {
+		t.Fatalf("failed to fund account: %v", err)
+	}
+
+	cardRepo := repositories.NewCardRepository(db)
+	uow := repositories.NewUnitOfWork(db)
+	cardService := NewCardService(cardRepo, repositories.NewCardRevealRepository(db), repositories.NewUserRepository(db), nil, uow)
+	paymentService := NewCardPaymentService(repositories.NewCardPaymentRepository(db), cardRepo, cardService, uow)
+
+	pan := generateCardNumber()
+	card := &models.Card{
+		AccountID: account.ID,
+		Number:    pan,
+		CVV:       "123",
+		Expiry:    generateExpiryDate(),
+		Status:    models.CardActive,
+		CreatedAt: time.Now(),
+	}
+	expiry := card.Expiry
+	if err := cardService.EncryptCardData(card); err != nil {
+		t.Fatalf("failed to encrypt card: %v", err)
+	}
+	if err := cardRepo.CreateCard(card); err != nil {
+		t.Fatalf("failed to create card: %v", err)
+	}
+
+	authorize := func(cvv string) error {
+		_, err := paymentService.Authorize(merchantID, CardAuthorizationRequest{
+			PAN:    pan,
+			Expiry: expiry,
+			CVV:    cvv,
+			Amount: models.NewMoney(1_00, ""),
+		})
+		return err
+	}
+	status := func() string {
+		card, err := cardRepo.GetCardByID(card.ID)
+		if err != nil {
+			t.Fatalf("failed to get card: %v", err)
+		}
+		return card.Status
+	}
+
+	// Верный CVV между ошибками обнуляет счетчик
+	for _, cvv := range []string{"000", "001", "123", "002", "003"} {
+		err := authorize(cvv)
+		if cvv == "123" && err != nil {
+			t.Fatalf("authorization with valid CVV failed: %v", err)
+		}
+		if cvv != "123" && declineReason(err) != DeclineInvalidCard {
+			t.Fatalf("authorization with CVV %s: error = %v, want %s", cvv, err, DeclineInvalidCard)
+		}
+	}
+	if got := status(); got != models.CardActive {
+		t.Fatalf("card status after 2 failures in a row = %s, want active", got)
+	}
+
+	if err := authorize("004"); declineReason(err) != DeclineInvalidCard {
+		t.Fatalf("third failure: error = %v, want %s", err, DeclineInvalidCard)
+	}
+	if got := status(); got != models.CardBlocked {
+		t.Fatalf("card status after 3 failures in a row = %s, want blocked", got)
+	}
+
+	// Заблокированная карта отклоняется одинаково при любом CVV
+	for _, cvv := range []string{"123", "005"} {
+		if err := authorize(cvv); declineReason(err) != DeclineCardNotActive {
+			t.Fatalf("blocked card with CVV %s: error = %v, want %s", cvv, err, DeclineCardNotActive)
+		}
+	}
+
+	// Разблокировка владельцем обнуляет счетчик
+	unblocked, err := cardService.UnblockCard(card.ID)
+	if err != nil {
+		t.Fatalf("failed to unblock card: %v", err)
+	}
+	if unblocked.Number != "" {
+		t.Fatal("UnblockCard must not return the full card number")
+	}
+	if err := authorize("006"); declineReason(err) != DeclineInvalidCard {
+		t.Fatalf("failure after unblock: error = %v, want %s", err, DeclineInvalidCard)
+	}
+	if got := status(); got != models.CardActive {
+		t.Fatalf("card status after unblock and 1 failure = %s, want active", got)
+	}
+}
+
+// TestAuthorizeWithRevealedCVV выпускает карту через CardService.CreateCard, получает ее реквизиты
+// показом владельцу и оплачивает ими: CVV, которого нет в открытом виде в базе, должен быть доступен владельцу
+func TestAuthorizeWithRevealedCVV(t *testing.T) {
+	db := openTestDB(t)
+
+	userRepo := repositories.NewUserRepository(db)
+	owner := &models.User{Password: "secret"}
+	if err := owner.HashPassword(); err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	ownerID := createTestUser(t, db)
+	if _, err := db.Exec(`UPDATE users SET password=$1 WHERE id=$2`, owner.Password, ownerID); err != nil {
+		t.Fatalf("failed to set password: %v", err)
+	}
+	merchantID := createTestUser(t, db)
+	account := createTestAccount(t, db, ownerID)
+	if err := repositories.NewAccountRepository(db).AdjustBalance(account.ID, models.NewMoney(1000_00, models.DefaultCurrency)); err != nil {
+		t.Fatalf("failed to fund account: %v", err)
+	}
+
+	cardRepo := repositories.NewCardRepository(db)
+	uow := repositories.NewUnitOfWork(db)
+	cardService := NewCardService(cardRepo, repositories.NewCardRevealRepository(db), userRepo, nil, uow)
+	paymentService := NewCardPaymentService(repositories.NewCardPaymentRepository(db), cardRepo, cardService, uow)
+
+	issued, err := cardService.CreateCard(account.ID)
+	if err != nil {
+		t.Fatalf("failed to create card: %v", err)
+	}
+	if issued.Number != "" || issued.CVV != "" {
+		t.Fatal("CreateCard must not return the full card number or CVV")
+	}
+
+	revealed, err := cardService.RevealCard(issued.ID, ownerID, CardRevealRequest{Password: "secret"}, CardRevealClient{})
+	if err != nil {
+		t.Fatalf("failed to reveal card: %v", err)
+	}
+	if len(revealed.Number) != 16 || len(revealed.CVV) != 3 || revealed.Expiry != issued.Expiry {
+		t.Fatalf("revealed card = %q %q %q, want number, CVV and expiry %s", revealed.Number, revealed.CVV, revealed.Expiry, issued.Expiry)
+	}
+
+	stored, err := cardRepo.GetCardByID(issued.ID)
+	if err != nil {
+		t.Fatalf("failed to get card: %v", err)
+	}
+	if stored.CVVHash == revealed.CVV || stored.CVVEncrypted == revealed.CVV {
+		t.Fatal("CVV must not be stored in plain text")
+	}
+
+	authorization, err := paymentService.Authorize(merchantID, CardAuthorizationRequest{
+		PAN:    revealed.Number,
+		Expiry: revealed.Expiry,
+		CVV:    revealed.CVV,
+		Amount: models.NewMoney(10_00, ""),
+	})
+	if err != nil {
+		t.Fatalf("authorization with revealed CVV failed: %v", err)
+	}
+	if authorization.CardID != issued.ID {
+		t.Fatalf("authorized card = %d, want %d", authorization.CardID, issued.ID)
+	}
+}
+
+// TestAuthorizeEnforcesCardLimits проверяет отказы по лимиту на операцию, дневному лимиту и запрещенной
+// категории: каждый отказ возвращается с причиной и сохраняется в истории отказов карты
+func TestAuthorizeEnforcesCardLimits(t *testing.T) {
+	db := openTestDB(t)
+
+	merchantID := createTestUser(t, db)
+	account := createTestAccount(t, db, createTestUser(t, db))
+	if err := repositories.NewAccountRepository(db).AdjustBalance(account.ID, models.NewMoney(1000_00, models.DefaultCurrency)); err != nil {
+		t.Fatalf("failed to fund account: %v", err)
+	}
+
+	cardRepo := repositories.NewCardRepository(db)
+	uow := repositories.NewUnitOfWork(db)
+	cardService := NewCardService(cardRepo, repositories.NewCardRevealRepository(db), repositories.NewUserRepository(db), nil, uow)
+	paymentService := NewCardPaymentService(repositories.NewCardPaymentRepository(db), cardRepo, cardService, uow)
+
+	pan := generateCardNumber()
+	card := &models.Card{
+		AccountID: account.ID,
+		Number:    pan,
+		CVV:       "123",
+		Expiry:    generateExpiryDate(),
+		Status:    models.CardActive,
+		CreatedAt: time.Now(),
+	}
+	expiry := card.Expiry
+	if err := cardService.EncryptCardData(card); err != nil {
+		t.Fatalf("failed to encrypt card: %v", err)
+	}
+	if err := cardRepo.CreateCard(card); err != nil {
+		t.Fatalf("failed to create card: %v", err)
+	}
+
+	perTransaction := models.NewMoney(120_00, "")
+	daily := models.NewMoney(200_00, "")
+	blocked := []string{"7995"}
+	_, err := cardService.UpdateCardLimits(card.ID, CardLimitsUpdate{PerTransaction: &perTransaction, Daily: &daily, BlockedMCCs: &blocked})
+	if err != nil {
+		t.Fatalf("failed to set card limits: %v", err)
+	}
+
+	steps := []struct {
+		name   string
+		amount int64
+		mcc    string
+		reason string // пусто — оплата проходит
+	}{
+		{name: "within limits", amount: 100_00, mcc: "5411"},
+		{name: "per-transaction limit", amount: 120_01, mcc: "5411", reason: DeclinePerTransactionLimit},
+		{name: "at per-transaction limit", amount: 80_00, mcc: "5411"},
+		// Уже потрачено 180.00 из 200.00 дневного лимита
+		{name: "daily limit", amount: 20_01, mcc: "5812", reason: DeclineDailyLimit},
+		{name: "blocked mcc", amount: 1_00, mcc: "7995", reason: DeclineMCCBlocked},
+		{name: "rest of daily limit", amount: 20_00, mcc: "5812"},
+	}
+	var wantDeclines []models.CardDecline
+	for _, step := range steps {
+		_, err := paymentService.Authorize(merchantID, CardAuthorizationRequest{
+			PAN:    pan,
+			Expiry: expiry,
+			CVV:    "123",
+			Amount: models.NewMoney(step.amount, ""),
+			MCC:    step.mcc,
+		})
+		if got := declineReason(err); got != step.reason || (step.reason == "" && err != nil) {
+			t.Fatalf("%s: error = %v, want decline reason %q", step.name, err, step.reason)
+		}
+		if step.reason != "" {
+			wantDeclines = append(wantDeclines, models.CardDecline{Amount: models.NewMoney(step.amount, ""), MCC: step.mcc, Reason: step.reason})
+		}
+	}
+
+	declines, err := cardService.GetCardDeclines(card.ID)
+	if err != nil {
+		t.Fatalf("failed to get card declines: %v", err)
+	}
+	if len(declines) != len(wantDeclines) {
+		t.Fatalf("recorded %d declines, want %d: %+v", len(declines), len(wantDeclines), declines)
+	}
+	// Отказы возвращаются начиная с последнего
+	for i, want := range wantDeclines {
+		got := declines[len(declines)-1-i]
+		if got.Reason != want.Reason || got.MCC != want.MCC || got.Amount.Minor() != want.Amount.Minor() ||
+			got.MerchantID != merchantID || got.CardID != card.ID {
+			t.Errorf("decline %d = %+v, want reason %s, mcc %s, amount %s", i, got, want.Reason, want.MCC, want.Amount)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+var (
+	// ErrCardRevealDenied — неверный пароль или одноразовый код при запросе реквизитов карты
+	ErrCardRevealDenied = errors.New("invalid password or one-time code")
+	// ErrCardRevealRateLimited — превышено число попыток показа реквизитов карты за период CARD_REVEAL_WINDOW
+	ErrCardRevealRateLimited = errors.New("too many card reveal attempts, try again later")
+)
+
+// CardRevealRequest — подтверждение показа реквизитов: пароль пользователя или одноразовый код из письма
+type CardRevealRequest struct {
+	Password string `json:"password"`
+	OTP      string `json:"otp"`
+}
+
+// CardRevealClient — сведения о клиенте, запросившем реквизиты, для журнала
+type CardRevealClient struct {
+	IP        string
+	UserAgent string
+}
+
+// Ограничение частоты показа реквизитов и срок действия одноразового кода
+func cardRevealLimit() int {
+	return envInt("CARD_REVEAL_LIMIT", 5)
+}
+
+func cardRevealWindow() time.Duration {
+	return envDuration("CARD_REVEAL_WINDOW", time.Hour)
+}
+
+func cardRevealOTPTTL() time.Duration {
+	return envDuration("CARD_REVEAL_OTP_TTL", 5*time.Minute)
+}
+
+// RequestRevealOTP отправляет владельцу карты письмо с одноразовым кодом для показа реквизитов.
+// Запрос кода учитывается в ограничении частоты наравне с попытками показа
+func (s *CardService) RequestRevealOTP(cardID, userID uint, client CardRevealClient) (time.Time, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get user: %v", err)
+	}
+
+	code, err := generateRevealCode()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var expiresAt time.Time
+	err = s.audited(cardID, userID, models.CardRevealOTPRequest, client, func(tx *repositories.Tx, _ *models.Card, now time.Time) (string, error) {
+		expiresAt = now.Add(cardRevealOTPTTL())
+		otp := &models.CardRevealOTP{
+			CardID:    cardID,
+			UserID:    userID,
+			CodeHash:  revealCodeHash(code),
+			ExpiresAt: expiresAt,
+			CreatedAt: now,
+		}
+		if err := tx.CardReveals.CreateOTP(otp); err != nil {
+			return "", err
+		}
+		return models.CardRevealOTPSent, nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := s.smtpService.SendCardRevealCode(user.Email, code, expiresAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to send one-time code: %v", err)
+	}
+	return expiresAt, nil
+}
+
+// RevealCard расшифровывает и возвращает полный номер карты и CVV после повторного ввода пароля
+// или одноразового кода. Каждая попытка записывается в журнал, число попыток ограничено
+func (s *CardService) RevealCard(cardID, userID uint, request CardRevealRequest, client CardRevealClient) (*models.Card, error) {
+	method := models.CardRevealByPassword
+	switch {
+	case request.Password != "" && request.OTP != "":
+		return nil, validationError("provide either password or otp, not both")
+	case request.OTP != "":
+		method = models.CardRevealByOTP
+	case request.Password == "":
+		return nil, validationError("password or otp is required")
+	}
+
+	var card *models.Card
+	err := s.audited(cardID, userID, method, client, func(tx *repositories.Tx, locked *models.Card, now time.Time) (string, error) {
+		if method == models.CardRevealByPassword {
+			user, err := s.userRepo.GetUserByID(userID)
+			if err != nil {
+				return "", fmt.Errorf("failed to get user: %v", err)
+			}
+			if user.CheckPassword(request.Password) != nil {
+				return models.CardRevealDenied, nil
+			}
+		} else {
+			otp, err := tx.CardReveals.GetActiveOTP(cardID, userID, now)
+			if err != nil {
+				return "", err
+			}
+			if otp == nil || !utils.VerifyHMAC([]byte(revealCodeInput(request.OTP)), []byte(otp.CodeHash)) {
+				return models.CardRevealDenied, nil
+			}
+			if err := tx.CardReveals.UseOTP(otp.ID, now); err != nil {
+				return "", err
+			}
+		}
+
+		if err := s.DecryptCardData(locked); err != nil {
+			return "", err
+		}
+		if err := s.decryptCVV(locked); err != nil {
+			return "", err
+		}
+		card = locked
+		return models.CardRevealSuccess, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// GetRevealEvents возвращает журнал показа реквизитов карты
+func (s *CardService) GetRevealEvents(cardID uint) ([]models.CardRevealEvent, error) {
+	return s.revealRepo.GetEvents(cardID)
+}
+
+// audited выполняет шаг показа реквизитов fn под блокировкой карты, проверив ограничение частоты,
+// и записывает результат в журнал. Отказ и превышение частоты тоже фиксируются в журнале
+func (s *CardService) audited(cardID, userID uint, method string, client CardRevealClient, fn func(tx *repositories.Tx, card *models.Card, now time.Time) (string, error)) error {
+	event := &models.CardRevealEvent{
+		CardID:    cardID,
+		UserID:    userID,
+		Method:    method,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		// Блокировка карты упорядочивает параллельные попытки, чтобы не превысить ограничение частоты
+		card, err := s.getCardForUpdate(tx, cardID, models.CardActive, models.CardBlocked, models.CardLost, models.CardExpired)
+		if err != nil {
+			return err
+		}
+
+		event.CreatedAt = time.Now()
+		attempts, err := tx.CardReveals.CountAttempts(cardID, userID, event.CreatedAt.Add(-cardRevealWindow()))
+		if err != nil {
+			return err
+		}
+		if attempts >= cardRevealLimit() {
+			event.Result = models.CardRevealRateLimited
+		} else if event.Result, err = fn(tx, card, event.CreatedAt); err != nil {
+			return err
+		}
+		return tx.CardReveals.CreateEvent(event)
+	})
+	if errors.Is(err, ErrValidation) || errors.Is(err, ErrCardNotFound) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("failed to reveal card: %v", err)
+	}
+
+	entry := utils.Log.WithFields(logrus.Fields{
+		"card_id": cardID,
+		"user_id": userID,
+		"method":  method,
+		"result":  event.Result,
+		"ip":      client.IP,
+	})
+	switch event.Result {
+	case models.CardRevealDenied:
+		entry.Warn("Card reveal denied")
+		return ErrCardRevealDenied
+	case models.CardRevealRateLimited:
+		entry.Warn("Card reveal rate limited")
+		return ErrCardRevealRateLimited
+	default:
+		entry.Info("Card reveal")
+		return nil
+	}
+}
+
+// generateRevealCode генерирует шестизначный одноразовый код
+func generateRevealCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate one-time code: %v", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func revealCodeInput(code string) string {
+	return "card-reveal:" + code
+}
+
+// revealCodeHash вычисляет HMAC одноразового кода, сам код не хранится
+func revealCodeHash(code string) string {
+	return utils.ComputeHMAC(revealCodeInput(code))
+}
@@ -1,9 +1,12 @@
 package services
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"math/rand"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
@@ -12,15 +15,45 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrCardNotFound — карта не существует
+var ErrCardNotFound = errors.New("card not found")
+
 type CardService struct {
-	repo *repositories.CardRepository
+	repo        *repositories.CardRepository
+	revealRepo  *repositories.CardRevealRepository
+	userRepo    *repositories.UserRepository
+	smtpService *SMTPService
+	uow         *repositories.UnitOfWork
 }
 
-func NewCardService(repo *repositories.CardRepository) *CardService {
-	return &CardService{repo: repo}
+func NewCardService(repo *repositories.CardRepository, revealRepo *repositories.CardRevealRepository, userRepo *repositories.UserRepository, smtpService *SMTPService, uow *repositories.UnitOfWork) *CardService {
+	return &CardService{
+		repo:        repo,
+		revealRepo:  revealRepo,
+		userRepo:    userRepo,
+		smtpService: smtpService,
+		uow:         uow,
+	}
 }
 
 func (s *CardService) CreateCard(accountID uint) (*models.Card, error) {
+	card, err := s.newCard(accountID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Сохранение карты в базе данных
+	if err := s.repo.CreateCard(card); err != nil {
+		return nil, err
+	}
+
+	hideCardNumber(card)
+	return card, nil
+}
+
+// newCard генерирует данные новой карты счета и шифрует их. Полный номер выпущенной карты
+// в ответе не возвращается, его можно получить через показ реквизитов
+func (s *CardService) newCard(accountID uint, now time.Time) (*models.Card, error) {
 	// Генерация данных карты
 	cardNumber := generateCardNumber()
 	cvv := generateCVV()
@@ -31,54 +64,174 @@ func (s *CardService) CreateCard(accountID uint) (*models.Card, error) {
 		Number:    cardNumber,
 		CVV:       cvv,
 		Expiry:    expiry,
-		CreatedAt: time.Now(),
+		Status:    models.CardActive,
+		CreatedAt: now,
 	}
 
 	// Шифрование данных карты
 	if err := s.EncryptCardData(card); err != nil {
 		return nil, err
 	}
-
-	// Сохранение карты в базе данных
-	if err := s.repo.CreateCard(card); err != nil {
-		return nil, err
-	}
-
 	return card, nil
 }
 
+// GetCardsByAccountID возвращает карты счета с маскированными номерами, номера не расшифровываются
 func (s *CardService) GetCardsByAccountID(accountID uint) ([]models.Card, error) {
 	cards, err := s.repo.GetCardsByAccountID(accountID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Расшифровка данных карт
 	for i := range cards {
-		if err := s.DecryptCardData(&cards[i]); err != nil {
-			return nil, err
-		}
+		hideCardNumber(&cards[i])
 	}
 
 	return cards, nil
 }
 
+// GetCardByID возвращает карту с маскированным номером, полный номер доступен только через RevealCard
 func (s *CardService) GetCardByID(cardID uint) (*models.Card, error) {
 	card, err := s.repo.GetCardByID(cardID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Расшифровка данных карты
-	if err := s.DecryptCardData(card); err != nil {
+	hideCardNumber(card)
+	return card, nil
+}
+
+// hideCardNumber убирает из карты номер и CVV перед отправкой клиенту
+func hideCardNumber(card *models.Card) {
+	card.Number = ""
+	card.CVV = ""
+}
+
+// BlockCard блокирует карту. Карта, отмеченная как утерянная (lost), не может быть разблокирована,
+// ее можно только перевыпустить
+func (s *CardService) BlockCard(cardID uint, lost bool) (*models.Card, error) {
+	if lost {
+		return s.changeStatus(cardID, models.CardLost, models.CardActive, models.CardBlocked)
+	}
+	return s.changeStatus(cardID, models.CardBlocked, models.CardActive)
+}
+
+// UnblockCard снимает временную блокировку карты
+func (s *CardService) UnblockCard(cardID uint) (*models.Card, error) {
+	return s.changeStatus(cardID, models.CardActive, models.CardBlocked)
+}
+
+// CloseCard закрывает карту. Карта остается в истории счета, но больше не может использоваться
+func (s *CardService) CloseCard(cardID uint) error {
+	_, err := s.changeStatus(cardID, models.CardClosed, models.CardActive, models.CardBlocked, models.CardLost, models.CardExpired)
+	return err
+}
+
+// changeStatus переводит карту в статус to, если ее текущий статус входит в from
+func (s *CardService) changeStatus(cardID uint, to string, from ...string) (*models.Card, error) {
+	var card *models.Card
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		var err error
+		card, err = s.getCardForUpdate(tx, cardID, from...)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if to == models.CardActive && cardExpired(card.Expiry, now) {
+			return validationError("card has expired, reissue it")
+		}
+		if err := tx.Cards.UpdateCardStatus(cardID, to, now); err != nil {
+			return err
+		}
+		// Разблокировка владельцем снимает и блокировку за неверные реквизиты
+		if to == models.CardActive {
+			if err := tx.Cards.ResetFailedVerifications(cardID); err != nil {
+				return err
+			}
+		}
+		card.Status = to
+		card.StatusChangedAt = &now
+		return nil
+	})
+	if errors.Is(err, ErrValidation) || errors.Is(err, ErrCardNotFound) {
 		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to change card status: %v", err)
 	}
 
+	hideCardNumber(card)
 	return card, nil
 }
 
-func (s *CardService) DeleteCard(cardID uint) error {
-	return s.repo.DeleteCard(cardID)
+// ReissueCard закрывает карту и выпускает взамен новую карту того же счета с новыми номером,
+// CVV и сроком действия. Перевыпустить можно любую незакрытую карту, в том числе утерянную и истекшую.
+// Лимиты и ограничения по категориям переносятся на новую карту
+func (s *CardService) ReissueCard(cardID uint) (*models.Card, error) {
+	var card *models.Card
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		old, err := s.getCardForUpdate(tx, cardID, models.CardActive, models.CardBlocked, models.CardLost, models.CardExpired)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Cards.UpdateCardStatus(cardID, models.CardClosed, now); err != nil {
+			return err
+		}
+		card, err = s.newCard(old.AccountID, now)
+		if err != nil {
+			return err
+		}
+		card.ReissuedFrom = &old.ID
+		if err := tx.Cards.CreateCard(card); err != nil {
+			return err
+		}
+		return copyCardLimits(tx, old.ID, card.ID)
+	})
+	if errors.Is(err, ErrValidation) || errors.Is(err, ErrCardNotFound) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to reissue card: %v", err)
+	}
+
+	hideCardNumber(card)
+	return card, nil
+}
+
+// getCardForUpdate блокирует карту в транзакции и проверяет, что ее статус входит в allowed
+func (s *CardService) getCardForUpdate(tx *repositories.Tx, cardID uint, allowed ...string) (*models.Card, error) {
+	card, err := tx.Cards.GetCardForUpdate(cardID)
+	if err == sql.ErrNoRows {
+		return nil, ErrCardNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, status := range allowed {
+		if card.Status == status {
+			return card, nil
+		}
+	}
+	return nil, validationError("operation is not allowed for %s card", card.Status)
+}
+
+// ExpireCards переводит в статус expired карты, срок действия которых истек
+func (s *CardService) ExpireCards() error {
+	expired, err := s.repo.ExpireCards(time.Now())
+	if err != nil {
+		return err
+	}
+	utils.Log.Infof("Cards expired: %d", expired)
+	return nil
+}
+
+// cardExpired проверяет, истек ли срок действия карты: карта действует до конца месяца MM/YY
+func cardExpired(expiry string, now time.Time) bool {
+	month, err := time.Parse("01/06", expiry)
+	if err != nil {
+		return false
+	}
+	return !now.Before(month.AddDate(0, 1, 0))
 }
 
 func (s *CardService) AccountBelongsToUser(accountID, userID uint) bool {
@@ -104,12 +257,23 @@ func (s *CardService) CardBelongsToUser(cardID, userID uint) bool {
 }
 
 func (s *CardService) EncryptCardData(card *models.Card) error {
-    // Хеширование CVV
+    // Хеш номера для поиска карты по номеру и маскированный номер для показа без расшифровки
+    card.PANHash = panHash(card.Number)
+    card.MaskedNumber = maskCardNumber(card.Number)
+
+    // Хеширование CVV для проверки при оплате и шифрование для показа владельцу.
+    // Открытый CVV в карте не остается
     hashedCVV, err := bcrypt.GenerateFromPassword([]byte(card.CVV), bcrypt.DefaultCost)
     if err != nil {
         return err
     }
-    card.CVV = string(hashedCVV)
+    card.CVVHash = string(hashedCVV)
+    encryptedCVV, err := utils.EncryptPGP(card.CVV)
+    if err != nil {
+        return fmt.Errorf("failed to encrypt card cvv: %v", err)
+    }
+    card.CVVEncrypted = encryptedCVV
+    card.CVV = ""
 
 	// Шифруем номер карты
     encryptedNumber, err := utils.EncryptPGP(card.Number)
@@ -144,6 +308,51 @@ func (s *CardService) DecryptCardData(card *models.Card) error {
     return nil
 }
 
+// decryptCVV расшифровывает CVV карты для показа реквизитов. У карт, выпущенных до появления
+// зашифрованного CVV, его можно узнать только перевыпуском карты
+func (s *CardService) decryptCVV(card *models.Card) error {
+	if card.CVVEncrypted == "" {
+		return nil
+	}
+	cvv, err := utils.DecryptPGP(card.CVVEncrypted)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt card cvv: %v", err)
+	}
+	card.CVV = cvv
+	return nil
+}
+
+// panHash вычисляет HMAC номера карты, по которому карта находится без расшифровки номеров
+func panHash(number string) string {
+	return utils.ComputeHMAC("pan:" + number)
+}
+
+// maskCardNumber оставляет в номере карты первые 6 и последние 4 цифры
+func maskCardNumber(number string) string {
+	if len(number) <= 10 {
+		return strings.Repeat("*", len(number))
+	}
+	return number[:6] + strings.Repeat("*", len(number)-10) + number[len(number)-4:]
+}
+
+// IndexCardNumbers сохраняет хеш и маскированный номер для карт, выпущенных до их появления
+func (s *CardService) IndexCardNumbers() error {
+	cards, err := s.repo.GetCardsWithoutPANIndex()
+	if err != nil {
+		return err
+	}
+	for i := range cards {
+		if err := s.DecryptCardData(&cards[i]); err != nil {
+			return fmt.Errorf("card %d: %v", cards[i].ID, err)
+		}
+		number := cards[i].Number
+		if err := s.repo.SetPANIndex(cards[i].ID, panHash(number), maskCardNumber(number)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func generateCardNumber() string {
     // Генерация номера карты по алгоритму Луна
     source := rand.NewSource(time.Now().UnixNano())
@@ -4,35 +4,406 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/beevik/etree"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
 )
 
+const defaultCBRURL = "https://www.cbr.ru/DailyInfoWebServ/DailyInfo.asmx"
+
+// CBRService — клиент SOAP-сервиса DailyInfo ЦБ РФ.
+// Ответы кэшируются на cacheTTL, неудачные запросы повторяются с экспоненциальной задержкой,
+// а при недоступности сервиса circuit breaker перестает к нему обращаться.
+// Полученные значения ключевой ставки и курсов валют сохраняются в БД и используются, если сервис недоступен
 type CBRService struct {
+	url        string
+	client     *http.Client
+	cacheTTL   time.Duration
+	maxRetries int
+	backoff    time.Duration
+	breaker    *circuitBreaker
+	rateRepo   *repositories.RateRepository
+	// historySyncFetches — сколько дат истории курсов можно запросить у ЦБ РФ в рамках одного запроса клиента
+	historySyncFetches int
+
+	mu          sync.Mutex
+	keyRate     *cachedKeyRate
+	cursCache   map[string]cachedCurs
+	backfilling bool
 }
 
-func NewCBRService() *CBRService {
-	return &CBRService{}
+type cachedKeyRate struct {
+	rate      float64
+	fetchedAt time.Time
+}
+
+// maxCursCacheDates — сколько дат курсов валют хранится в кэше. Курсы сохраняются в БД,
+// поэтому кэш нужен только для частых обращений к курсам на одни и те же даты
+const maxCursCacheDates = 64
+
+type cachedCurs struct {
+	rates     map[string]models.CurrencyRate
+	fetchedAt time.Time
+}
+
+func NewCBRService(rateRepo *repositories.RateRepository) *CBRService {
+	url := os.Getenv("CBR_URL")
+	if url == "" {
+		url = defaultCBRURL
+	}
+	return &CBRService{
+		url:        url,
+		client:     &http.Client{Timeout: envDuration("CBR_TIMEOUT", 10*time.Second)},
+		cacheTTL:   envDuration("CBR_CACHE_TTL", time.Hour),
+		maxRetries: envInt("CBR_MAX_RETRIES", 3),
+		backoff:    envDuration("CBR_RETRY_BACKOFF", 500*time.Millisecond),
+		breaker:    newCircuitBreaker(envInt("CBR_BREAKER_THRESHOLD", 5), envDuration("CBR_BREAKER_COOLDOWN", time.Minute)),
+		rateRepo:   rateRepo,
+		cursCache:  make(map[string]cachedCurs),
+
+		historySyncFetches: envInt("CBR_HISTORY_SYNC_FETCHES", 10),
+	}
 }
 
+// GetCentralBankRate возвращает текущую ключевую ставку ЦБ РФ.
+// Если сервис ЦБ недоступен, используется последнее сохраненное в БД значение
 func (s *CBRService) GetCentralBankRate() (float64, error) {
-	soapRequest := buildSOAPRequest()
-	rawBody, err := sendRequest(soapRequest)
+	s.mu.Lock()
+	cached := s.keyRate
+	s.mu.Unlock()
+	if cached != nil && time.Since(cached.fetchedAt) < s.cacheTTL {
+		return cached.rate, nil
+	}
+
+	rate, err := s.fetchKeyRate()
+	if err == nil {
+		return rate, nil
+	}
+
+	// Сервис недоступен — берем последнее известное значение из истории
+	utils.Log.WithError(err).Warn("Failed to get key rate from CBR, falling back to stored history")
+	stored, dbErr := s.rateRepo.GetLatestKeyRate()
+	if dbErr != nil {
+		return 0, fmt.Errorf("%v; %v", err, dbErr)
+	}
+	if stored == nil {
+		return 0, err
+	}
+	return stored.Rate, nil
+}
+
+func (s *CBRService) fetchKeyRate() (float64, error) {
+	rawBody, err := s.call(buildSOAPRequest(), "KeyRate")
 	if err != nil {
 		return 0, err
 	}
-	rate, err := parseXMLResponse(rawBody)
+	rates, err := parseKeyRates(rawBody)
 	if err != nil {
 		return 0, err
 	}
-	return rate, nil
+
+	toDate := dateOnly(time.Now())
+	if err := s.storeKeyRates(rates, toDate.AddDate(0, 0, -30), toDate); err != nil {
+		utils.Log.WithError(err).Warn("Failed to save key rate history")
+	}
+
+	s.mu.Lock()
+	s.keyRate = &cachedKeyRate{rate: rates[0].Rate, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return rates[0].Rate, nil
+}
+
+// GetKeyRateHistory возвращает значения ключевой ставки за период.
+// Данные берутся из локального хранилища, у ЦБ РФ запрашиваются только еще не загруженные даты
+func (s *CBRService) GetKeyRateHistory(from, to time.Time) ([]models.KeyRate, error) {
+	from, to, err := validateRatePeriod(from, to, maxKeyRatePeriodDays)
+	if err != nil {
+		return nil, err
+	}
+
+	missing, err := s.rateRepo.GetMissingDates(repositories.RateSourceKeyRate, from, to, time.Now().Add(-s.cacheTTL))
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		if err := s.loadKeyRates(missing[0], missing[len(missing)-1]); err != nil {
+			// Отдаем то, что уже есть в хранилище, если сервис ЦБ недоступен
+			utils.Log.WithError(err).Warn("Failed to load key rate history from CBR")
+			rates, dbErr := s.rateRepo.GetKeyRates(from, to)
+			if dbErr != nil || len(rates) == 0 {
+				return nil, fmt.Errorf("failed to get key rate history: %v", err)
+			}
+			return rates, nil
+		}
+	}
+
+	return s.rateRepo.GetKeyRates(from, to)
+}
+
+// GetCurrencyRateHistory возвращает официальные курсы валюты за период.
+// Курсы за еще не загруженные даты запрашиваются у ЦБ РФ и сохраняются в локальное хранилище.
+// Сервис ЦБ отдает курсы только на одну дату, поэтому синхронно загружаются не более historySyncFetches
+// последних дат, а остальные догружаются в фоне и появятся в ответе на следующие запросы
+func (s *CBRService) GetCurrencyRateHistory(code string, from, to time.Time) ([]models.CurrencyRate, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return nil, fmt.Errorf("%w: currency code must contain 3 letters", ErrInvalidRatePeriod)
+	}
+	from, to, err := validateRatePeriod(from, to, maxCurrencyRatePeriodDays)
+	if err != nil {
+		return nil, err
+	}
+
+	missing, err := s.rateRepo.GetMissingDates(repositories.RateSourceCurrency, from, to, time.Now().Add(-s.cacheTTL))
+	if err != nil {
+		return nil, err
+	}
+	split := max(len(missing)-s.historySyncFetches, 0)
+	s.loadCurrencyRates(missing[split:])
+	if split > 0 {
+		s.backfillCurrencyRates(missing[:split])
+	}
+
+	return s.rateRepo.GetCurrencyRates(code, from, to)
+}
+
+// loadCurrencyRates загружает курсы на даты по порядку и останавливается на первой ошибке:
+// если сервис ЦБ недоступен, остальные запросы тоже завершатся ошибкой
+func (s *CBRService) loadCurrencyRates(dates []time.Time) {
+	for _, date := range dates {
+		if _, err := s.GetCursOnDate(date); err != nil {
+			utils.Log.WithError(err).Warnf("Failed to load currency rates on %s from CBR", date.Format("2006-01-02"))
+			return
+		}
+	}
+}
+
+// backfillCurrencyRates догружает курсы в фоне. Одновременно выполняется не больше одной догрузки:
+// даты, которые не успели загрузиться, будут запрошены снова при следующем обращении к истории
+func (s *CBRService) backfillCurrencyRates(dates []time.Time) {
+	s.mu.Lock()
+	if s.backfilling {
+		s.mu.Unlock()
+		return
+	}
+	s.backfilling = true
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			s.backfilling = false
+			s.mu.Unlock()
+		}()
+		s.loadCurrencyRates(dates)
+	}()
+}
+
+func (s *CBRService) loadKeyRates(from, to time.Time) error {
+	rawBody, err := s.call(buildKeyRateRequest(from, to), "KeyRate")
+	if err != nil {
+		return err
+	}
+	rates, err := parseKeyRateRows(rawBody)
+	if err != nil {
+		return err
+	}
+	return s.storeKeyRates(rates, from, to)
+}
+
+// storeKeyRates сохраняет значения ключевой ставки и отмечает период как загруженный
+func (s *CBRService) storeKeyRates(rates []models.KeyRate, from, to time.Time) error {
+	if err := s.rateRepo.SaveKeyRates(rates); err != nil {
+		return err
+	}
+	return s.rateRepo.MarkFetched(repositories.RateSourceKeyRate, from, to)
+}
+
+// GetCursOnDate возвращает официальные курсы валют на дату, по буквенному коду валюты
+func (s *CBRService) GetCursOnDate(date time.Time) (map[string]models.CurrencyRate, error) {
+	date = dateOnly(date)
+	key := date.Format("2006-01-02")
+	s.mu.Lock()
+	cached, ok := s.cursCache[key]
+	s.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < s.cacheTTL {
+		return cached.rates, nil
+	}
+
+	rawBody, err := s.call(buildCursOnDateRequest(date), "GetCursOnDate")
+	if err != nil {
+		return nil, err
+	}
+	rates, err := parseCursOnDateResponse(rawBody, date)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storeCurrencyRates(rates, date); err != nil {
+		utils.Log.WithError(err).Warn("Failed to save currency rate history")
+	}
+
+	s.mu.Lock()
+	s.cacheCurs(key, rates, time.Now())
+	s.mu.Unlock()
+	return rates, nil
+}
+
+// cacheCurs кладет курсы на дату key в кэш и удаляет записи с истекшим TTL. Если кэш все равно полон,
+// удаляется запись, загруженная раньше остальных. Вызывается под s.mu
+func (s *CBRService) cacheCurs(key string, rates map[string]models.CurrencyRate, now time.Time) {
+	for k, cached := range s.cursCache {
+		if now.Sub(cached.fetchedAt) >= s.cacheTTL {
+			delete(s.cursCache, k)
+		}
+	}
+	if _, ok := s.cursCache[key]; !ok && len(s.cursCache) >= maxCursCacheDates {
+		oldest := ""
+		for k, cached := range s.cursCache {
+			if oldest == "" || cached.fetchedAt.Before(s.cursCache[oldest].fetchedAt) {
+				oldest = k
+			}
+		}
+		delete(s.cursCache, oldest)
+	}
+	s.cursCache[key] = cachedCurs{rates: rates, fetchedAt: now}
+}
+
+// GetExchangeRate возвращает официальный кросс-курс: сколько единиц toCurrency стоит одна единица fromCurrency.
+// Курсы ЦБ РФ установлены к рублю, поэтому для пары иностранных валют курс считается через рубль.
+// Если сервис ЦБ недоступен, используются последние сохраненные в БД курсы на дату date или раньше
+func (s *CBRService) GetExchangeRate(fromCurrency, toCurrency string, date time.Time) (*big.Rat, error) {
+	if fromCurrency == toCurrency {
+		return big.NewRat(1, 1), nil
+	}
+
+	rates, err := s.GetCursOnDate(date)
+	if err != nil {
+		// Сервис недоступен — берем последние известные курсы из истории
+		utils.Log.WithError(err).Warn("Failed to get currency rates from CBR, falling back to stored history")
+		rates, err = s.storedCurrencyRates(err, dateOnly(date), fromCurrency, toCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get currency rates: %v", err)
+		}
+	}
+
+	fromRub, err := rublesPerUnit(rates, fromCurrency)
+	if err != nil {
+		return nil, err
+	}
+	toRub, err := rublesPerUnit(rates, toCurrency)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Quo(fromRub, toRub), nil
+}
+
+// storedCurrencyRates возвращает последние сохраненные курсы валют codes на дату date или раньше.
+// cbrErr — ошибка обращения к ЦБ РФ: она возвращается, если в истории нет курса хотя бы одной валюты
+func (s *CBRService) storedCurrencyRates(cbrErr error, date time.Time, codes ...string) (map[string]models.CurrencyRate, error) {
+	rates := make(map[string]models.CurrencyRate, len(codes))
+	for _, code := range codes {
+		if code == "RUB" {
+			continue
+		}
+		rate, err := s.rateRepo.GetLatestCurrencyRate(code, date)
+		if err != nil {
+			return nil, fmt.Errorf("%v; %v", cbrErr, err)
+		}
+		if rate == nil {
+			return nil, cbrErr
+		}
+		rates[code] = *rate
+	}
+	return rates, nil
+}
+
+func (s *CBRService) storeCurrencyRates(rates map[string]models.CurrencyRate, date time.Time) error {
+	list := make([]models.CurrencyRate, 0, len(rates))
+	for _, rate := range rates {
+		list = append(list, rate)
+	}
+	if err := s.rateRepo.SaveCurrencyRates(list); err != nil {
+		return err
+	}
+	return s.rateRepo.MarkFetched(repositories.RateSourceCurrency, date, date)
+}
+
+// rublesPerUnit возвращает стоимость одной единицы валюты в рублях
+func rublesPerUnit(rates map[string]models.CurrencyRate, currency string) (*big.Rat, error) {
+	if currency == "RUB" {
+		return big.NewRat(1, 1), nil
+	}
+	rate, ok := rates[currency]
+	if !ok || rate.Nominal <= 0 {
+		return nil, fmt.Errorf("курс валюты %s не найден", currency)
+	}
+	return new(big.Rat).Quo(models.DecimalRat(rate.Value), big.NewRat(int64(rate.Nominal), 1)), nil
+}
+
+func buildCursOnDateRequest(date time.Time) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+		<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope">
+			<soap12:Body>
+				<GetCursOnDate xmlns="http://web.cbr.ru/">
+					<On_date>%s</On_date>
+				</GetCursOnDate>
+			</soap12:Body>
+		</soap12:Envelope>`, date.Format("2006-01-02"))
+}
+
+func parseCursOnDateResponse(rawBody []byte, date time.Time) (map[string]models.CurrencyRate, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(rawBody); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга XML: %v", err)
+	}
+	elements := doc.FindElements("//ValuteData/ValuteCursOnDate")
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("данные по курсам валют не найдены")
+	}
+
+	rates := make(map[string]models.CurrencyRate, len(elements))
+	for _, element := range elements {
+		codeElement := element.FindElement("./VchCode")
+		nominalElement := element.FindElement("./Vnom")
+		valueElement := element.FindElement("./Vcurs")
+		if codeElement == nil || nominalElement == nil || valueElement == nil {
+			continue
+		}
+
+		rate := models.CurrencyRate{
+			Date: date,
+			Code: strings.TrimSpace(codeElement.Text()),
+		}
+		var nominal float64
+		if _, err := fmt.Sscanf(strings.TrimSpace(nominalElement.Text()), "%f", &nominal); err != nil {
+			return nil, fmt.Errorf("ошибка конвертации номинала %s: %v", rate.Code, err)
+		}
+		rate.Nominal = int(nominal)
+		if _, err := fmt.Sscanf(strings.TrimSpace(valueElement.Text()), "%f", &rate.Value); err != nil {
+			return nil, fmt.Errorf("ошибка конвертации курса %s: %v", rate.Code, err)
+		}
+		rates[rate.Code] = rate
+	}
+	return rates, nil
 }
 
 func buildSOAPRequest() string {
-	fromDate := time.Now().AddDate(0, 0, -30).Format("2006-01-02")
-	toDate := time.Now().Format("2006-01-02")
+	return buildKeyRateRequest(time.Now().AddDate(0, 0, -30), time.Now())
+}
+
+func buildKeyRateRequest(from, to time.Time) string {
+	fromDate := from.Format("2006-01-02")
+	toDate := to.Format("2006-01-02")
 	return fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
 		<soap12:Envelope xmlns:soap12="http://www.w3.org/2003/05/soap-envelope">
 			<soap12:Body>
@@ -44,20 +415,44 @@ func buildSOAPRequest() string {
 		</soap12:Envelope>`, fromDate, toDate)
 }
 
-func sendRequest(soapRequest string) ([]byte, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
+// call выполняет SOAP-запрос через circuit breaker, повторяя его при ошибке
+// с экспоненциально растущей задержкой
+func (s *CBRService) call(soapRequest, action string) ([]byte, error) {
+	if err := s.breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("сервис ЦБ РФ временно недоступен: %v", err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= s.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(s.backoff * time.Duration(1<<(attempt-1)))
+		}
+		rawBody, err := s.sendRequest(soapRequest, action)
+		if err == nil {
+			s.breaker.Success()
+			return rawBody, nil
+		}
+		lastErr = err
+		utils.Log.WithError(err).Warnf("CBR %s request failed (attempt %d of %d)", action, attempt+1, s.maxRetries+1)
+	}
+
+	s.breaker.Failure()
+	return nil, lastErr
+}
+
+func (s *CBRService) sendRequest(soapRequest, action string) ([]byte, error) {
 	req, err := http.NewRequest(
 		"POST",
-		"https://www.cbr.ru/DailyInfoWebServ/DailyInfo.asmx",
+		s.url,
 		bytes.NewBuffer([]byte(soapRequest)),
 	)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/soap+xml; charset=utf-8")
-	req.Header.Set("SOAPAction", "http://web.cbr.ru/KeyRate")
+	req.Header.Set("SOAPAction", "http://web.cbr.ru/"+action)
 
-	resp, err := client.Do(req)
+	resp, err := s.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка запроса: %v", err)
 	}
@@ -67,28 +462,80 @@ func sendRequest(soapRequest string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("ошибка чтения ответа: %v", err)
 	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("сервис ЦБ РФ вернул статус %d", resp.StatusCode)
+	}
 
 	return rawBody, nil
 }
 
-func parseXMLResponse(rawBody []byte) (float64, error) {
+// parseKeyRates возвращает все значения ключевой ставки из ответа KeyRate, начиная с последнего
+func parseKeyRates(rawBody []byte) ([]models.KeyRate, error) {
+	rates, err := parseKeyRateRows(rawBody)
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("данные по ставке не найдены")
+	}
+	return rates, nil
+}
+
+// parseKeyRateRows разбирает ответ KeyRate; за период без заседаний ЦБ ответ может быть пустым
+func parseKeyRateRows(rawBody []byte) ([]models.KeyRate, error) {
 	doc := etree.NewDocument()
 	if err := doc.ReadFromBytes(rawBody); err != nil {
-		return 0, fmt.Errorf("ошибка парсинга XML: %v", err)
+		return nil, fmt.Errorf("ошибка парсинга XML: %v", err)
+	}
+	if doc.FindElement("//Body/Fault") != nil {
+		return nil, fmt.Errorf("сервис ЦБ РФ вернул ошибку SOAP")
 	}
 	krElements := doc.FindElements("//diffgram/KeyRate/KR")
-	if len(krElements) == 0 {
-		return 0, fmt.Errorf("данные по ставке не найдены")
+
+	rates := make([]models.KeyRate, 0, len(krElements))
+	for _, kr := range krElements {
+		rateElement := kr.FindElement("./Rate")
+		if rateElement == nil {
+			return nil, fmt.Errorf("тег Rate отсутствует")
+		}
+		var rate models.KeyRate
+		if _, err := fmt.Sscanf(rateElement.Text(), "%f", &rate.Rate); err != nil {
+			return nil, fmt.Errorf("ошибка конвертации ставки: %v", err)
+		}
+		if dateElement := kr.FindElement("./DT"); dateElement != nil {
+			date, err := time.Parse(time.RFC3339, strings.TrimSpace(dateElement.Text()))
+			if err != nil {
+				return nil, fmt.Errorf("ошибка конвертации даты ставки: %v", err)
+			}
+			rate.Date = dateOnly(date)
+		} else {
+			rate.Date = dateOnly(time.Now())
+		}
+		rates = append(rates, rate)
 	}
-	latestKR := krElements[0]
-	rateElement := latestKR.FindElement("./Rate")
-	if rateElement == nil {
-		return 0, fmt.Errorf("тег Rate отсутствует")
+
+	// Сервис возвращает значения от последнего к первому, но не полагаемся на это
+	sort.SliceStable(rates, func(i, j int) bool { return rates[i].Date.After(rates[j].Date) })
+	return rates, nil
+}
+
+// dateOnly отбрасывает время, оставляя календарную дату
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func envInt(name string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return defaultValue
 	}
-	rateStr := rateElement.Text()
-	var rate float64
-	if _, err := fmt.Sscanf(rateStr, "%f", &rate); err != nil {
-		return 0, fmt.Errorf("ошибка конвертации ставки: %v", err)
+	return value
+}
+
+func envDuration(name string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return defaultValue
 	}
-	return rate, nil
+	return value
 }
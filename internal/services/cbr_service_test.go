@@ -0,0 +1,447 @@
+package services
+
+import (
+	"database/sql"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/cbrstub"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+)
+
+// recordingStub оборачивает заглушку ЦБ РФ: запоминает запросы и может отвечать ошибкой
+type recordingStub struct {
+	stub *cbrstub.Server
+
+	mu       sync.Mutex
+	requests []recordedRequest
+	failNext int // сколько следующих запросов завершить ошибкой 503
+}
+
+type recordedRequest struct {
+	action      string
+	contentType string
+	body        string
+}
+
+func (s *recordingStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.requests = append(s.requests, recordedRequest{
+		action:      r.Header.Get("SOAPAction"),
+		contentType: r.Header.Get("Content-Type"),
+		body:        string(body),
+	})
+	fail := s.failNext > 0
+	if fail {
+		s.failNext--
+	}
+	s.mu.Unlock()
+
+	if fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	r.Body = io.NopCloser(strings.NewReader(string(body)))
+	s.stub.ServeHTTP(w, r)
+}
+
+func (s *recordingStub) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func (s *recordingStub) setFailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext = n
+}
+
+// newTestCBRService поднимает заглушку ЦБ РФ и клиент к ней. Хранилище истории недоступно:
+// сохранение курсов лишь пишет предупреждение в лог и не влияет на ответ клиента
+func newTestCBRService(t *testing.T) (*CBRService, *recordingStub) {
+	t.Helper()
+	rec := &recordingStub{stub: cbrstub.NewServer()}
+	server := httptest.NewServer(rec)
+	t.Cleanup(server.Close)
+
+	db, err := sql.Open("postgres", "postgres://localhost:1/unavailable?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatalf("failed to open database handle: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &CBRService{
+		url:        server.URL,
+		client:     server.Client(),
+		cacheTTL:   time.Hour,
+		maxRetries: 3,
+		backoff:    10 * time.Millisecond,
+		breaker:    newCircuitBreaker(2, time.Hour),
+		rateRepo:   repositories.NewRateRepository(db),
+		cursCache:  make(map[string]cachedCurs),
+	}, rec
+}
+
+func TestCBRServiceGetCursOnDate(t *testing.T) {
+	s, rec := newTestCBRService(t)
+	date := time.Date(2025, 3, 14, 15, 30, 0, 0, time.UTC)
+
+	rates, err := s.GetCursOnDate(date)
+	if err != nil {
+		t.Fatalf("GetCursOnDate error: %v", err)
+	}
+
+	usd, ok := rates["USD"]
+	if !ok || usd.Nominal != 1 || usd.Value != 90.1234 {
+		t.Errorf("USD rate = %+v, want nominal 1 value 90.1234", usd)
+	}
+	if jpy := rates["JPY"]; jpy.Nominal != 100 || jpy.Value != 60.4321 {
+		t.Errorf("JPY rate = %+v, want nominal 100 value 60.4321", jpy)
+	}
+	if !usd.Date.Equal(dateOnly(date)) {
+		t.Errorf("rate date = %v, want %v", usd.Date, dateOnly(date))
+	}
+
+	if rec.count() != 1 {
+		t.Fatalf("requests = %d, want 1", rec.count())
+	}
+	req := rec.requests[0]
+	if req.action != "http://web.cbr.ru/GetCursOnDate" {
+		t.Errorf("SOAPAction = %q", req.action)
+	}
+	if !strings.HasPrefix(req.contentType, "application/soap+xml") {
+		t.Errorf("Content-Type = %q", req.contentType)
+	}
+	if !strings.Contains(req.body, "<On_date>2025-03-14</On_date>") {
+		t.Errorf("request body does not contain the date: %s", req.body)
+	}
+}
+
+func TestCBRServiceGetExchangeRate(t *testing.T) {
+	s, _ := newTestCBRService(t)
+
+	rate, err := s.GetExchangeRate("USD", "EUR", time.Now())
+	if err != nil {
+		t.Fatalf("GetExchangeRate error: %v", err)
+	}
+	want := new(big.Rat).Quo(models.DecimalRat(90.1234), models.DecimalRat(98.5678))
+	if rate.Cmp(want) != 0 {
+		t.Errorf("USD/EUR = %s, want %s", rate.FloatString(6), want.FloatString(6))
+	}
+
+	// Номинал учитывается: 100 иен стоят 60.4321 рубля
+	rate, err = s.GetExchangeRate("JPY", "RUB", time.Now())
+	if err != nil {
+		t.Fatalf("GetExchangeRate error: %v", err)
+	}
+	if want := models.DecimalRat(0.604321); rate.Cmp(want) != 0 {
+		t.Errorf("JPY/RUB = %s, want 0.604321", rate.FloatString(6))
+	}
+
+	if _, err := s.GetExchangeRate("XXX", "RUB", time.Now()); err == nil {
+		t.Error("GetExchangeRate for unknown currency must fail")
+	}
+}
+
+func TestCBRServiceGetCentralBankRate(t *testing.T) {
+	s, rec := newTestCBRService(t)
+	rec.stub.KeyRate = 16.5
+
+	rate, err := s.GetCentralBankRate()
+	if err != nil {
+		t.Fatalf("GetCentralBankRate error: %v", err)
+	}
+	if rate != 16.5 {
+		t.Errorf("key rate = %v, want 16.5", rate)
+	}
+
+	req := rec.requests[0]
+	if req.action != "http://web.cbr.ru/KeyRate" {
+		t.Errorf("SOAPAction = %q", req.action)
+	}
+	if !strings.Contains(req.body, "<fromDate>") || !strings.Contains(req.body, "<ToDate>"+time.Now().Format("2006-01-02")+"</ToDate>") {
+		t.Errorf("unexpected KeyRate request: %s", req.body)
+	}
+}
+
+func TestParseKeyRateRows(t *testing.T) {
+	rec := httptest.NewRecorder()
+	stub := cbrstub.NewServer()
+	stub.KeyRate = 19
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+	stub.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(buildKeyRateRequest(from, to))))
+
+	rates, err := parseKeyRateRows(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("parseKeyRateRows error: %v", err)
+	}
+	if len(rates) != 3 {
+		t.Fatalf("rates = %d, want 3", len(rates))
+	}
+	if !rates[0].Date.Equal(to) || !rates[2].Date.Equal(from) || rates[0].Rate != 19 {
+		t.Errorf("rates = %+v, want newest first from %v to %v", rates, to, from)
+	}
+
+	fault := httptest.NewRecorder()
+	stub.ServeHTTP(fault, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("<Envelope><Body><Unknown/></Body></Envelope>")))
+	if _, err := parseKeyRateRows(fault.Body.Bytes()); err == nil {
+		t.Error("parseKeyRateRows must fail on SOAP fault")
+	}
+	if _, err := parseKeyRates([]byte(`<Envelope><Body/></Envelope>`)); err == nil {
+		t.Error("parseKeyRates must fail on empty response")
+	}
+	if _, err := parseCursOnDateResponse([]byte(`<Envelope><Body/></Envelope>`), from); err == nil {
+		t.Error("parseCursOnDateResponse must fail on empty response")
+	}
+}
+
+func TestCBRServiceRetriesWithBackoff(t *testing.T) {
+	s, rec := newTestCBRService(t)
+	rec.setFailNext(2)
+
+	start := time.Now()
+	if _, err := s.GetCursOnDate(time.Now()); err != nil {
+		t.Fatalf("GetCursOnDate error after retries: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if rec.count() != 3 {
+		t.Errorf("requests = %d, want 3 (2 failures and a success)", rec.count())
+	}
+	// Задержки перед повторами: 10ms, затем 20ms
+	if elapsed < 30*time.Millisecond {
+		t.Errorf("elapsed = %v, want at least 30ms of backoff", elapsed)
+	}
+	if err := s.breaker.Allow(); err != nil {
+		t.Errorf("breaker must stay closed after a successful retry: %v", err)
+	}
+}
+
+func TestCBRServiceRetriesExhausted(t *testing.T) {
+	s, rec := newTestCBRService(t)
+	s.maxRetries = 2
+	rec.setFailNext(100)
+
+	if _, err := s.GetCursOnDate(time.Now()); err == nil {
+		t.Fatal("GetCursOnDate must fail when all attempts fail")
+	}
+	if rec.count() != 3 {
+		t.Errorf("requests = %d, want 3 (1 attempt and 2 retries)", rec.count())
+	}
+}
+
+func TestCBRServiceCircuitBreaker(t *testing.T) {
+	s, rec := newTestCBRService(t)
+	s.maxRetries = 0
+	s.breaker = newCircuitBreaker(2, 50*time.Millisecond)
+	rec.setFailNext(100)
+
+	// Две ошибки подряд размыкают выключатель
+	for i := 0; i < 2; i++ {
+		if _, err := s.GetCursOnDate(time.Now()); err == nil {
+			t.Fatal("GetCursOnDate must fail while the service is down")
+		}
+	}
+	if _, err := s.GetCursOnDate(time.Now()); err == nil || !strings.Contains(err.Error(), ErrCircuitOpen.Error()) {
+		t.Fatalf("error = %v, want open circuit", err)
+	}
+	if rec.count() != 2 {
+		t.Fatalf("requests = %d, want 2: open breaker must not call the service", rec.count())
+	}
+
+	// После cooldown пропускается один пробный запрос; его ошибка снова размыкает выключатель
+	time.Sleep(60 * time.Millisecond)
+	if _, err := s.GetCursOnDate(time.Now()); err == nil || strings.Contains(err.Error(), ErrCircuitOpen.Error()) {
+		t.Fatalf("error = %v, want failed probe request", err)
+	}
+	if rec.count() != 3 {
+		t.Fatalf("requests = %d, want 3 after the probe", rec.count())
+	}
+	if _, err := s.GetCursOnDate(time.Now()); err == nil || !strings.Contains(err.Error(), ErrCircuitOpen.Error()) {
+		t.Fatalf("error = %v, want open circuit after failed probe", err)
+	}
+
+	// Успешный пробный запрос замыкает выключатель
+	time.Sleep(60 * time.Millisecond)
+	rec.setFailNext(0)
+	if _, err := s.GetCursOnDate(time.Now()); err != nil {
+		t.Fatalf("probe request error: %v", err)
+	}
+	if err := s.breaker.Allow(); err != nil {
+		t.Errorf("breaker must be closed after a successful probe: %v", err)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	b := newCircuitBreaker(1, time.Millisecond)
+	b.Failure()
+	time.Sleep(2 * time.Millisecond)
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("first request after cooldown must be allowed: %v", err)
+	}
+	if err := b.Allow(); err != ErrCircuitOpen {
+		t.Fatalf("second request during probe = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestCBRServiceCache(t *testing.T) {
+	s, rec := newTestCBRService(t)
+	date := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.GetCursOnDate(date); err != nil {
+			t.Fatalf("GetCursOnDate error: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.GetCentralBankRate(); err != nil {
+			t.Fatalf("GetCentralBankRate error: %v", err)
+		}
+	}
+	if rec.count() != 2 {
+		t.Fatalf("requests = %d, want 2: repeated calls must be served from cache", rec.count())
+	}
+
+	// Другая дата кэшируется отдельно
+	if _, err := s.GetCursOnDate(date.AddDate(0, 0, -1)); err != nil {
+		t.Fatalf("GetCursOnDate error: %v", err)
+	}
+	if rec.count() != 3 {
+		t.Fatalf("requests = %d, want 3 after a request for another date", rec.count())
+	}
+
+	// По истечении TTL данные запрашиваются заново
+	s.cacheTTL = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	if _, err := s.GetCursOnDate(date); err != nil {
+		t.Fatalf("GetCursOnDate error: %v", err)
+	}
+	if _, err := s.GetCentralBankRate(); err != nil {
+		t.Fatalf("GetCentralBankRate error: %v", err)
+	}
+	if rec.count() != 5 {
+		t.Fatalf("requests = %d, want 5 after the cache expired", rec.count())
+	}
+}
+
+func TestCBRServiceCacheEviction(t *testing.T) {
+	s, _ := newTestCBRService(t)
+	start := dateOnly(time.Now()).AddDate(0, 0, -2*maxCursCacheDates)
+
+	// Кэш не растет больше maxCursCacheDates дат, вытесняются самые старые записи
+	for i := 0; i < maxCursCacheDates+10; i++ {
+		if _, err := s.GetCursOnDate(start.AddDate(0, 0, i)); err != nil {
+			t.Fatalf("GetCursOnDate error: %v", err)
+		}
+	}
+	if len(s.cursCache) != maxCursCacheDates {
+		t.Fatalf("cache size = %d, want %d", len(s.cursCache), maxCursCacheDates)
+	}
+	if _, ok := s.cursCache[start.Format("2006-01-02")]; ok {
+		t.Error("the oldest entry must be evicted")
+	}
+	if _, ok := s.cursCache[start.AddDate(0, 0, maxCursCacheDates+9).Format("2006-01-02")]; !ok {
+		t.Error("the newest entry must stay in the cache")
+	}
+
+	// Записи с истекшим TTL удаляются при следующей загрузке
+	s.cacheTTL = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	if _, err := s.GetCursOnDate(start); err != nil {
+		t.Fatalf("GetCursOnDate error: %v", err)
+	}
+	if len(s.cursCache) != 1 {
+		t.Fatalf("cache size = %d, want 1 after expired entries were evicted", len(s.cursCache))
+	}
+}
+
+func TestCBRServiceGetExchangeRateWithoutHistory(t *testing.T) {
+	s, rec := newTestCBRService(t)
+	rec.setFailNext(100)
+
+	// ЦБ РФ и хранилище недоступны: в ошибке указаны обе причины
+	_, err := s.GetExchangeRate("USD", "RUB", time.Now())
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "failed to get latest currency rate") {
+		t.Fatalf("GetExchangeRate error = %v, want CBR and storage errors", err)
+	}
+}
+
+func TestCBRServiceGetExchangeRateFallsBackToStoredRates(t *testing.T) {
+	db := openTestDB(t)
+	s, rec := newTestCBRService(t)
+	s.rateRepo = repositories.NewRateRepository(db)
+
+	// Курсы на дату в будущем, чтобы не зависеть от данных других тестов
+	date := dateOnly(time.Now()).AddDate(50, 0, 0)
+	stored := []models.CurrencyRate{
+		{Date: date.AddDate(0, 0, -3), Code: "USD", Nominal: 1, Value: 80},
+		{Date: date.AddDate(0, 0, -1), Code: "USD", Nominal: 1, Value: 90},
+		{Date: date.AddDate(0, 0, 1), Code: "USD", Nominal: 1, Value: 100},
+		{Date: date.AddDate(0, 0, -2), Code: "JPY", Nominal: 100, Value: 60},
+	}
+	if err := s.rateRepo.SaveCurrencyRates(stored); err != nil {
+		t.Fatalf("failed to save currency rates: %v", err)
+	}
+	rec.setFailNext(100)
+
+	// Берется последний курс не позже даты: 90 рублей за доллар и 0.6 рубля за иену
+	rate, err := s.GetExchangeRate("USD", "JPY", date)
+	if err != nil {
+		t.Fatalf("GetExchangeRate error: %v", err)
+	}
+	if want := big.NewRat(150, 1); rate.Cmp(want) != 0 {
+		t.Errorf("USD/JPY = %s, want 150", rate.FloatString(4))
+	}
+
+	// Без истории по валюте возвращается ошибка ЦБ РФ
+	if _, err := s.GetExchangeRate("XXX", "RUB", date); err == nil {
+		t.Error("GetExchangeRate without stored rates must fail")
+	}
+}
+
+func TestCBRServiceBackfillCurrencyRates(t *testing.T) {
+	s, rec := newTestCBRService(t)
+	start := dateOnly(time.Now()).AddDate(0, 0, -10)
+	dates := make([]time.Time, 5)
+	for i := range dates {
+		dates[i] = start.AddDate(0, 0, i)
+	}
+
+	s.backfillCurrencyRates(dates)
+	// Пока идет догрузка, повторный запуск ничего не делает
+	s.backfillCurrencyRates(dates)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		done := !s.backfilling
+		s.mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("backfill did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if rec.count() != len(dates) {
+		t.Fatalf("requests = %d, want %d: one per date", rec.count(), len(dates))
+	}
+	for _, date := range dates {
+		if _, ok := s.cursCache[date.Format("2006-01-02")]; !ok {
+			t.Errorf("rates on %s were not loaded", date.Format("2006-01-02"))
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/vanhellthing93/sf.mephi.go_homework/config"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+)
+
+// openTestDB подключается к БД из TEST_DATABASE_URL и создает схему. Без переменной тест пропускается
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	if err := config.InitDB(db); err != nil {
+		t.Fatalf("failed to init test database: %v", err)
+	}
+	return db
+}
+
+func createTestUser(t *testing.T, db *sql.DB) uint {
+	t.Helper()
+	userRepo := repositories.NewUserRepository(db)
+	email := fmt.Sprintf("test-%d@example.com", time.Now().UnixNano())
+	err := userRepo.CreateUser(&models.User{Email: email, Username: email, Password: "x", CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	user, err := userRepo.GetUserByEmail(email)
+	if err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	return user.ID
+}
+
+func createTestAccount(t *testing.T, db *sql.DB, userID uint) *models.Account {
+	t.Helper()
+	account := &models.Account{
+		UserID:    userID,
+		Balance:   models.NewMoney(0, models.DefaultCurrency),
+		Currency:  models.DefaultCurrency,
+		CreatedAt: time.Now(),
+	}
+	if err := repositories.NewAccountRepository(db).CreateAccount(account); err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	return account
+}
+
+// TestConcurrentDebitsNeverOverdraw списывает со счета параллельными переводами и расходными операциями
+// на сумму, втрое превышающую остаток: успешных списаний должно быть ровно столько, сколько покрывает остаток,
+// а баланс счета ни в какой момент не должен становиться отрицательным
+func TestConcurrentDebitsNeverOverdraw(t *testing.T) {
+	db := openTestDB(t)
+	db.SetMaxOpenConns(20)
+
+	userID := createTestUser(t, db)
+	from := createTestAccount(t, db, userID)
+	to := createTestAccount(t, db, userID)
+
+	accountRepo := repositories.NewAccountRepository(db)
+	uow := repositories.NewUnitOfWork(db)
+	transferService := NewTransferService(repositories.NewTransferRepository(db), accountRepo, uow, nil)
+	transactionService := NewTransactionService(repositories.NewTransactionRepository(db), accountRepo, uow)
+
+	initial := models.NewMoney(100_00, models.DefaultCurrency)
+	debit := models.NewMoney(3_00, models.DefaultCurrency)
+	if _, err := transactionService.CreateTransaction(from.ID, initial, "income", "test", "funding"); err != nil {
+		t.Fatalf("failed to fund account: %v", err)
+	}
+
+	// Следим за балансом, пока идут списания
+	stop := make(chan struct{})
+	var watcher sync.WaitGroup
+	var minBalance atomic.Int64
+	watcher.Add(1)
+	go func() {
+		defer watcher.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			account, err := accountRepo.GetAccountByID(from.ID)
+			if err != nil {
+				continue
+			}
+			if account.Balance.Minor() < minBalance.Load() {
+				minBalance.Store(account.Balance.Minor())
+			}
+		}
+	}()
+
+	const workers = 50
+	var transfers, expenses atomic.Int64
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := transferService.CreateTransfer(from.ID, to.ID, debit, "concurrent transfer")
+			if err == nil {
+				transfers.Add(1)
+			} else if !strings.Contains(err.Error(), "insufficient funds") {
+				errs <- fmt.Errorf("transfer: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := transactionService.CreateTransaction(from.ID, debit, "expense", "test", "concurrent expense")
+			if err == nil {
+				expenses.Add(1)
+			} else if !strings.Contains(err.Error(), "insufficient funds") {
+				errs <- fmt.Errorf("transaction: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	watcher.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// 100.00 покрывает 33 списания по 3.00, на счете остается 1.00
+	succeeded := transfers.Load() + expenses.Load()
+	if succeeded != 33 {
+		t.Errorf("succeeded debits = %d, want 33", succeeded)
+	}
+	if minBalance.Load() < 0 {
+		t.Errorf("account balance went negative: %d", minBalance.Load())
+	}
+
+	fromAccount, err := accountRepo.GetAccountByID(from.ID)
+	if err != nil {
+		t.Fatalf("failed to get account: %v", err)
+	}
+	if want := initial.Minor() - succeeded*debit.Minor(); fromAccount.Balance.Minor() != want {
+		t.Errorf("final balance = %s, want %d minor units", fromAccount.Balance, want)
+	}
+	if fromAccount.Balance.IsNegative() {
+		t.Errorf("final balance is negative: %s", fromAccount.Balance)
+	}
+
+	toAccount, err := accountRepo.GetAccountByID(to.ID)
+	if err != nil {
+		t.Fatalf("failed to get account: %v", err)
+	}
+	if want := transfers.Load() * debit.Minor(); toAccount.Balance.Minor() != want {
+		t.Errorf("receiver balance = %s, want %d minor units", toAccount.Balance, want)
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+var (
+	ErrApplicationNotFound    = errors.New("credit application not found")
+	ErrApplicationNotApproved = errors.New("credit application is not approved")
+)
+
+type CreditApplicationService struct {
+	repo        *repositories.CreditApplicationRepository
+	accountRepo *repositories.AccountRepository
+	productRepo *repositories.CreditProductRepository
+	cbrService  *CBRService
+	scorer      CreditScorer
+}
+
+func NewCreditApplicationService(repo *repositories.CreditApplicationRepository, accountRepo *repositories.AccountRepository, productRepo *repositories.CreditProductRepository, cbrService *CBRService, scorer CreditScorer) *CreditApplicationService {
+	return &CreditApplicationService{
+		repo:        repo,
+		accountRepo: accountRepo,
+		productRepo: productRepo,
+		cbrService:  cbrService,
+		scorer:      scorer,
+	}
+}
+
+// Submit принимает заявку на кредит по продукту productID (0 — продукт по умолчанию) с зачислением
+// на рублевый счет accountID и сразу передает ее на оценку. Если оценку выполнить не удалось,
+// заявка остается в статусе "submitted" и оценивается повторно планировщиком
+func (s *CreditApplicationService) Submit(userID, productID, accountID uint, amount models.Money, term int, scheduleType string) (*models.CreditApplication, error) {
+	amount = amount.WithCurrency(models.DefaultCurrency)
+
+	scheduleType, err := normalizeScheduleType(scheduleType)
+	if err != nil {
+		return nil, err
+	}
+	product, err := findCreditProduct(s.productRepo, productID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := priceCredit(product, amount, term); err != nil {
+		return nil, err
+	}
+
+	if accountID == 0 {
+		return nil, validationError("account_id is required")
+	}
+	account, err := s.accountRepo.GetAccountByID(accountID)
+	if err != nil || account.UserID != userID {
+		return nil, validationError("account %d not found", accountID)
+	}
+	if account.Currency != models.DefaultCurrency {
+		return nil, validationError("credit can only be disbursed to a %s account", models.DefaultCurrency)
+	}
+
+	now := time.Now()
+	application := &models.CreditApplication{
+		UserID:       userID,
+		ProductID:    product.ID,
+		AccountID:    accountID,
+		Amount:       amount,
+		Term:         term,
+		ScheduleType: scheduleType,
+		Status:       models.ApplicationSubmitted,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.repo.CreateApplication(application); err != nil {
+		return nil, err
+	}
+
+	if err := s.score(application); err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"error":          err.Error(),
+			"application.ID": application.ID,
+		}).Warn("Failed to score credit application")
+	}
+	return application, nil
+}
+
+// ScoreSubmitted повторно оценивает заявки, оценку которых не удалось выполнить при подаче
+func (s *CreditApplicationService) ScoreSubmitted() error {
+	applications, err := s.repo.GetApplicationsByStatus(models.ApplicationSubmitted)
+	if err != nil {
+		return err
+	}
+
+	for i := range applications {
+		if err := s.score(&applications[i]); err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"error":          err.Error(),
+				"application.ID": applications[i].ID,
+			}).Warn("Failed to score credit application")
+		}
+	}
+	return nil
+}
+
+// score переводит заявку в статус "scoring", оценивает ее и сохраняет решение.
+// При ошибке оценки заявка возвращается в статус "submitted"
+func (s *CreditApplicationService) score(application *models.CreditApplication) error {
+	ok, err := s.repo.TransitionStatus(application.ID, models.ApplicationSubmitted, models.ApplicationScoring)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("credit application %d is already being scored", application.ID)
+	}
+	application.Status = models.ApplicationScoring
+
+	result, err := s.evaluate(application)
+	if err != nil {
+		if _, revertErr := s.repo.TransitionStatus(application.ID, models.ApplicationScoring, models.ApplicationSubmitted); revertErr != nil {
+			return fmt.Errorf("%v; failed to return application to submitted: %v", err, revertErr)
+		}
+		application.Status = models.ApplicationSubmitted
+		return err
+	}
+
+	application.Score = &result.Score
+	application.DebtToIncome = &result.DebtToIncome
+	if result.Approved {
+		application.Status = models.ApplicationApproved
+	} else {
+		application.Status = models.ApplicationRejected
+		application.RejectReason = result.Reason
+	}
+	return s.repo.SaveDecision(application)
+}
+
+// evaluate рассчитывает платеж по будущему кредиту по текущей ставке и передает заявку модулю оценки
+func (s *CreditApplicationService) evaluate(application *models.CreditApplication) (*ScoringResult, error) {
+	product, err := findCreditProduct(s.productRepo, application.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	pricing, err := priceCredit(product, application.Amount, application.Term)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := s.cbrService.GetCentralBankRate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CBR rate: %v", err)
+	}
+
+	// Для дифференцированного графика наибольший платеж — первый
+	schedule := repositories.PreviewPaymentSchedule(&models.Credit{
+		Amount:       application.Amount,
+		InterestRate: rate + pricing.Spread,
+		Term:         application.Term,
+		ScheduleType: application.ScheduleType,
+	})
+	var monthlyPayment models.Money
+	for _, row := range schedule {
+		if row.Amount.GreaterThan(monthlyPayment) {
+			monthlyPayment = row.Amount
+		}
+	}
+
+	return s.scorer.Score(ScoringRequest{
+		UserID:         application.UserID,
+		Amount:         application.Amount,
+		Term:           application.Term,
+		MonthlyPayment: monthlyPayment,
+	})
+}
+
+func (s *CreditApplicationService) GetApplications(userID uint) ([]models.CreditApplication, error) {
+	return s.repo.GetApplicationsByUserID(userID)
+}
+
+// GetApplication возвращает заявку пользователя или ErrApplicationNotFound
+func (s *CreditApplicationService) GetApplication(userID, applicationID uint) (*models.CreditApplication, error) {
+	application, err := s.repo.GetApplicationByID(applicationID)
+	if err != nil {
+		return nil, err
+	}
+	if application == nil || application.UserID != userID {
+		return nil, ErrApplicationNotFound
+	}
+	return application, nil
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+// ErrCertificateNotFound — справка о погашении не выпущена: кредит еще не погашен
+var ErrCertificateNotFound = errors.New("closure certificate not found, credit is not repaid")
+
+// GetPayoffQuote рассчитывает сумму, которой кредит погашается полностью на дату date (nil — сегодня).
+// К текущей задолженности добавляются проценты по платежам графика с датой не позже date, проценты
+// текущего периода пропорционально прошедшим к date дням и неустойка, которая будет начислена
+// по просроченным к этой дате платежам, если до нее не вносить платежи.
+// Для сегодняшней даты сумма совпадает с той, что примет досрочное погашение (early_repayment)
+func (s *PaymentService) GetPayoffQuote(creditID uint, date *time.Time) (*models.PayoffQuote, error) {
+	now := time.Now()
+	asOf := now
+	if date != nil {
+		day := dateOnly(*date)
+		if day.Before(dateOnly(now)) {
+			return nil, validationError("payoff date must not be in the past")
+		}
+		// На будущую дату учитывается все, что наступит до конца дня
+		if day.After(dateOnly(now)) {
+			asOf = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+
+	credit, err := s.creditRepo.GetCreditByID(creditID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credit: %v", err)
+	}
+	if credit.Status == models.CreditClosed {
+		return nil, validationError("credit is already paid off")
+	}
+
+	schedule, err := s.creditRepo.GetPaymentSchedule(creditID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment schedule: %v", err)
+	}
+	balance := credit.Outstanding
+	var unpaid []models.PaymentSchedule
+	for _, row := range schedule {
+		if row.IsPaid {
+			continue
+		}
+		unpaid = append(unpaid, row)
+		if !row.InterestAccrued && !row.DueDate.After(asOf) {
+			balance.Interest = balance.Interest.Add(row.Interest)
+		}
+	}
+	_, interest := periodInterest(unpaid, asOf)
+	balance.Interest = balance.Interest.Add(interest)
+
+	payments, err := s.repo.GetOpenScheduledPayments(creditID)
+	if err != nil {
+		return nil, err
+	}
+	policy := loadPenaltyPolicy()
+	for _, payment := range payments {
+		if !payment.PaymentDate.Before(asOf) {
+			break
+		}
+		accrued, lastAccrued, err := s.penaltyRepo.GetAccrued(payment.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, accrual := range policy.accruals(payment.Amount, payment.PaymentDate, asOf, lastAccrued, accrued) {
+			balance.Penalties = balance.Penalties.Add(accrual.Amount)
+		}
+	}
+
+	return &models.PayoffQuote{
+		CreditID:      creditID,
+		Date:          dateOnly(asOf),
+		CreditBalance: balance,
+		Total:         balance.Total().WithCurrency(models.DefaultCurrency),
+	}, nil
+}
+
+// periodInterest возвращает строку графика текущего процентного периода — первую непогашенную строку
+// с датой платежа после asOf — и проценты по ней, накопленные к asOf. Период — месяц до даты платежа,
+// проценты строки распределяются по его дням равномерно, день asOf в расчет не входит.
+// Если проценты по строке уже начислены или период еще не начался, возвращается нулевая сумма
+func periodInterest(unpaid []models.PaymentSchedule, asOf time.Time) (*models.PaymentSchedule, models.Money) {
+	for i := range unpaid {
+		row := &unpaid[i]
+		if row.IsPaid || !row.DueDate.After(asOf) {
+			continue
+		}
+		if row.InterestAccrued {
+			return row, models.Money{}
+		}
+
+		end := dateOnly(row.DueDate)
+		start := end.AddDate(0, -1, 0)
+		elapsed := daysBetween(start, dateOnly(asOf))
+		if elapsed <= 0 {
+			return row, models.Money{}
+		}
+		total := daysBetween(start, end)
+		if elapsed > total {
+			elapsed = total
+		}
+		return row, row.Interest.MulRat(big.NewRat(int64(elapsed), int64(total)), models.DefaultRounding)
+	}
+	return nil, models.Money{}
+}
+
+// daysBetween возвращает число календарных дней от from до to
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+// GetClosureCertificate возвращает справку о полном погашении кредита
+func (s *PaymentService) GetClosureCertificate(creditID uint) (*models.ClosureCertificate, error) {
+	certificate, err := s.certificateRepo.GetCertificateByCreditID(creditID)
+	if err != nil {
+		return nil, err
+	}
+	if certificate == nil {
+		return nil, ErrCertificateNotFound
+	}
+	return certificate, nil
+}
+
+// closeCredit закрывает погашенный кредит и выпускает справку о погашении с подписью банка
+func (s *PaymentService) closeCredit(tx *repositories.Tx, credit *models.Credit, closedAt time.Time) error {
+	if err := tx.Credits.CloseCredit(credit.ID); err != nil {
+		return err
+	}
+	credit.Status = models.CreditClosed
+
+	payments, err := tx.Payments.GetPaymentsByCreditID(credit.ID)
+	if err != nil {
+		return err
+	}
+	totalPaid := models.NewMoney(0, models.DefaultCurrency)
+	for _, payment := range payments {
+		if payment.Status == "completed" {
+			totalPaid = totalPaid.Add(payment.Amount)
+		}
+	}
+
+	user, err := s.userRepo.GetUserByID(credit.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %v", err)
+	}
+
+	certificate := &models.ClosureCertificate{
+		CreditID:  credit.ID,
+		Number:    fmt.Sprintf("%d-%s", credit.ID, closedAt.Format("20060102")),
+		ClosedAt:  closedAt,
+		TotalPaid: totalPaid,
+		CreatedAt: closedAt,
+	}
+	certificate.Document, err = utils.SignPGP(closureCertificateText(certificate, credit, user))
+	if err != nil {
+		return fmt.Errorf("failed to sign closure certificate: %v", err)
+	}
+	return tx.Certificates.CreateCertificate(certificate)
+}
+
+// sendClosureCertificate отправляет заемщику справку о погашении кредита, если она выпущена и еще не отправлена.
+// Ошибки только логируются: платеж к этому моменту уже проведен
+func (s *PaymentService) sendClosureCertificate(creditID uint) {
+	certificate, err := s.certificateRepo.GetCertificateByCreditID(creditID)
+	if err != nil || certificate == nil || certificate.EmailedAt != nil {
+		return
+	}
+
+	credit, err := s.creditRepo.GetCreditByID(creditID)
+	if err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"error":     err.Error(),
+			"credit.ID": creditID,
+		}).Error("Failed to get credit for closure certificate")
+		return
+	}
+	user, err := s.userRepo.GetUserByID(credit.UserID)
+	if err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"error":     err.Error(),
+			"credit.ID": creditID,
+		}).Error("Failed to get user for closure certificate")
+		return
+	}
+
+	if err := s.smtpService.SendClosureCertificate(user.Email, certificate); err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"error":     err.Error(),
+			"credit.ID": creditID,
+		}).Warn("Failed to send closure certificate")
+		return
+	}
+	if err := s.certificateRepo.MarkEmailed(certificate.ID, time.Now()); err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"error":     err.Error(),
+			"credit.ID": creditID,
+		}).Error("Failed to mark closure certificate emailed")
+	}
+}
+
+// closureCertificateText формирует текст справки о полном погашении кредита
+func closureCertificateText(certificate *models.ClosureCertificate, credit *models.Credit, user *models.User) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "СПРАВКА О ПОЛНОМ ПОГАШЕНИИ КРЕДИТА № %s\n\n", certificate.Number)
+	fmt.Fprintf(&b, "Заемщик: %s (%s)\n", user.Username, user.Email)
+	fmt.Fprintf(&b, "Кредит № %d от %s\n", credit.ID, credit.CreatedAt.Format("02.01.2006"))
+	fmt.Fprintf(&b, "Процентная ставка: %.2f%%\n", credit.InterestRate)
+	fmt.Fprintf(&b, "Всего внесено платежей: %s %s\n", certificate.TotalPaid, models.DefaultCurrency)
+	fmt.Fprintf(&b, "Дата полного погашения: %s\n\n", certificate.ClosedAt.Format("02.01.2006"))
+	b.WriteString("Задолженность по кредиту отсутствует, обязательства заемщика исполнены в полном объеме.\n")
+	fmt.Fprintf(&b, "Дата выдачи справки: %s\n", certificate.CreatedAt.Format("02.01.2006 15:04:05"))
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+func TestPeriodInterest(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatalf("bad test date %q", s)
+		}
+		return d
+	}
+	row := func(due string, interest int64, accrued bool) models.PaymentSchedule {
+		return models.PaymentSchedule{DueDate: date(due), Interest: rub(interest), InterestAccrued: accrued}
+	}
+
+	// Период 10.03–10.04 — 31 день, проценты строки 310.00, то есть 10.00 в день
+	schedule := []models.PaymentSchedule{
+		row("2025-03-10", 300_00, true),
+		row("2025-04-10", 310_00, false),
+		row("2025-05-10", 290_00, false),
+	}
+	tests := []struct {
+		name     string
+		schedule []models.PaymentSchedule
+		asOf     string
+		wantDue  string // дата строки текущего периода, пусто — строки нет
+		interest int64
+	}{
+		{name: "first day of period", schedule: schedule, asOf: "2025-03-10", wantDue: "2025-04-10", interest: 0},
+		{name: "one day elapsed", schedule: schedule, asOf: "2025-03-11", wantDue: "2025-04-10", interest: 10_00},
+		{name: "mid period", schedule: schedule, asOf: "2025-03-25", wantDue: "2025-04-10", interest: 150_00},
+		{name: "day before due", schedule: schedule, asOf: "2025-04-09", wantDue: "2025-04-10", interest: 300_00},
+		// В дату платежа проценты строки уже наступили, текущим становится следующий период
+		{name: "due date", schedule: schedule, asOf: "2025-04-10", wantDue: "2025-05-10", interest: 0},
+		// Период строки еще не начался: кредит выдан позже, чем за месяц до первого платежа
+		{name: "before period start", schedule: schedule[1:], asOf: "2025-03-01", wantDue: "2025-04-10", interest: 0},
+		{name: "interest already accrued", schedule: []models.PaymentSchedule{row("2025-04-10", 310_00, true)}, asOf: "2025-03-25", wantDue: "2025-04-10", interest: 0},
+		{name: "after last due date", schedule: schedule, asOf: "2025-06-01"},
+		{name: "empty schedule", asOf: "2025-03-25"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Время суток не влияет на число прошедших дней
+			got, interest := periodInterest(tt.schedule, date(tt.asOf).Add(15*time.Hour))
+			if tt.wantDue == "" {
+				if got != nil || !interest.IsZero() {
+					t.Fatalf("periodInterest = %+v, %s; want no row", got, interest)
+				}
+				return
+			}
+			if got == nil || !got.DueDate.Equal(date(tt.wantDue)) {
+				t.Fatalf("periodInterest row = %+v, want due %s", got, tt.wantDue)
+			}
+			if interest.Minor() != tt.interest {
+				t.Errorf("periodInterest = %s, want %d minor units", interest, tt.interest)
+			}
+		})
+	}
+}
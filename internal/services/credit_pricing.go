@@ -0,0 +1,83 @@
+package services
+
+import (
+	"slices"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+)
+
+// defaultCreditProductCode — продукт, по которому оформляется кредит, если product_id не указан
+const defaultCreditProductCode = "consumer"
+
+// creditPricing — условия кредита, рассчитанные по правилам продукта
+type creditPricing struct {
+	Spread float64      // надбавка к ключевой ставке в процентных пунктах
+	Fee    models.Money // комиссия за выдачу
+}
+
+// priceCredit проверяет сумму и срок по ограничениям продукта и подбирает спред и комиссию
+func priceCredit(product *models.CreditProduct, amount models.Money, term int) (*creditPricing, error) {
+	if !product.IsActive {
+		return nil, validationError("credit product %s is not available", product.Code)
+	}
+	if amount.LessThan(product.MinAmount) || amount.GreaterThan(product.MaxAmount) {
+		return nil, validationError("amount must be between %s and %s for product %s", product.MinAmount, product.MaxAmount, product.Code)
+	}
+	if !slices.Contains(product.Terms, term) {
+		return nil, validationError("term %d is not allowed for product %s, allowed terms: %v", term, product.Code, product.Terms)
+	}
+
+	// Из подходящих строк таблицы выбираем самую узкую по сроку, затем по сумме
+	var matched *models.CreditProductSpread
+	for i := range product.Spreads {
+		spread := &product.Spreads[i]
+		if term < spread.MinTerm || term > spread.MaxTerm || amount.LessThan(spread.MinAmount) || amount.GreaterThan(spread.MaxAmount) {
+			continue
+		}
+		if matched == nil || spread.MaxTerm-spread.MinTerm < matched.MaxTerm-matched.MinTerm ||
+			(spread.MaxTerm-spread.MinTerm == matched.MaxTerm-matched.MinTerm && spread.MaxAmount.Sub(spread.MinAmount).LessThan(matched.MaxAmount.Sub(matched.MinAmount))) {
+			matched = spread
+		}
+	}
+	if matched == nil {
+		return nil, validationError("no pricing rule for amount %s and term %d in product %s", amount, term, product.Code)
+	}
+
+	fee := amount.Percent(product.IssueFeePercent, models.DefaultRounding).Add(product.IssueFeeFixed.WithCurrency(amount.Currency()))
+	if !fee.LessThan(amount) {
+		return nil, validationError("issue fee %s exceeds credit amount", fee)
+	}
+
+	return &creditPricing{Spread: matched.Spread, Fee: fee}, nil
+}
+
+// findCreditProduct возвращает продукт productID (0 — продукт по умолчанию)
+func findCreditProduct(repo *repositories.CreditProductRepository, productID uint) (*models.CreditProduct, error) {
+	var product *models.CreditProduct
+	var err error
+	if productID == 0 {
+		product, err = repo.GetProductByCode(defaultCreditProductCode)
+	} else {
+		product, err = repo.GetProductByID(productID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if product == nil {
+		return nil, validationError("credit product %d not found", productID)
+	}
+	return product, nil
+}
+
+// normalizeScheduleType проверяет тип графика платежей, пустое значение означает аннуитетный график
+func normalizeScheduleType(scheduleType string) (string, error) {
+	switch scheduleType {
+	case "":
+		return models.ScheduleAnnuity, nil
+	case models.ScheduleAnnuity, models.ScheduleDifferentiated:
+		return scheduleType, nil
+	default:
+		return "", validationError("schedule_type must be %q or %q", models.ScheduleAnnuity, models.ScheduleDifferentiated)
+	}
+}
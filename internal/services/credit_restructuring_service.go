@@ -0,0 +1,157 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+// ErrCreditNotFound — кредит не существует
+var ErrCreditNotFound = errors.New("credit not found")
+
+// Ограничения реструктуризации за одну операцию
+const (
+	maxGraceMonths  = 12
+	maxExtendMonths = 60
+)
+
+// RestructuringRequest — параметры реструктуризации: кредитные каникулы на GraceMonths месяцев,
+// увеличение срока на ExtendMonths платежей и новая ставка InterestRate (nil — без изменения ставки)
+type RestructuringRequest struct {
+	GraceMonths  int      `json:"grace_months"`
+	ExtendMonths int      `json:"extend_months"`
+	InterestRate *float64 `json:"interest_rate"`
+	Reason       string   `json:"reason"`
+}
+
+type CreditRestructuringService struct {
+	repo        *repositories.CreditRestructuringRepository
+	creditRepo  *repositories.CreditRepository
+	userRepo    *repositories.UserRepository
+	smtpService *SMTPService
+	uow         *repositories.UnitOfWork
+}
+
+func NewCreditRestructuringService(repo *repositories.CreditRestructuringRepository, creditRepo *repositories.CreditRepository, userRepo *repositories.UserRepository, smtpService *SMTPService, uow *repositories.UnitOfWork) *CreditRestructuringService {
+	return &CreditRestructuringService{
+		repo:        repo,
+		creditRepo:  creditRepo,
+		userRepo:    userRepo,
+		smtpService: smtpService,
+		uow:         uow,
+	}
+}
+
+// RestructureCredit предоставляет кредитные каникулы или реструктурирует кредит по решению сотрудника поддержки operatorID.
+// Будущие платежи графика переносятся и пересчитываются, действовавший график сохраняется в архиве
+// под номером версии, заемщик получает уведомление о новых условиях
+func (s *CreditRestructuringService) RestructureCredit(creditID, operatorID uint, request RestructuringRequest) (*models.CreditRestructuring, error) {
+	if request.GraceMonths < 0 || request.GraceMonths > maxGraceMonths {
+		return nil, validationError("grace_months must be between 0 and %d", maxGraceMonths)
+	}
+	if request.ExtendMonths < 0 || request.ExtendMonths > maxExtendMonths {
+		return nil, validationError("extend_months must be between 0 and %d", maxExtendMonths)
+	}
+	if request.InterestRate != nil && *request.InterestRate < 0 {
+		return nil, validationError("interest_rate must not be negative")
+	}
+	if request.GraceMonths == 0 && request.ExtendMonths == 0 && request.InterestRate == nil {
+		return nil, validationError("nothing to restructure")
+	}
+
+	var restructuring *models.CreditRestructuring
+	var schedule []models.PaymentSchedule
+	var credit *models.Credit
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		var err error
+		credit, err = tx.Credits.GetCreditForUpdate(creditID)
+		if err == sql.ErrNoRows {
+			return ErrCreditNotFound
+		} else if err != nil {
+			return err
+		}
+		if credit.Status == models.CreditClosed {
+			return validationError("credit is already paid off")
+		}
+
+		// Действовавший график сохраняется до перестроения
+		previous, err := tx.Credits.GetPaymentSchedule(creditID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if !hasFutureInstallments(previous, now) {
+			return validationError("credit has no future installments to restructure")
+		}
+
+		restructuring = &models.CreditRestructuring{
+			CreditID:         creditID,
+			OperatorID:       &operatorID,
+			GraceMonths:      request.GraceMonths,
+			ExtendMonths:     request.ExtendMonths,
+			OldInterestRate:  credit.InterestRate,
+			NewInterestRate:  credit.InterestRate,
+			OldTerm:          credit.Term,
+			Reason:           request.Reason,
+			PreviousSchedule: previous,
+			CreatedAt:        now,
+		}
+		if request.InterestRate != nil {
+			credit.InterestRate = *request.InterestRate
+			restructuring.NewInterestRate = *request.InterestRate
+		}
+
+		schedule, err = tx.Credits.RestructureSchedule(credit, request.GraceMonths, request.ExtendMonths, now)
+		if err != nil {
+			return err
+		}
+		restructuring.NewTerm = credit.Term
+		return tx.Restructurings.CreateRestructuring(restructuring)
+	})
+	if errors.Is(err, ErrValidation) || errors.Is(err, ErrCreditNotFound) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to restructure credit: %v", err)
+	}
+
+	user, err := s.userRepo.GetUserByID(credit.UserID)
+	if err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"credit_id": creditID,
+			"error":     err.Error(),
+		}).Warn("Failed to get borrower for restructuring notification")
+		return restructuring, nil
+	}
+	if err := s.smtpService.SendRestructuringNotification(user.Email, restructuring, schedule[0]); err != nil {
+		utils.Log.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to send restructuring notification")
+	}
+
+	return restructuring, nil
+}
+
+// hasFutureInstallments проверяет, есть ли в графике непогашенные строки, срок которых еще не наступил
+func hasFutureInstallments(schedule []models.PaymentSchedule, now time.Time) bool {
+	for _, row := range schedule {
+		if !row.IsPaid && !row.InterestAccrued && row.DueDate.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRestructurings возвращает историю реструктуризаций кредита с архивными версиями графика
+func (s *CreditRestructuringService) GetRestructurings(creditID uint) ([]models.CreditRestructuring, error) {
+	if _, err := s.creditRepo.GetCreditByID(creditID); err != nil {
+		return nil, ErrCreditNotFound
+	}
+	return s.repo.GetRestructuringsByCreditID(creditID)
+}
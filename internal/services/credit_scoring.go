@@ -0,0 +1,95 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+)
+
+// ScoringRequest — данные заявки, передаваемые модулю оценки кредитоспособности
+type ScoringRequest struct {
+	UserID         uint
+	Amount         models.Money
+	Term           int
+	MonthlyPayment models.Money // наибольший ежемесячный платеж по новому кредиту
+}
+
+// ScoringResult — решение модуля оценки
+type ScoringResult struct {
+	Approved     bool
+	Score        int     // 0–100, чем выше, тем надежнее заемщик
+	DebtToIncome float64 // доля ежемесячных платежей в доходе с учетом нового кредита, в процентах
+	Reason       string  // причина отказа
+}
+
+// CreditScorer оценивает кредитоспособность заявителя. Реализацию можно заменить,
+// передав другую в NewCreditApplicationService
+type CreditScorer interface {
+	Score(request ScoringRequest) (*ScoringResult, error)
+}
+
+// DebtToIncomeScorer оценивает заявку по показателю долговой нагрузки: среднемесячные поступления
+// на рублевые счета за последние months месяцев сравниваются с платежами по действующим кредитам
+// и новому кредиту. Заявка отклоняется, если нагрузка превышает maxDebtToIncome процентов
+type DebtToIncomeScorer struct {
+	analyticsRepo   *repositories.AnalyticsRepository
+	months          int
+	maxDebtToIncome float64
+}
+
+// NewDebtToIncomeScorer читает период оценки CREDIT_SCORING_MONTHS и предельную долговую нагрузку
+// CREDIT_MAX_DEBT_TO_INCOME из переменных окружения
+func NewDebtToIncomeScorer(analyticsRepo *repositories.AnalyticsRepository) *DebtToIncomeScorer {
+	months := envInt("CREDIT_SCORING_MONTHS", 3)
+	if months == 0 {
+		months = 3
+	}
+	maxDebtToIncome, err := strconv.ParseFloat(os.Getenv("CREDIT_MAX_DEBT_TO_INCOME"), 64)
+	if err != nil || maxDebtToIncome <= 0 {
+		maxDebtToIncome = 50
+	}
+
+	return &DebtToIncomeScorer{
+		analyticsRepo:   analyticsRepo,
+		months:          months,
+		maxDebtToIncome: maxDebtToIncome,
+	}
+}
+
+func (s *DebtToIncomeScorer) Score(request ScoringRequest) (*ScoringResult, error) {
+	now := time.Now()
+	turnover, err := s.analyticsRepo.GetIncomingTurnover(request.UserID, models.DefaultCurrency, now.AddDate(0, -s.months, 0), now)
+	if err != nil {
+		return nil, err
+	}
+	if !turnover.IsPositive() {
+		return &ScoringResult{Reason: fmt.Sprintf("no incoming turnover on %s accounts in the last %d months", models.DefaultCurrency, s.months)}, nil
+	}
+	income := turnover.DivInt(int64(s.months), models.DefaultRounding)
+
+	load, err := s.analyticsRepo.GetCreditLoad(request.UserID)
+	if err != nil {
+		return nil, err
+	}
+	payments := load.MonthlyPayment.Add(request.MonthlyPayment)
+
+	ratio, _ := new(big.Rat).Quo(payments.Rat(), income.Rat()).Float64()
+	debtToIncome := math.Round(ratio*100*100) / 100
+
+	result := &ScoringResult{
+		DebtToIncome: debtToIncome,
+		Score:        int(math.Max(0, math.Round(100-debtToIncome))),
+	}
+	if debtToIncome > s.maxDebtToIncome {
+		result.Reason = fmt.Sprintf("debt-to-income ratio %.2f%% exceeds the limit of %.2f%%", debtToIncome, s.maxDebtToIncome)
+		return result, nil
+	}
+	result.Approved = true
+	return result, nil
+}
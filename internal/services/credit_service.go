@@ -2,9 +2,8 @@
 package services
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -16,43 +15,112 @@ import (
 type CreditService struct {
 	repo         *repositories.CreditRepository
 	userRepo 	 *repositories.UserRepository
+	accountRepo   *repositories.AccountRepository
+	applicationRepo *repositories.CreditApplicationRepository
+	productRepo   *repositories.CreditProductRepository
 	cbrService    *CBRService
 	smtpService   *SMTPService
+	uow           *repositories.UnitOfWork
 }
 
-func NewCreditService(repo *repositories.CreditRepository, userRepo *repositories.UserRepository, cbrService *CBRService, smtpService *SMTPService) *CreditService {
+func NewCreditService(repo *repositories.CreditRepository, userRepo *repositories.UserRepository, accountRepo *repositories.AccountRepository, applicationRepo *repositories.CreditApplicationRepository, productRepo *repositories.CreditProductRepository, cbrService *CBRService, smtpService *SMTPService, uow *repositories.UnitOfWork) *CreditService {
 	return &CreditService{
 		repo:         repo,
 		userRepo: 	  userRepo,
+		accountRepo:   accountRepo,
+		applicationRepo: applicationRepo,
+		productRepo:   productRepo,
 		cbrService:    cbrService,
 		smtpService:   smtpService,
+		uow:           uow,
 	}
 }
 
-func (s *CreditService) CreateCredit(userID uint, amount float64, term int) (*models.Credit, error) {
-	// Получаем константу увеличения ставки из .env
-	incrementStr := os.Getenv("CB_RATE_INCREMENT")
-	increment, err := strconv.ParseFloat(incrementStr, 64)
+// IssueCredit выдает кредит по одобренной заявке applicationID и зачисляет его за вычетом комиссии
+// на указанный в заявке счет. Ставка равна ключевой ставке ЦБ РФ на момент выдачи плюс спред продукта
+// для суммы и срока заявки
+func (s *CreditService) IssueCredit(userID, applicationID uint) (*models.Credit, error) {
+	application, err := s.applicationRepo.GetApplicationByID(applicationID)
 	if err != nil {
-		increment = 2.5 // Значение по умолчанию
+		return nil, err
 	}
-	
+	if application == nil || application.UserID != userID {
+		return nil, ErrApplicationNotFound
+	}
+	if application.Status != models.ApplicationApproved {
+		return nil, fmt.Errorf("%w: application status is %s", ErrApplicationNotApproved, application.Status)
+	}
+
+	product, err := findCreditProduct(s.productRepo, application.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	amount := application.Amount.WithCurrency(models.DefaultCurrency)
+	pricing, err := priceCredit(product, amount, application.Term)
+	if err != nil {
+		return nil, err
+	}
+
 	// Получаем текущую ставку ЦБ РФ
 	rate, err := s.cbrService.GetCentralBankRate()
-	rate += increment
 	if err != nil {
 		return nil, fmt.Errorf("failed to get CBR rate: %v", err)
 	}
+	rate += pricing.Spread
 
+	accountID := application.AccountID
 	credit := &models.Credit{
 		UserID:       userID,
+		ProductID:    &product.ID,
+		AccountID:    &accountID,
 		Amount:       amount,
 		InterestRate: rate,
-		Term:         term,
+		Term:         application.Term,
+		ScheduleType: application.ScheduleType,
+		Fee:          pricing.Fee,
 		CreatedAt:    time.Now(),
 	}
 
-	if err := s.repo.CreateCredit(credit); err != nil {
+	// Кредит, график платежей, зачисление на счет, проводки по выдаче и статус заявки
+	// меняются в одной транзакции
+	err = s.uow.Do(func(tx *repositories.Tx) error {
+		// Блокируем заявку, чтобы по ней нельзя было выдать второй кредит
+		locked, err := tx.Applications.GetApplicationForUpdate(applicationID)
+		if err != nil {
+			return err
+		}
+		if locked == nil {
+			return ErrApplicationNotFound
+		}
+		if locked.Status != models.ApplicationApproved {
+			return fmt.Errorf("%w: application status is %s", ErrApplicationNotApproved, locked.Status)
+		}
+
+		account, err := tx.Accounts.GetAccountForUpdate(accountID)
+		if err != nil {
+			return fmt.Errorf("failed to get account: %v", err)
+		}
+		if account.UserID != userID {
+			return validationError("account %d not found", accountID)
+		}
+		if account.Currency != models.DefaultCurrency {
+			return validationError("credit can only be disbursed to a %s account", models.DefaultCurrency)
+		}
+
+		if err := tx.Credits.CreateCredit(credit); err != nil {
+			return err
+		}
+		if err := tx.Accounts.AdjustBalance(accountID, amount.Sub(credit.Fee)); err != nil {
+			return err
+		}
+		if err := tx.Ledger.PostEntry(creditDisbursementEntry(credit)); err != nil {
+			return fmt.Errorf("failed to post credit disbursement to ledger: %v", err)
+		}
+		return tx.Applications.MarkIssued(applicationID, credit.ID)
+	})
+	if errors.Is(err, ErrValidation) || errors.Is(err, ErrApplicationNotFound) || errors.Is(err, ErrApplicationNotApproved) {
+		return nil, err
+	} else if err != nil {
 		return nil, fmt.Errorf("failed to create credit: %v", err)
 	}
 
@@ -63,7 +131,7 @@ func (s *CreditService) CreateCredit(userID uint, amount float64, term int) (*mo
 	}
 
 	// Отправляем уведомление
-	if err := s.smtpService.SendCreditNotification(user.Email, amount, rate, term); err != nil {
+	if err := s.smtpService.SendCreditNotification(user.Email, amount, rate, credit.Term); err != nil {
 		utils.Log.WithFields(logrus.Fields{
 			"error": err.Error(),
 		}).Warn("Failed to send credit notification")
@@ -72,6 +140,38 @@ func (s *CreditService) CreateCredit(userID uint, amount float64, term int) (*mo
 	return credit, nil
 }
 
+// SetRepaymentAccount привязывает к кредиту рублевый счет пользователя, с которого планировщик
+// списывает платежи по графику. accountID == nil отключает автоматическое списание
+func (s *CreditService) SetRepaymentAccount(creditID, userID uint, accountID *uint) (*models.Credit, error) {
+	credit, err := s.repo.GetCreditByID(creditID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credit: %v", err)
+	}
+	if credit.Status == models.CreditClosed {
+		return nil, validationError("credit is already paid off")
+	}
+
+	if accountID != nil {
+		account, err := s.accountRepo.GetAccountByID(*accountID)
+		if err != nil || account.UserID != userID {
+			return nil, validationError("account %d not found", *accountID)
+		}
+		if account.Currency != models.DefaultCurrency {
+			return nil, validationError("repayment account must be in %s", models.DefaultCurrency)
+		}
+	}
+
+	if err := s.repo.SetRepaymentAccount(creditID, accountID); err != nil {
+		return nil, err
+	}
+	credit.RepaymentAccountID = accountID
+	return credit, nil
+}
+
+func (s *CreditService) GetProducts() ([]models.CreditProduct, error) {
+	return s.productRepo.GetActiveProducts()
+}
+
 func (s *CreditService) GetCreditsByUserID(userID uint) ([]models.Credit, error) {
 	return s.repo.GetCreditsByUserID(userID)
 }
@@ -93,13 +193,32 @@ func (s *CreditService) CreditBelongsToUser(creditID, userID uint) bool {
 	return credit.UserID == userID
 }
 
-func (s *CreditService) GetCreditBalance(creditID uint) (float64, error) {
+// GetCreditBalance возвращает разбивку задолженности по кредиту и сумму к оплате по ближайшей строке графика
+func (s *CreditService) GetCreditBalance(creditID uint) (*models.CreditBalanceInfo, error) {
 	credit, err := s.repo.GetCreditByID(creditID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get credit: %v", err)
+		return nil, fmt.Errorf("failed to get credit: %v", err)
+	}
+	schedule, err := s.repo.GetPaymentSchedule(creditID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment schedule: %v", err)
 	}
 
-	return credit.Amount, nil
+	balance := &models.CreditBalanceInfo{
+		CreditID:      credit.ID,
+		CreditBalance: credit.Outstanding,
+		Total:         credit.Outstanding.Total().WithCurrency(models.DefaultCurrency),
+	}
+	balance.AmountDue = balance.Total
+	for _, row := range schedule {
+		if !row.IsPaid {
+			dueDate := row.DueDate
+			balance.AmountDue = installmentDue(credit, row)
+			balance.DueDate = &dueDate
+			break
+		}
+	}
+	return balance, nil
 }
 
 func (s *CreditService) GetNextPaymentDate(creditID uint) (time.Time, error) {
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule — расписание задачи в формате cron из пяти полей: минута, час, день месяца, месяц, день недели.
+// Поддерживаются *, списки через запятую, диапазоны a-b и шаг /n, а также сокращения @hourly, @daily,
+// @weekly, @monthly и @every <интервал> (например, @every 12h)
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // битовые маски допустимых значений
+	domAny, dowAny                bool   // поле задано как *
+	every                         time.Duration
+}
+
+// cronMaxSearch — горизонт поиска следующего запуска. Невыполнимые расписания (например, 30 февраля)
+// отклоняются при разборе; 29 февраля встречается не реже раза в 8 лет (2096 → 2104)
+const cronMaxSearch = 9 * 366 * 24 * time.Hour
+
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+func parseCronSchedule(spec string) (cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || every < time.Minute {
+			return cronSchedule{}, fmt.Errorf("invalid schedule %q: interval must be at least 1m", spec)
+		}
+		return cronSchedule{every: every}, nil
+	}
+	if macro, ok := cronMacros[spec]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("invalid schedule %q: expected 5 fields", spec)
+	}
+
+	var schedule cronSchedule
+	var err error
+	if schedule.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid schedule %q: minute: %v", spec, err)
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid schedule %q: hour: %v", spec, err)
+	}
+	if schedule.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid schedule %q: day of month: %v", spec, err)
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid schedule %q: month: %v", spec, err)
+	}
+	if schedule.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return cronSchedule{}, fmt.Errorf("invalid schedule %q: day of week: %v", spec, err)
+	}
+	// 7 — тоже воскресенье
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	schedule.domAny = fields[2] == "*"
+	schedule.dowAny = fields[4] == "*"
+	if !schedule.dayPossible() {
+		return cronSchedule{}, fmt.Errorf("invalid schedule %q: day of month never occurs in the given months", spec)
+	}
+	return schedule, nil
+}
+
+// cronMonthDays — наибольшее число дней в месяце с учетом високосного года
+var cronMonthDays = [13]int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+// dayPossible проверяет, что расписание когда-нибудь срабатывает. Невыполнимым может быть только
+// день месяца, заданный без дня недели и отсутствующий во всех выбранных месяцах (например, 30 февраля)
+func (c cronSchedule) dayPossible() bool {
+	if c.domAny || !c.dowAny {
+		return true
+	}
+	for month := 1; month <= 12; month++ {
+		if c.month&(1<<uint(month)) == 0 {
+			continue
+		}
+		for day := 1; day <= cronMonthDays[month]; day++ {
+			if c.dom&(1<<uint(day)) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseCronField разбирает одно поле расписания в битовую маску значений из диапазона [min, max]
+func parseCronField(field string, min, max int) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		low, high := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			low, err1 = strconv.Atoi(bounds[0])
+			high, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			low = value
+			// a/n означает a, a+n, ... до конца диапазона
+			if step == 1 {
+				high = value
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("value %q out of range %d-%d", part, min, max)
+		}
+
+		for v := low; v <= high; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+// Next возвращает время первого запуска строго после t
+func (c cronSchedule) Next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Truncate(time.Second).Add(c.every)
+	}
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronMaxSearch)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return limit
+}
+
+// dayMatches проверяет день по правилам cron: если заданы и день месяца, и день недели,
+// достаточно совпадения любого из них
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowMatch
+	case c.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
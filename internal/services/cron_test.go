@@ -0,0 +1,92 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2025-03-10 — понедельник
+	from := time.Date(2025, 3, 10, 10, 30, 45, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{spec: "* * * * *", want: "2025-03-10 10:31"},
+		{spec: "*/15 * * * *", want: "2025-03-10 10:45"},
+		{spec: "5/20 * * * *", want: "2025-03-10 10:45"},
+		{spec: "0,20,40 * * * *", want: "2025-03-10 10:40"},
+		{spec: "10-20 * * * *", want: "2025-03-10 11:10"},
+		{spec: "0 9-17/4 * * *", want: "2025-03-10 13:00"},
+		{spec: "30 10 * * *", want: "2025-03-11 10:30"},
+		{spec: "0 0 1 * *", want: "2025-04-01 00:00"},
+		{spec: "0 0 31 * *", want: "2025-03-31 00:00"},
+		{spec: "0 0 * 6 *", want: "2025-06-01 00:00"},
+		{spec: "0 0 1 1,7 *", want: "2025-07-01 00:00"},
+		// Воскресенье задается как 0 и как 7
+		{spec: "0 12 * * 0", want: "2025-03-16 12:00"},
+		{spec: "0 12 * * 7", want: "2025-03-16 12:00"},
+		{spec: "0 12 * * 5-7", want: "2025-03-14 12:00"},
+		{spec: "0 12 * * 1-5", want: "2025-03-10 12:00"},
+		// Заданы и день месяца, и день недели: достаточно совпадения любого из них
+		{spec: "0 0 20 * 5", want: "2025-03-14 00:00"},
+		{spec: "0 0 11 * 5", want: "2025-03-11 00:00"},
+		// 29 февраля — только в високосный год
+		{spec: "0 0 29 2 *", want: "2028-02-29 00:00"},
+		{spec: "@hourly", want: "2025-03-10 11:00"},
+		{spec: "@daily", want: "2025-03-11 00:00"},
+		{spec: "@weekly", want: "2025-03-16 00:00"},
+		{spec: "@monthly", want: "2025-04-01 00:00"},
+		// @every отсчитывается от предыдущего запуска с точностью до секунды
+		{spec: "@every 90m", want: "2025-03-10 12:00:45"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := parseCronSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("parseCronSchedule(%q): %v", tt.spec, err)
+			}
+			layout := "2006-01-02 15:04"
+			if len(tt.want) > len(layout) {
+				layout += ":05"
+			}
+			if got := schedule.Next(from).Format(layout); got != tt.want {
+				t.Errorf("Next(%s) = %s, want %s", from.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-a * * * *",
+		"@yearly",
+		"@every 30s",
+		"@every soon",
+		// Дня нет ни в одном из выбранных месяцев
+		"0 0 30 2 *",
+		"0 0 31 4,6,9,11 *",
+	}
+	for _, spec := range specs {
+		if _, err := parseCronSchedule(spec); err == nil {
+			t.Errorf("parseCronSchedule(%q) succeeded, want error", spec)
+		}
+	}
+
+	// С днем недели такое расписание выполнимо: срабатывает по воскресеньям февраля
+	if _, err := parseCronSchedule("0 0 30 2 0"); err != nil {
+		t.Errorf("parseCronSchedule(%q): %v", "0 0 30 2 0", err)
+	}
+}
@@ -0,0 +1,13 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrValidation — ошибка во входных данных клиента; обработчики возвращают ее со статусом 400
+var ErrValidation = errors.New("validation error")
+
+func validationError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrValidation, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"math/big"
+	"os"
+	"strconv"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+// exchangeRateScale — точность, с которой хранится примененный курс (знаков после запятой)
+const exchangeRateScale = 8
+
+// fxSpreadPercent возвращает спред банка на конвертацию в процентах из .env
+func fxSpreadPercent() float64 {
+	spread, err := strconv.ParseFloat(os.Getenv("FX_RATE_SPREAD"), 64)
+	if err != nil || spread < 0 || spread >= 100 {
+		return 0 // Значение по умолчанию — конвертация по официальному курсу
+	}
+	return spread
+}
+
+// applySpread уменьшает официальный курс на спред банка: клиент получает
+// меньше единиц валюты зачисления за единицу валюты списания.
+// Результат округляется до exchangeRateScale знаков, чтобы сохраненный курс
+// в точности совпадал с курсом, по которому посчитана сумма зачисления
+func applySpread(marketRate *big.Rat, spreadPercent float64) *big.Rat {
+	factor := new(big.Rat).Sub(big.NewRat(1, 1), new(big.Rat).Quo(models.DecimalRat(spreadPercent), big.NewRat(100, 1)))
+	rate := new(big.Rat).Mul(marketRate, factor)
+	rounded, _ := new(big.Rat).SetString(rate.FloatString(exchangeRateScale))
+	return rounded
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+)
+
+var (
+	ErrFXQuoteNotFound = errors.New("fx quote not found")
+	ErrFXQuoteExpired  = errors.New("fx quote has expired")
+	ErrFXQuoteExecuted = errors.New("fx quote has already been executed")
+)
+
+type FXService struct {
+	repo        *repositories.FXQuoteRepository
+	accountRepo *repositories.AccountRepository
+	uow         *repositories.UnitOfWork
+	cbrService  *CBRService
+}
+
+func NewFXService(repo *repositories.FXQuoteRepository, accountRepo *repositories.AccountRepository, uow *repositories.UnitOfWork, cbrService *CBRService) *FXService {
+	return &FXService{
+		repo:        repo,
+		accountRepo: accountRepo,
+		uow:         uow,
+		cbrService:  cbrService,
+	}
+}
+
+// fxQuoteTTL возвращает срок действия котировки из .env
+func fxQuoteTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("FX_QUOTE_TTL"))
+	if err != nil || ttl <= 0 {
+		return time.Minute // Значение по умолчанию
+	}
+	return ttl
+}
+
+// CreateQuote рассчитывает котировку обмена amount с одного счета пользователя на другой
+// по курсу ЦБ РФ с учетом спреда банка
+func (s *FXService) CreateQuote(userID, fromAccountID, toAccountID uint, amount models.Money) (*models.FXQuote, error) {
+	if !amount.IsPositive() {
+		return nil, validationError("exchange amount must be positive")
+	}
+	if fromAccountID == toAccountID {
+		return nil, validationError("cannot exchange within the same account")
+	}
+
+	// Ищем счета среди счетов пользователя: чужой и несуществующий счет отклоняются одинаково
+	accounts, err := s.accountRepo.GetAccountsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	var fromAccount, toAccount *models.Account
+	for i := range accounts {
+		switch accounts[i].ID {
+		case fromAccountID:
+			fromAccount = &accounts[i]
+		case toAccountID:
+			toAccount = &accounts[i]
+		}
+	}
+	if fromAccount == nil || toAccount == nil {
+		return nil, validationError("accounts do not belong to user")
+	}
+	if fromAccount.Currency == toAccount.Currency {
+		return nil, validationError("accounts have the same currency")
+	}
+
+	marketRate, err := s.cbrService.GetExchangeRate(fromAccount.Currency, toAccount.Currency, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exchange rate: %v", err)
+	}
+	spread := fxSpreadPercent()
+	rate := applySpread(marketRate, spread)
+
+	amount = amount.WithCurrency(fromAccount.Currency)
+	toAmount, err := models.MoneyFromRatChecked(new(big.Rat).Mul(amount.Rat(), rate), toAccount.Currency, models.DefaultRounding)
+	if err != nil {
+		return nil, validationError("exchange amount is too large to convert")
+	}
+	if !toAmount.IsPositive() {
+		return nil, validationError("exchange amount is too small to convert")
+	}
+
+	now := time.Now()
+	quote := &models.FXQuote{
+		UserID:        userID,
+		FromAccount:   fromAccountID,
+		ToAccount:     toAccountID,
+		Amount:        amount,
+		FromCurrency:  fromAccount.Currency,
+		ToAmount:      toAmount,
+		ToCurrency:    toAccount.Currency,
+		MarketRate:    ratFloat(marketRate),
+		Rate:          ratFloat(rate),
+		SpreadPercent: spread,
+		Status:        models.FXQuoteActive,
+		ExpiresAt:     now.Add(fxQuoteTTL()),
+		CreatedAt:     now,
+	}
+	if err := s.repo.CreateQuote(quote); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// ExecuteQuote проводит обмен по котировке. Котировка блокируется на время транзакции
+// и помечается исполненной, поэтому повторное исполнение невозможно
+func (s *FXService) ExecuteQuote(userID, quoteID uint) (*models.FXQuote, error) {
+	var quote *models.FXQuote
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		var err error
+		quote, err = tx.FXQuotes.GetQuoteForUpdate(quoteID)
+		if err != nil {
+			return err
+		}
+		if quote == nil || quote.UserID != userID {
+			return ErrFXQuoteNotFound
+		}
+		if quote.Status != models.FXQuoteActive {
+			return ErrFXQuoteExecuted
+		}
+		now := time.Now()
+		if now.After(quote.ExpiresAt) {
+			return ErrFXQuoteExpired
+		}
+
+		accounts, err := tx.Accounts.LockAccounts(quote.FromAccount, quote.ToAccount)
+		if err != nil {
+			return err
+		}
+		fromAccount := accounts[quote.FromAccount]
+		toAccount := accounts[quote.ToAccount]
+
+		// Валюта счетов могла измениться после выдачи котировки
+		if fromAccount.Currency != quote.FromCurrency || toAccount.Currency != quote.ToCurrency {
+			return validationError("account currency changed, please request a new quote")
+		}
+		if fromAccount.AvailableBalance.LessThan(quote.Amount) {
+			return validationError("insufficient funds")
+		}
+		// Баланс счета зачисления должен поместиться в колонку счета
+		if _, err := toAccount.Balance.AddChecked(quote.ToAmount); err != nil {
+			return validationError("exchange amount is too large: %v", err)
+		}
+
+		if err := tx.Accounts.AdjustBalance(quote.FromAccount, quote.Amount.Neg()); err != nil {
+			return err
+		}
+		if err := tx.Accounts.AdjustBalance(quote.ToAccount, quote.ToAmount); err != nil {
+			return err
+		}
+		if err := tx.FXQuotes.MarkExecuted(quote.ID, now); err != nil {
+			return err
+		}
+		quote.Status = models.FXQuoteExecuted
+		quote.ExecutedAt = &now
+
+		// Отражаем обмен в главной книге, он попадет в историю обоих счетов
+		if err := tx.Ledger.PostEntry(fxExchangeEntry(quote)); err != nil {
+			return fmt.Errorf("failed to post exchange to ledger: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+func (s *FXService) GetQuote(userID, quoteID uint) (*models.FXQuote, error) {
+	quote, err := s.repo.GetQuoteByID(quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if quote == nil || quote.UserID != userID {
+		return nil, ErrFXQuoteNotFound
+	}
+	return quote, nil
+}
+
+func ratFloat(r *big.Rat) float64 {
+	f, _ := r.Float64()
+	return f
+}
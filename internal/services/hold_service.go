@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+// ErrHoldNotFound — блокировка не существует или относится к другому счету
+var ErrHoldNotFound = errors.New("hold not found")
+
+// Срок блокировки средств, устанавливаемой через API
+const (
+	defaultHoldTTL = 7 * 24 * time.Hour
+	maxHoldTTL     = 30 * 24 * time.Hour
+)
+
+// HoldRequest — параметры блокировки средств: сумма в валюте счета и срок (nil — через 7 дней)
+type HoldRequest struct {
+	Amount      models.Money `json:"amount"`
+	Description string       `json:"description"`
+	ExpiresAt   *time.Time   `json:"expires_at"`
+}
+
+type HoldService struct {
+	repo        *repositories.HoldRepository
+	accountRepo *repositories.AccountRepository
+	uow         *repositories.UnitOfWork
+}
+
+func NewHoldService(repo *repositories.HoldRepository, accountRepo *repositories.AccountRepository, uow *repositories.UnitOfWork) *HoldService {
+	return &HoldService{
+		repo:        repo,
+		accountRepo: accountRepo,
+		uow:         uow,
+	}
+}
+
+// CreateHold блокирует часть доступного остатка счета до снятия блокировки или истечения ее срока
+func (s *HoldService) CreateHold(accountID uint, request HoldRequest) (*models.Hold, error) {
+	if !request.Amount.IsPositive() {
+		return nil, validationError("amount must be positive")
+	}
+	now := time.Now()
+	expiresAt := now.Add(defaultHoldTTL)
+	if request.ExpiresAt != nil {
+		expiresAt = *request.ExpiresAt
+	}
+	if !expiresAt.After(now) || expiresAt.After(now.Add(maxHoldTTL)) {
+		return nil, validationError("expires_at must be in the future and not later than %d days", int(maxHoldTTL.Hours()/24))
+	}
+
+	var hold *models.Hold
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		account, err := tx.Accounts.GetAccountForUpdate(accountID)
+		if err != nil {
+			return err
+		}
+		amount := request.Amount.WithCurrency(account.Currency)
+		if account.AvailableBalance.LessThan(amount) {
+			return validationError("insufficient funds")
+		}
+
+		hold = &models.Hold{
+			AccountID:   accountID,
+			Amount:      amount,
+			Currency:    account.Currency,
+			Source:      models.HoldSourceManual,
+			Description: request.Description,
+			ExpiresAt:   expiresAt,
+			CreatedAt:   now,
+		}
+		return tx.Holds.CreateHold(hold)
+	})
+	if errors.Is(err, ErrValidation) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to create hold: %v", err)
+	}
+	return hold, nil
+}
+
+// ReleaseHold досрочно снимает блокировку счета accountID. Блокировки авторизаций по картам
+// снимаются только отменой авторизации торговым предприятием
+func (s *HoldService) ReleaseHold(accountID, holdID uint) (*models.Hold, error) {
+	var hold *models.Hold
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		var err error
+		hold, err = tx.Holds.GetHoldForUpdate(holdID)
+		if err != nil {
+			return err
+		}
+		if hold == nil || hold.AccountID != accountID {
+			return ErrHoldNotFound
+		}
+		if hold.Source != models.HoldSourceManual {
+			return validationError("hold of %s can not be released manually", hold.Source)
+		}
+		if hold.Status != models.HoldActive {
+			return validationError("hold is %s", hold.Status)
+		}
+
+		now := time.Now()
+		if err := tx.Holds.FinishHold(holdID, models.HoldReleased, now); err != nil {
+			return err
+		}
+		hold.Status = models.HoldReleased
+		hold.ReleasedAt = &now
+		return nil
+	})
+	if errors.Is(err, ErrValidation) || errors.Is(err, ErrHoldNotFound) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to release hold: %v", err)
+	}
+	return hold, nil
+}
+
+// GetAccountHolds возвращает блокировки счета
+func (s *HoldService) GetAccountHolds(accountID uint) ([]models.Hold, error) {
+	return s.repo.GetHoldsByAccountID(accountID)
+}
+
+// ExpireHolds отмечает истекшими блокировки, срок которых наступил
+func (s *HoldService) ExpireHolds() error {
+	expired, err := s.repo.ExpireHolds(time.Now())
+	if err != nil {
+		return err
+	}
+	utils.Log.Infof("Holds expired: %d", expired)
+	return nil
+}
+
+func (s *HoldService) AccountBelongsToUser(accountID, userID uint) bool {
+	account, err := s.accountRepo.GetAccountByID(accountID)
+	if err != nil {
+		return false
+	}
+
+	return account.UserID == userID
+}
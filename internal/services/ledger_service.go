@@ -0,0 +1,284 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+type LedgerService struct {
+	repo        *repositories.LedgerRepository
+	accountRepo *repositories.AccountRepository
+}
+
+func NewLedgerService(repo *repositories.LedgerRepository, accountRepo *repositories.AccountRepository) *LedgerService {
+	return &LedgerService{
+		repo:        repo,
+		accountRepo: accountRepo,
+	}
+}
+
+func (s *LedgerService) GetAccountHistory(accountID uint) ([]models.AccountHistoryItem, error) {
+	return s.repo.GetAccountHistory(accountID)
+}
+
+func (s *LedgerService) AccountBelongsToUser(accountID, userID uint) bool {
+	account, err := s.accountRepo.GetAccountByID(accountID)
+	if err != nil {
+		return false
+	}
+
+	return account.UserID == userID
+}
+
+// PostOpeningBalances переносит в главную книгу остатки счетов и кредитов,
+// созданных до ее ведения, чтобы сверка сходилась и для старых данных
+func (s *LedgerService) PostOpeningBalances() error {
+	accounts, err := s.repo.GetAccountsWithoutPostings()
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		// Положительный остаток счета — обязательство банка перед клиентом (кредит счета клиента)
+		customerSide, cashSide := models.DirectionCredit, models.DirectionDebit
+		amount := account.Balance
+		if amount.IsNegative() {
+			customerSide, cashSide = cashSide, customerSide
+			amount = amount.Neg()
+		}
+		entry := &models.JournalEntry{
+			Type:        models.EntryOpeningBalance,
+			ReferenceID: account.ID,
+			Description: "Входящий остаток счета",
+			Postings: []models.Posting{
+				models.CustomerPosting(customerSide, account.ID, amount),
+				models.BankPosting(cashSide, models.LedgerCash, amount),
+			},
+		}
+		if err := s.repo.PostEntry(entry); err != nil {
+			return fmt.Errorf("failed to post opening balance for account %d: %v", account.ID, err)
+		}
+	}
+
+	credits, err := s.repo.GetCreditsWithoutPostings()
+	if err != nil {
+		return err
+	}
+	for _, credit := range credits {
+		entry := &models.JournalEntry{
+			Type:        models.EntryOpeningBalance,
+			ReferenceID: credit.ID,
+			Description: "Входящий остаток задолженности по кредиту",
+			Postings: []models.Posting{
+				models.LoanPosting(models.DirectionDebit, credit.ID, credit.Amount),
+				models.BankPosting(models.DirectionCredit, models.LedgerCash, credit.Amount),
+			},
+		}
+		if err := s.repo.PostEntry(entry); err != nil {
+			return fmt.Errorf("failed to post opening balance for credit %d: %v", credit.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// Reconcile сверяет остатки счетов и задолженность по кредитам с проводками главной книги
+func (s *LedgerService) Reconcile() ([]models.BalanceMismatch, error) {
+	accountMismatches, err := s.repo.GetAccountBalanceMismatches()
+	if err != nil {
+		return nil, err
+	}
+	creditMismatches, err := s.repo.GetCreditBalanceMismatches()
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches := append(accountMismatches, creditMismatches...)
+	for _, mismatch := range mismatches {
+		utils.Log.WithFields(logrus.Fields{
+			"ledger_account": mismatch.LedgerAccount,
+			"id":             mismatch.ID,
+			"stored":         mismatch.Stored.String(),
+			"ledger":         mismatch.Ledger.String(),
+		}).Error("Balance does not match ledger postings")
+	}
+	return mismatches, nil
+}
+
+// transferEntry — перевод между счетами клиентов.
+// При конвертации перевод проходит через валютную позицию банка,
+// чтобы проводки балансировались в каждой из валют
+func transferEntry(transfer *models.Transfer) *models.JournalEntry {
+	postings := []models.Posting{
+		models.CustomerPosting(models.DirectionDebit, transfer.FromAccount, transfer.Amount),
+		models.CustomerPosting(models.DirectionCredit, transfer.ToAccount, transfer.ToAmount),
+	}
+	if transfer.Amount.Currency() != transfer.ToAmount.Currency() {
+		postings = append(postings,
+			models.BankPosting(models.DirectionCredit, models.LedgerFXPosition, transfer.Amount),
+			models.BankPosting(models.DirectionDebit, models.LedgerFXPosition, transfer.ToAmount),
+		)
+	}
+
+	return &models.JournalEntry{
+		Type:        models.EntryTransfer,
+		ReferenceID: transfer.ID,
+		Description: transfer.Description,
+		CreatedAt:   transfer.CreatedAt,
+		Postings:    postings,
+	}
+}
+
+// fxExchangeEntry — обмен валюты между счетами пользователя через валютную позицию банка
+func fxExchangeEntry(quote *models.FXQuote) *models.JournalEntry {
+	entry := &models.JournalEntry{
+		Type:        models.EntryFXExchange,
+		ReferenceID: quote.ID,
+		Description: fmt.Sprintf("Обмен %s %s на %s %s", quote.Amount, quote.FromCurrency, quote.ToAmount, quote.ToCurrency),
+		Postings: []models.Posting{
+			models.CustomerPosting(models.DirectionDebit, quote.FromAccount, quote.Amount),
+			models.BankPosting(models.DirectionCredit, models.LedgerFXPosition, quote.Amount),
+			models.BankPosting(models.DirectionDebit, models.LedgerFXPosition, quote.ToAmount),
+			models.CustomerPosting(models.DirectionCredit, quote.ToAccount, quote.ToAmount),
+		},
+	}
+	if quote.ExecutedAt != nil {
+		entry.CreatedAt = *quote.ExecutedAt
+	}
+	return entry
+}
+
+// transactionEntry — пополнение или списание средств со счета.
+// При reversal проводки меняются местами, чтобы отменить ранее проведенную операцию
+func transactionEntry(transaction *models.Transaction, currency string, reversal bool) *models.JournalEntry {
+	amount := transaction.Amount.WithCurrency(currency)
+	customerSide, cashSide := models.DirectionCredit, models.DirectionDebit
+	if transaction.Type != "income" {
+		customerSide, cashSide = cashSide, customerSide
+	}
+
+	entryType := models.EntryTransaction
+	if reversal {
+		entryType = models.EntryTransactionReversal
+		customerSide, cashSide = cashSide, customerSide
+	}
+
+	return &models.JournalEntry{
+		Type:        entryType,
+		ReferenceID: transaction.ID,
+		Description: transaction.Description,
+		Postings: []models.Posting{
+			models.CustomerPosting(customerSide, transaction.AccountID, amount),
+			models.BankPosting(cashSide, models.LedgerCash, amount),
+		},
+	}
+}
+
+// creditDisbursementEntry — выдача кредита на счет клиента. Комиссия за выдачу удерживается из выдаваемой суммы
+func creditDisbursementEntry(credit *models.Credit) *models.JournalEntry {
+	payout := models.BankPosting(models.DirectionCredit, models.LedgerCash, credit.Amount.Sub(credit.Fee))
+	if credit.AccountID != nil {
+		payout = models.CustomerPosting(models.DirectionCredit, *credit.AccountID, credit.Amount.Sub(credit.Fee))
+	}
+	postings := []models.Posting{
+		models.LoanPosting(models.DirectionDebit, credit.ID, credit.Amount),
+		payout,
+	}
+	if credit.Fee.IsPositive() {
+		postings = append(postings, models.BankPosting(models.DirectionCredit, models.LedgerFeeIncome, credit.Fee))
+	}
+
+	return &models.JournalEntry{
+		Type:        models.EntryCreditDisbursement,
+		ReferenceID: credit.ID,
+		Description: "Выдача кредита",
+		CreatedAt:   credit.CreatedAt,
+		Postings:    postings,
+	}
+}
+
+// creditRepaymentEntry — платеж по кредиту. Проценты, неустойка и комиссии включаются в задолженность
+// при начислении, поэтому весь платеж уменьшает ссудную задолженность.
+// Платеж, списанный со счета клиента, уменьшает его остаток
+func creditRepaymentEntry(payment *models.Payment) *models.JournalEntry {
+	source := models.BankPosting(models.DirectionDebit, models.LedgerCash, payment.Amount)
+	if payment.AccountID != nil {
+		source = models.CustomerPosting(models.DirectionDebit, *payment.AccountID, payment.Amount)
+	}
+
+	return &models.JournalEntry{
+		Type:        models.EntryCreditRepayment,
+		ReferenceID: payment.ID,
+		Description: "Платеж по кредиту",
+		CreatedAt:   payment.CreatedAt,
+		Postings: []models.Posting{
+			source,
+			models.LoanPosting(models.DirectionCredit, payment.CreditID, payment.Amount),
+		},
+	}
+}
+
+// interestAccrualEntry — начисление процентов по строке графика
+func interestAccrualEntry(creditID uint, row *models.PaymentSchedule) *models.JournalEntry {
+	return &models.JournalEntry{
+		Type:        models.EntryInterestAccrual,
+		ReferenceID: row.ID,
+		Description: "Начисление процентов по кредиту",
+		Postings: []models.Posting{
+			models.LoanPosting(models.DirectionDebit, creditID, row.Interest),
+			models.BankPosting(models.DirectionCredit, models.LedgerInterestIncome, row.Interest),
+		},
+	}
+}
+
+// penaltyEntry — начисление штрафа за просрочку платежа
+func penaltyEntry(payment *models.Payment, penalty models.Money) *models.JournalEntry {
+	return &models.JournalEntry{
+		Type:        models.EntryPenalty,
+		ReferenceID: payment.ID,
+		Description: "Штраф за просрочку платежа",
+		Postings: []models.Posting{
+			models.LoanPosting(models.DirectionDebit, payment.CreditID, penalty),
+			models.BankPosting(models.DirectionCredit, models.LedgerPenaltyIncome, penalty),
+		},
+	}
+}
+
+// cardCaptureEntry — списание оплаты картой со счета клиента в расчеты с торговым предприятием
+func cardCaptureEntry(authorization *models.CardAuthorization, amount models.Money, createdAt time.Time) *models.JournalEntry {
+	return &models.JournalEntry{
+		Type:        models.EntryCardCapture,
+		ReferenceID: authorization.ID,
+		Description: cardPaymentDescription("Оплата картой", authorization),
+		CreatedAt:   createdAt,
+		Postings: []models.Posting{
+			models.CustomerPosting(models.DirectionDebit, authorization.AccountID, amount),
+			models.BankPosting(models.DirectionCredit, models.LedgerCardSettlement, amount),
+		},
+	}
+}
+
+// cardRefundEntry — возврат торговым предприятием оплаты картой на счет клиента
+func cardRefundEntry(authorization *models.CardAuthorization, amount models.Money, createdAt time.Time) *models.JournalEntry {
+	return &models.JournalEntry{
+		Type:        models.EntryCardRefund,
+		ReferenceID: authorization.ID,
+		Description: cardPaymentDescription("Возврат оплаты картой", authorization),
+		CreatedAt:   createdAt,
+		Postings: []models.Posting{
+			models.BankPosting(models.DirectionDebit, models.LedgerCardSettlement, amount),
+			models.CustomerPosting(models.DirectionCredit, authorization.AccountID, amount),
+		},
+	}
+}
+
+func cardPaymentDescription(prefix string, authorization *models.CardAuthorization) string {
+	if authorization.Description == "" {
+		return prefix
+	}
+	return prefix + ": " + authorization.Description
+}
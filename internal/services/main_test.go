@@ -0,0 +1,53 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger()
+	if err := setupTestKeys(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up test keys: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// setupTestKeys создает HMAC-секрет и одноразовый PGP-ключ, чтобы шифрование карт работало без .env
+func setupTestKeys() error {
+	dir, err := os.MkdirTemp("", "services-test")
+	if err != nil {
+		return err
+	}
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		return err
+	}
+	keyFile := filepath.Join(dir, "private.asc")
+	f, err := os.Create(keyFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := armor.Encode(f, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		return err
+	}
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	os.Setenv("HMAC_SECRET", "test-hmac-secret")
+	os.Setenv("PGP_PRIVATE_KEY", keyFile)
+	return utils.InitCrypto()
+}
@@ -0,0 +1,50 @@
+package services
+
+import "github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+
+// allocatePayment распределяет сумму платежа по составляющим задолженности в порядке очередности:
+// неустойка, комиссии, проценты, основной долг. Сумма сверх задолженности не распределяется
+func allocatePayment(balance models.CreditBalance, amount models.Money) models.CreditBalance {
+	take := func(due models.Money) models.Money {
+		part := due
+		if amount.LessThan(part) {
+			part = amount
+		}
+		if part.IsNegative() {
+			part = models.Money{}
+		}
+		amount = amount.Sub(part)
+		return part
+	}
+
+	var allocation models.CreditBalance
+	allocation.Penalties = take(balance.Penalties)
+	allocation.Fees = take(balance.Fees)
+	allocation.Interest = take(balance.Interest)
+	allocation.Principal = take(balance.Principal)
+	return allocation
+}
+
+// installmentDue возвращает сумму, погашающую строку графика row с учетом очередности:
+// неустойка, комиссии и начисленные проценты по кредиту, проценты по строке (если еще не начислены)
+// и основной долг по строке
+func installmentDue(credit *models.Credit, row models.PaymentSchedule) models.Money {
+	due := credit.Outstanding.Penalties.Add(credit.Outstanding.Fees).Add(credit.Outstanding.Interest)
+	if !row.InterestAccrued {
+		due = due.Add(row.Interest)
+	}
+	return due.Add(installmentPrincipal(credit, row)).WithCurrency(models.DefaultCurrency)
+}
+
+// installmentPrincipal возвращает основной долг по строке графика. Строки, созданные
+// до разбивки платежа на основной долг и проценты, целиком идут в погашение основного долга
+func installmentPrincipal(credit *models.Credit, row models.PaymentSchedule) models.Money {
+	principal := row.Principal
+	if principal.IsZero() && row.Interest.IsZero() {
+		principal = row.Amount
+	}
+	if principal.GreaterThan(credit.Outstanding.Principal) {
+		principal = credit.Outstanding.Principal
+	}
+	return principal
+}
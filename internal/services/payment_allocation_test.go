@@ -0,0 +1,114 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+func rub(minor int64) models.Money {
+	return models.NewMoney(minor, models.DefaultCurrency)
+}
+
+func TestAllocatePayment(t *testing.T) {
+	balance := models.CreditBalance{
+		Penalties: rub(50_00),
+		Fees:      rub(30_00),
+		Interest:  rub(200_00),
+		Principal: rub(1_000_00),
+	}
+	tests := []struct {
+		name   string
+		amount int64
+		want   models.CreditBalance
+	}{
+		{name: "zero", amount: 0, want: models.CreditBalance{}},
+		{name: "penalties only", amount: 40_00, want: models.CreditBalance{Penalties: rub(40_00)}},
+		{name: "penalties exactly", amount: 50_00, want: models.CreditBalance{Penalties: rub(50_00)}},
+		{name: "into fees", amount: 60_00, want: models.CreditBalance{Penalties: rub(50_00), Fees: rub(10_00)}},
+		{name: "into interest", amount: 180_00, want: models.CreditBalance{Penalties: rub(50_00), Fees: rub(30_00), Interest: rub(100_00)}},
+		{name: "into principal", amount: 380_01, want: models.CreditBalance{Penalties: rub(50_00), Fees: rub(30_00), Interest: rub(200_00), Principal: rub(100_01)}},
+		{name: "exact total", amount: 1_280_00, want: balance},
+		// Переплата не распределяется: каждая составляющая гасится не больше, чем на ее долг
+		{name: "overpayment", amount: 5_000_00, want: balance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := allocatePayment(balance, rub(tt.amount))
+			if got.Penalties.Minor() != tt.want.Penalties.Minor() || got.Fees.Minor() != tt.want.Fees.Minor() ||
+				got.Interest.Minor() != tt.want.Interest.Minor() || got.Principal.Minor() != tt.want.Principal.Minor() {
+				t.Fatalf("allocatePayment(%d) = %+v, want %+v", tt.amount, got, tt.want)
+			}
+			if total := got.Total(); total.GreaterThan(rub(tt.amount)) {
+				t.Fatalf("allocated %s exceeds payment %d", total, tt.amount)
+			}
+		})
+	}
+
+	// Отрицательная составляющая (например, после сторно) ничего не забирает
+	got := allocatePayment(models.CreditBalance{Penalties: rub(-10_00), Principal: rub(100_00)}, rub(50_00))
+	if !got.Penalties.IsZero() || got.Principal.Minor() != 50_00 {
+		t.Fatalf("allocation with negative penalties = %+v, want 50.00 of principal", got)
+	}
+}
+
+func TestInstallmentDue(t *testing.T) {
+	outstanding := models.CreditBalance{
+		Penalties: rub(15_00),
+		Fees:      rub(5_00),
+		Interest:  rub(100_00),
+		Principal: rub(10_000_00),
+	}
+	tests := []struct {
+		name      string
+		row       models.PaymentSchedule
+		principal int64 // installmentPrincipal
+		due       int64 // installmentDue
+	}{
+		{
+			name:      "interest not accrued yet",
+			row:       models.PaymentSchedule{Amount: rub(1_100_00), Principal: rub(1_000_00), Interest: rub(100_00)},
+			principal: 1_000_00,
+			due:       15_00 + 5_00 + 100_00 + 100_00 + 1_000_00,
+		},
+		{
+			// Проценты по строке уже включены в задолженность и не учитываются дважды
+			name:      "interest accrued",
+			row:       models.PaymentSchedule{Amount: rub(1_100_00), Principal: rub(1_000_00), Interest: rub(100_00), InterestAccrued: true},
+			principal: 1_000_00,
+			due:       15_00 + 5_00 + 100_00 + 1_000_00,
+		},
+		{
+			// Строка, созданная до разбивки на основной долг и проценты, целиком гасит основной долг
+			name:      "legacy row",
+			row:       models.PaymentSchedule{Amount: rub(1_100_00)},
+			principal: 1_100_00,
+			due:       15_00 + 5_00 + 100_00 + 1_100_00,
+		},
+		{
+			// Основной долг по строке не превышает остатка по кредиту
+			name:      "legacy row above outstanding principal",
+			row:       models.PaymentSchedule{Amount: rub(20_000_00)},
+			principal: 10_000_00,
+			due:       15_00 + 5_00 + 100_00 + 10_000_00,
+		},
+		{
+			name:      "interest-only row",
+			row:       models.PaymentSchedule{Amount: rub(100_00), Interest: rub(100_00)},
+			principal: 0,
+			due:       15_00 + 5_00 + 100_00 + 100_00,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credit := &models.Credit{Outstanding: outstanding}
+			if got := installmentPrincipal(credit, tt.row); got.Minor() != tt.principal {
+				t.Errorf("installmentPrincipal = %s, want %d minor units", got, tt.principal)
+			}
+			got := installmentDue(credit, tt.row)
+			if got.Minor() != tt.due || got.Currency() != models.DefaultCurrency {
+				t.Errorf("installmentDue = %s %s, want %d minor units", got, got.Currency(), tt.due)
+			}
+		})
+	}
+}
@@ -15,70 +15,325 @@ type PaymentService struct {
 	creditRepo   *repositories.CreditRepository
 	accountRepo  *repositories.AccountRepository
 	userRepo	 *repositories.UserRepository
+	penaltyRepo  *repositories.PenaltyRepository
+	certificateRepo *repositories.ClosureCertificateRepository
 	smtpService  *SMTPService
+	uow          *repositories.UnitOfWork
 }
 
-func NewPaymentService(repo *repositories.PaymentRepository, creditRepo *repositories.CreditRepository, accountRepo *repositories.AccountRepository, userRepo *repositories.UserRepository, smtpService *SMTPService) *PaymentService {
+func NewPaymentService(repo *repositories.PaymentRepository, creditRepo *repositories.CreditRepository, accountRepo *repositories.AccountRepository, userRepo *repositories.UserRepository, penaltyRepo *repositories.PenaltyRepository, certificateRepo *repositories.ClosureCertificateRepository, smtpService *SMTPService, uow *repositories.UnitOfWork) *PaymentService {
 	return &PaymentService{
 		repo:         repo,
 		creditRepo:   creditRepo,
 		accountRepo:  accountRepo,
 		userRepo: userRepo,
+		penaltyRepo:  penaltyRepo,
+		certificateRepo: certificateRepo,
 		smtpService:  smtpService,
+		uow:          uow,
 	}
 }
 
-func (s *PaymentService) CreatePayment(creditID uint, amount float64) (*models.Payment, error) {
-	// Получаем кредит
-	credit, err := s.creditRepo.GetCreditByID(creditID)
+// CreatePayment принимает платеж по кредиту. Платеж распределяется по составляющим задолженности
+// в порядке очередности: неустойка, комиссии, проценты, основной долг.
+// Без earlyRepayment это очередной платеж по графику: сумма должна совпадать с суммой к оплате по ближайшей
+// строке графика (задолженность по неустойке, комиссиям и процентам плюс основной долг по строке).
+// С earlyRepayment ("reduce_term" или "reduce_payment") это досрочное погашение произвольной суммы, после
+// которого оставшийся график пересчитывается. Погашение всей задолженности закрывает кредит
+func (s *PaymentService) CreatePayment(creditID uint, amount models.Money, earlyRepayment string) (*models.Payment, error) {
+	switch earlyRepayment {
+	case "", models.EarlyRepaymentReduceTerm, models.EarlyRepaymentReducePayment:
+	default:
+		return nil, validationError("early_repayment must be %q or %q", models.EarlyRepaymentReduceTerm, models.EarlyRepaymentReducePayment)
+	}
+	amount = amount.WithCurrency(models.DefaultCurrency)
+
+	var payment *models.Payment
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		// Получаем кредит под блокировкой, чтобы параллельные платежи не погасили его дважды
+		credit, err := tx.Credits.GetCreditForUpdate(creditID)
+		if err != nil {
+			return fmt.Errorf("failed to get credit: %v", err)
+		}
+
+		// Проверяем статус кредита
+		if credit.Status == models.CreditClosed || !credit.Amount.IsPositive() {
+			return validationError("credit is already paid off")
+		}
+
+		schedule, err := tx.Credits.GetPaymentSchedule(creditID)
+		if err != nil {
+			return fmt.Errorf("failed to get payment schedule: %v", err)
+		}
+		var unpaid []models.PaymentSchedule
+		for _, row := range schedule {
+			if !row.IsPaid {
+				unpaid = append(unpaid, row)
+			}
+		}
+
+		// Проценты по наступившим платежам и неустойка по просроченным на текущий день включаются
+		// в задолженность до распределения платежа, так же как в расчете суммы полного погашения
+		now := time.Now()
+		if err := s.accrueDueInterest(tx, credit, unpaid, now); err != nil {
+			return err
+		}
+		if err := s.accrueOverduePenalties(tx, credit, loadPenaltyPolicy(), now); err != nil {
+			return err
+		}
+
+		// Досрочный платеж, покрывающий всю задолженность, — полное погашение: к нему добавляются проценты
+		// текущего периода по сегодняшний день, как в расчете суммы полного погашения
+		if earlyRepayment != "" && !amount.LessThan(credit.Amount) {
+			row, interest := periodInterest(unpaid, now)
+			payoff := credit.Amount.Add(interest)
+			if amount.LessThan(payoff) {
+				return validationError("full repayment amount is %s", payoff)
+			}
+			if err := s.accruePeriodInterest(tx, credit, row, interest); err != nil {
+				return err
+			}
+		}
+
+		// Проверяем минимальную сумму платежа. Остаток задолженности меньше минимума можно погасить целиком
+		minPayment := models.NewMoney(100_00, models.DefaultCurrency)
+		if amount.LessThan(minPayment) && amount.Cmp(credit.Amount) != 0 {
+			return validationError("payment amount is too small")
+		}
+
+		if earlyRepayment == "" {
+			payment, err = s.payInstallment(tx, credit, unpaid, amount)
+		} else {
+			payment, err = s.repayEarly(tx, credit, unpaid, amount, earlyRepayment)
+		}
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get credit: %v", err)
+		return nil, err
+	}
+
+	s.sendClosureCertificate(creditID)
+	return payment, nil
+}
+
+// payInstallment погашает ближайший непогашенный платеж графика
+func (s *PaymentService) payInstallment(tx *repositories.Tx, credit *models.Credit, unpaid []models.PaymentSchedule, amount models.Money) (*models.Payment, error) {
+	if len(unpaid) == 0 {
+		return nil, validationError("no scheduled installments left, use early_repayment to pay off the remaining balance")
+	}
+	next := unpaid[0]
+	if due := installmentDue(credit, next); amount.Cmp(due) != 0 {
+		return nil, validationError("amount must equal the amount due %s for the installment due %s; specify early_repayment to pay a different amount",
+			due, next.DueDate.Format("2006-01-02"))
+	}
+
+	return s.settleInstallment(tx, credit, &next, len(unpaid) == 1, nil)
+}
+
+// settleInstallment погашает строку графика row вместе с задолженностью по неустойке, комиссиям и процентам.
+// accountID — счет клиента, с которого списан платеж (nil для платежа, поступившего извне).
+// last — row является последним непогашенным платежом графика
+func (s *PaymentService) settleInstallment(tx *repositories.Tx, credit *models.Credit, row *models.PaymentSchedule, last bool, accountID *uint) (*models.Payment, error) {
+	// Проценты по строке, погашаемой до даты платежа, начисляются в момент погашения
+	if err := s.accrueInterest(tx, credit, row); err != nil {
+		return nil, err
+	}
+	amount := installmentDue(credit, *row)
+	allocation := allocatePayment(credit.Outstanding, amount)
+
+	now := time.Now()
+	if err := tx.Credits.UpdatePaymentScheduleStatus(row.ID, true); err != nil {
+		return nil, fmt.Errorf("failed to update payment schedule status: %v", err)
+	}
+
+	// Исполняем ожидающий (или просроченный) платеж по этой строке графика
+	payment, err := tx.Payments.CompleteScheduledPayment(row.ID, amount, accountID, now)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		payment = &models.Payment{
+			CreditID:    credit.ID,
+			ScheduleID:  &row.ID,
+			AccountID:   accountID,
+			Type:        models.PaymentScheduled,
+			Amount:      amount,
+			PaymentDate: now,
+			Status:      "completed",
+			CreatedAt:   now,
+		}
+		if err := tx.Payments.CreatePayment(payment); err != nil {
+			return nil, fmt.Errorf("failed to create payment: %v", err)
+		}
 	}
+	payment.Amount = payment.Amount.WithCurrency(models.DefaultCurrency)
 
-	// Проверяем минимальную сумму платежа
-	minPayment := 100.0
-	if amount < minPayment {
-		return nil, fmt.Errorf("payment amount is too small")
+	if err := s.repayBalance(tx, credit, payment, allocation); err != nil {
+		return nil, err
 	}
 
-	// Проверяем максимальную сумму платежа
-	maxPayment := credit.Amount
-	if amount > maxPayment {
-		return nil, fmt.Errorf("payment amount exceeds credit balance")
+	if last && !credit.Amount.IsPositive() {
+		if err := s.closeCredit(tx, credit, now); err != nil {
+			return nil, err
+		}
 	}
+	return payment, nil
+}
 
-	// Проверяем статус кредита
-	if credit.Amount <= 0 {
-		return nil, fmt.Errorf("credit is already paid off")
+// repayEarly выполняет частичное или полное досрочное погашение. Частичное погашение невозможно
+// при наличии просроченных платежей, полное погашает и их
+func (s *PaymentService) repayEarly(tx *repositories.Tx, credit *models.Credit, unpaid []models.PaymentSchedule, amount models.Money, mode string) (*models.Payment, error) {
+	if amount.GreaterThan(credit.Amount) {
+		return nil, validationError("payment amount exceeds credit balance %s", credit.Amount)
 	}
+	now := time.Now()
+	for _, row := range unpaid {
+		if row.DueDate.Before(now) && amount.LessThan(credit.Amount) {
+			return nil, validationError("credit has an overdue installment due %s, pay it before early repayment", row.DueDate.Format("2006-01-02"))
+		}
+	}
+	allocation := allocatePayment(credit.Outstanding, amount)
 
-	// Создаем платеж
 	payment := &models.Payment{
-		CreditID:    creditID,
+		CreditID:    credit.ID,
+		Type:        models.PaymentEarlyRepayment,
 		Amount:      amount,
-		PaymentDate: time.Now(),
+		PaymentDate: now,
 		Status:      "completed",
-		CreatedAt:   time.Now(),
+		CreatedAt:   now,
 	}
-
-	// Сохраняем платеж в базе данных
-	if err := s.repo.CreatePayment(payment); err != nil {
+	if err := tx.Payments.CreatePayment(payment); err != nil {
 		return nil, fmt.Errorf("failed to create payment: %v", err)
 	}
-
-	// Обновляем статус платежа в графике платежей
-	if err := s.updatePaymentSchedule(creditID, amount); err != nil {
-		return nil, fmt.Errorf("failed to update payment schedule: %v", err)
+	if err := s.repayBalance(tx, credit, payment, allocation); err != nil {
+		return nil, err
 	}
 
-	// Обновляем баланс кредита
-	if err := s.updateCreditBalance(creditID, amount); err != nil {
-		return nil, fmt.Errorf("failed to update credit balance: %v", err)
+	// Полное погашение: отменяем оставшиеся платежи и закрываем кредит
+	if !credit.Amount.IsPositive() {
+		if _, err := tx.Payments.CancelOpenPayments(credit.ID); err != nil {
+			return nil, err
+		}
+		if err := tx.Credits.DeleteUnpaidSchedule(credit.ID); err != nil {
+			return nil, err
+		}
+		if err := s.closeCredit(tx, credit, now); err != nil {
+			return nil, err
+		}
+		return payment, nil
 	}
 
+	// Частичное погашение основного долга: пересчитываем оставшийся график
+	if allocation.Principal.IsPositive() && len(unpaid) > 0 {
+		if err := tx.Credits.RescheduleUnpaid(credit, credit.Outstanding.Principal, mode); err != nil {
+			return nil, fmt.Errorf("failed to recalculate payment schedule: %v", err)
+		}
+	}
 	return payment, nil
 }
 
+// repayBalance уменьшает задолженность по кредиту на распределенный платеж и отражает погашение в главной книге
+func (s *PaymentService) repayBalance(tx *repositories.Tx, credit *models.Credit, payment *models.Payment, allocation models.CreditBalance) error {
+	if err := tx.Credits.AdjustBalance(credit.ID, allocation.Neg()); err != nil {
+		return err
+	}
+	if err := tx.Ledger.PostEntry(creditRepaymentEntry(payment)); err != nil {
+		return fmt.Errorf("failed to post credit repayment to ledger: %v", err)
+	}
+	credit.Outstanding = credit.Outstanding.Add(allocation.Neg())
+	credit.Amount = credit.Outstanding.Total()
+	return nil
+}
+
+// accrueDueInterest включает в задолженность проценты по строкам графика с наступившей датой платежа
+func (s *PaymentService) accrueDueInterest(tx *repositories.Tx, credit *models.Credit, unpaid []models.PaymentSchedule, asOf time.Time) error {
+	for i := range unpaid {
+		if unpaid[i].DueDate.After(asOf) {
+			break
+		}
+		if err := s.accrueInterest(tx, credit, &unpaid[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// accrueInterest включает проценты по строке графика в задолженность по кредиту. Повторное начисление
+// по той же строке не выполняется
+func (s *PaymentService) accrueInterest(tx *repositories.Tx, credit *models.Credit, row *models.PaymentSchedule) error {
+	if row.InterestAccrued {
+		return nil
+	}
+	row.InterestAccrued = true
+	if !row.Interest.IsPositive() {
+		return nil
+	}
+
+	accrued, err := tx.Credits.MarkInterestAccrued(row.ID)
+	if err != nil || !accrued {
+		return err
+	}
+	delta := models.CreditBalance{Interest: row.Interest}
+	if err := tx.Credits.AdjustBalance(credit.ID, delta); err != nil {
+		return err
+	}
+	if err := tx.Ledger.PostEntry(interestAccrualEntry(credit.ID, row)); err != nil {
+		return fmt.Errorf("failed to post interest accrual to ledger: %v", err)
+	}
+	credit.Outstanding = credit.Outstanding.Add(delta)
+	credit.Amount = credit.Outstanding.Total()
+	return nil
+}
+
+// accruePeriodInterest включает в задолженность проценты текущего периода, накопленные по строке row
+// к дате полного погашения. Строка не помечается начисленной: после полного погашения она удаляется
+func (s *PaymentService) accruePeriodInterest(tx *repositories.Tx, credit *models.Credit, row *models.PaymentSchedule, interest models.Money) error {
+	if row == nil || !interest.IsPositive() {
+		return nil
+	}
+	delta := models.CreditBalance{Interest: interest}
+	if err := tx.Credits.AdjustBalance(credit.ID, delta); err != nil {
+		return err
+	}
+	accrual := *row
+	accrual.Interest = interest
+	if err := tx.Ledger.PostEntry(interestAccrualEntry(credit.ID, &accrual)); err != nil {
+		return fmt.Errorf("failed to post interest accrual to ledger: %v", err)
+	}
+	credit.Outstanding = credit.Outstanding.Add(delta)
+	credit.Amount = credit.Outstanding.Total()
+	return nil
+}
+
+// AccrueInterest включает в задолженность проценты по наступившим платежам графика
+func (s *PaymentService) AccrueInterest() error {
+	rows, err := s.creditRepo.GetDueUnaccruedSchedule()
+	if err != nil {
+		return fmt.Errorf("failed to get due payment schedule: %v", err)
+	}
+
+	for _, row := range rows {
+		err := s.uow.Do(func(tx *repositories.Tx) error {
+			credit, err := tx.Credits.GetCreditForUpdate(row.CreditID)
+			if err != nil {
+				return fmt.Errorf("failed to get credit: %v", err)
+			}
+			if credit.Status != models.CreditActive {
+				return nil
+			}
+			return s.accrueInterest(tx, credit, &row)
+		})
+		if err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"error":       err.Error(),
+				"schedule.ID": row.ID,
+			}).Error("Failed to accrue interest for payment schedule")
+		}
+	}
+
+	return nil
+}
+
 func (s *PaymentService) GetPaymentsByCreditID(creditID uint) ([]models.Payment, error) {
 	return s.repo.GetPaymentsByCreditID(creditID)
 }
@@ -87,45 +342,92 @@ func (s *PaymentService) GetPaymentByID(paymentID uint) (*models.Payment, error)
 	return s.repo.GetPaymentByID(paymentID)
 }
 
-func (s *PaymentService) updatePaymentSchedule(creditID uint, amount float64) error {
-	// Получаем график платежей
-	schedule, err := s.creditRepo.GetPaymentSchedule(creditID)
+// ProcessAutoDebits списывает наступившие платежи по графику со счетов, привязанных к кредитам.
+// Платеж, на который не хватает средств, остается неисполненным и обрабатывается как просроченный
+func (s *PaymentService) ProcessAutoDebits() error {
+	payments, err := s.repo.GetAutoDebitPayments()
 	if err != nil {
-		return fmt.Errorf("failed to get payment schedule: %v", err)
+		return fmt.Errorf("failed to get auto-debit payments: %v", err)
 	}
 
-	// Находим первый непогашенный платеж
-	for _, payment := range schedule {
-		if !payment.IsPaid {
-			// Обновляем статус платежа
-			if err := s.creditRepo.UpdatePaymentScheduleStatus(payment.ID, true); err != nil {
-				return fmt.Errorf("failed to update payment schedule status: %v", err)
-			}
-			break
+	for _, payment := range payments {
+		if err := s.autoDebit(payment); err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"error":      err.Error(),
+				"payment.ID": payment.ID,
+			}).Warn("Failed to auto-debit credit payment")
 		}
 	}
 
 	return nil
 }
 
-func (s *PaymentService) updateCreditBalance(creditID uint, amount float64) error {
-	// Получаем кредит
-	credit, err := s.creditRepo.GetCreditByID(creditID)
-	if err != nil {
-		return fmt.Errorf("failed to get credit: %v", err)
-	}
+// autoDebit списывает платеж по графику с привязанного к кредиту счета в одной транзакции.
+// Вместе с платежом списываются неустойка, комиссии и проценты, ожидающие погашения
+func (s *PaymentService) autoDebit(payment models.Payment) error {
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		credit, err := tx.Credits.GetCreditForUpdate(payment.CreditID)
+		if err != nil {
+			return fmt.Errorf("failed to get credit: %v", err)
+		}
+		if credit.Status != models.CreditActive || credit.RepaymentAccountID == nil || payment.ScheduleID == nil {
+			return nil
+		}
+
+		schedule, err := tx.Credits.GetPaymentSchedule(credit.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get payment schedule: %v", err)
+		}
+		var row *models.PaymentSchedule
+		unpaidCount := 0
+		for i := range schedule {
+			if schedule[i].IsPaid {
+				continue
+			}
+			unpaidCount++
+			if schedule[i].ID == *payment.ScheduleID {
+				row = &schedule[i]
+			}
+		}
+		// Платеж уже погашен вручную или график был пересчитан
+		if row == nil {
+			return nil
+		}
+
+		if err := s.accrueInterest(tx, credit, row); err != nil {
+			return err
+		}
+		amount := installmentDue(credit, *row)
+
+		accountID := *credit.RepaymentAccountID
+		account, err := tx.Accounts.GetAccountForUpdate(accountID)
+		if err != nil {
+			return fmt.Errorf("failed to get repayment account: %v", err)
+		}
+		if account.Currency != models.DefaultCurrency {
+			return fmt.Errorf("repayment account %d currency %s does not match credit currency", accountID, account.Currency)
+		}
+		if account.AvailableBalance.LessThan(amount) {
+			return fmt.Errorf("insufficient funds on repayment account %d", accountID)
+		}
 
-	// Обновляем баланс кредита
-	newBalance := credit.Amount - amount
-	query := `UPDATE credits SET amount=$1 WHERE id=$2`
-	_, err = s.creditRepo.DB.Exec(query, newBalance, creditID)
+		if err := tx.Accounts.AdjustBalance(accountID, amount.Neg()); err != nil {
+			return err
+		}
+		_, err = s.settleInstallment(tx, credit, row, unpaidCount == 1, &accountID)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update credit balance: %v", err)
+		return err
 	}
 
+	s.sendClosureCertificate(payment.CreditID)
 	return nil
 }
 
+// ProcessOverduePayments начисляет неустойку по просроченным платежам за каждый день просрочки
+// по правилам penaltyPolicy до погашения платежа. Начисление за день сохраняется один раз,
+// поэтому повторный запуск не увеличивает задолженность. О новой просрочке заемщик получает уведомление
 func (s *PaymentService) ProcessOverduePayments() error {
 	// Получаем просроченные платежи
 	payments, err := s.repo.GetOverduePayments()
@@ -133,23 +435,20 @@ func (s *PaymentService) ProcessOverduePayments() error {
 		return fmt.Errorf("failed to get overdue payments: %v", err)
 	}
 
+	policy := loadPenaltyPolicy()
+	now := time.Now()
+
 	// Обрабатываем каждый просроченный платеж
 	for _, payment := range payments {
-		// Начисляем штраф
-		if err := s.applyPenalty(payment); err != nil {
+		newlyOverdue, err := s.accruePenalties(payment, policy, now)
+		if err != nil {
 			utils.Log.WithFields(logrus.Fields{
 				"error": err.Error(),
 				"payment.ID": payment.ID,
-			}).Error("Failed to apply penalty for payment")
+			}).Error("Failed to accrue penalties for payment")
 			continue
 		}
-
-		// Обновляем статус платежа
-		if err := s.repo.UpdatePaymentStatus(payment.ID, "failed"); err != nil {
-			utils.Log.WithFields(logrus.Fields{
-				"error": err.Error(),
-				"payment.ID": payment.ID,
-			}).Error("Failed to update payment status for payment")
+		if !newlyOverdue {
 			continue
 		}
 
@@ -184,25 +483,102 @@ func (s *PaymentService) ProcessOverduePayments() error {
 	return nil
 }
 
-func (s *PaymentService) applyPenalty(payment models.Payment) error {
-	// Получаем кредит
-	credit, err := s.creditRepo.GetCreditByID(payment.CreditID)
+// accruePenalties переводит платеж в статус "failed" и начисляет неустойку за дни просрочки по asOf
+// в одной транзакции. Возвращает true, если платеж стал просроченным при этом запуске
+func (s *PaymentService) accruePenalties(payment models.Payment, policy penaltyPolicy, asOf time.Time) (bool, error) {
+	var newlyOverdue bool
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		credit, err := tx.Credits.GetCreditForUpdate(payment.CreditID)
+		if err != nil {
+			return fmt.Errorf("failed to get credit: %v", err)
+		}
+		if credit.Status != models.CreditActive {
+			return nil
+		}
+
+		newlyOverdue, err = s.accruePaymentPenalties(tx, credit, payment.ID, policy, asOf)
+		return err
+	})
+	return newlyOverdue, err
+}
+
+// accrueOverduePenalties начисляет неустойку по asOf по всем просроченным платежам кредита
+func (s *PaymentService) accrueOverduePenalties(tx *repositories.Tx, credit *models.Credit, policy penaltyPolicy, asOf time.Time) error {
+	payments, err := tx.Payments.GetOpenScheduledPayments(credit.ID)
 	if err != nil {
-		return fmt.Errorf("failed to get credit: %v", err)
+		return err
+	}
+	for _, payment := range payments {
+		if !payment.PaymentDate.Before(asOf) {
+			break
+		}
+		if _, err := s.accruePaymentPenalties(tx, credit, payment.ID, policy, asOf); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	// Вычисляем штраф
-	penalty := payment.Amount * 0.1 // 10% от суммы платежа
+// accruePaymentPenalties переводит просроченный платеж в статус "failed" и начисляет по нему неустойку
+// за дни просрочки по asOf. Кредит должен быть заблокирован. Возвращает true, если платеж стал просроченным
+// при этом вызове
+func (s *PaymentService) accruePaymentPenalties(tx *repositories.Tx, credit *models.Credit, paymentID uint, policy penaltyPolicy, asOf time.Time) (bool, error) {
+	newlyOverdue, err := tx.Payments.TransitionPaymentStatus(paymentID, "pending", "failed")
+	if err != nil {
+		return false, err
+	}
+	// Платеж мог быть погашен после выборки просроченных платежей
+	current, err := tx.Payments.GetPaymentByID(paymentID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get payment: %v", err)
+	}
+	if current.Status != "failed" {
+		return newlyOverdue, nil
+	}
 
-	// Обновляем баланс кредита
-	newBalance := credit.Amount + penalty
-	query := `UPDATE credits SET amount=$1 WHERE id=$2`
-	_, err = s.creditRepo.DB.Exec(query, newBalance, credit.ID)
+	accrued, lastAccrued, err := tx.Penalties.GetAccrued(paymentID)
 	if err != nil {
-		return fmt.Errorf("failed to update credit balance: %v", err)
+		return false, err
 	}
 
-	return nil
+	var total models.Money
+	for _, accrual := range policy.accruals(current.Amount, current.PaymentDate, asOf, lastAccrued, accrued) {
+		penalty := &models.Penalty{
+			CreditID:    current.CreditID,
+			PaymentID:   current.ID,
+			AccrualDate: accrual.Date,
+			Amount:      accrual.Amount,
+			CreatedAt:   asOf,
+		}
+		added, err := tx.Penalties.AddAccrual(penalty)
+		if err != nil {
+			return false, err
+		}
+		if added {
+			total = total.Add(accrual.Amount)
+		}
+	}
+	if !total.IsPositive() {
+		return newlyOverdue, nil
+	}
+
+	// Обновляем задолженность по неустойке
+	delta := models.CreditBalance{Penalties: total}
+	if err := tx.Credits.AdjustBalance(credit.ID, delta); err != nil {
+		return false, err
+	}
+	credit.Outstanding = credit.Outstanding.Add(delta)
+	credit.Amount = credit.Outstanding.Total()
+
+	// Отражаем неустойку в главной книге
+	if err := tx.Ledger.PostEntry(penaltyEntry(current, total)); err != nil {
+		return false, fmt.Errorf("failed to post penalty to ledger: %v", err)
+	}
+	return newlyOverdue, nil
+}
+
+func (s *PaymentService) GetPenaltiesByCreditID(creditID uint) ([]models.Penalty, error) {
+	return s.penaltyRepo.GetPenaltiesByCreditID(creditID)
 }
 
 func (s *PaymentService) CreditBelongsToUser(creditID uint, userID uint) bool {
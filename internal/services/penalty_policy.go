@@ -0,0 +1,76 @@
+package services
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+// penaltyPolicy — правила начисления неустойки по просроченному платежу
+type penaltyPolicy struct {
+	DailyRate  float64 // процент от суммы платежа за каждый день просрочки
+	GraceDays  int     // дни после даты платежа, за которые неустойка не начисляется
+	MaxPercent float64 // предел неустойки по одному платежу в процентах от его суммы, 0 — без ограничения
+}
+
+// penaltyAccrual — начисление неустойки за один день
+type penaltyAccrual struct {
+	Date   time.Time
+	Amount models.Money
+}
+
+// loadPenaltyPolicy читает правила из переменных окружения PENALTY_DAILY_RATE,
+// PENALTY_GRACE_DAYS и PENALTY_MAX_PERCENT
+func loadPenaltyPolicy() penaltyPolicy {
+	return penaltyPolicy{
+		DailyRate:  envPercent("PENALTY_DAILY_RATE", 0.1),
+		GraceDays:  envInt("PENALTY_GRACE_DAYS", 0),
+		MaxPercent: envPercent("PENALTY_MAX_PERCENT", 10),
+	}
+}
+
+// accruals рассчитывает начисления по платежу installment с датой dueDate за дни по asOf включительно.
+// Дни до lastAccrued включительно уже начислены, accrued — сумма этих начислений
+func (p penaltyPolicy) accruals(installment models.Money, dueDate, asOf time.Time, lastAccrued *time.Time, accrued models.Money) []penaltyAccrual {
+	daily := installment.Percent(p.DailyRate, models.DefaultRounding)
+	if !daily.IsPositive() {
+		return nil
+	}
+	var remaining models.Money
+	if p.MaxPercent > 0 {
+		remaining = installment.Percent(p.MaxPercent, models.DefaultRounding).Sub(accrued)
+	}
+
+	day := dateOnly(dueDate).AddDate(0, 0, p.GraceDays+1)
+	if lastAccrued != nil {
+		if next := dateOnly(*lastAccrued).AddDate(0, 0, 1); next.After(day) {
+			day = next
+		}
+	}
+
+	var result []penaltyAccrual
+	for last := dateOnly(asOf); !day.After(last); day = day.AddDate(0, 0, 1) {
+		amount := daily
+		if p.MaxPercent > 0 {
+			if !remaining.IsPositive() {
+				break
+			}
+			if amount.GreaterThan(remaining) {
+				amount = remaining
+			}
+			remaining = remaining.Sub(amount)
+		}
+		result = append(result, penaltyAccrual{Date: day, Amount: amount})
+	}
+	return result
+}
+
+func envPercent(name string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(name), 64)
+	if err != nil || value < 0 || value > 100 {
+		return defaultValue
+	}
+	return value
+}
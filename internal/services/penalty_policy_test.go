@@ -0,0 +1,155 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+)
+
+func TestPenaltyPolicyAccruals(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatalf("bad test date %q", s)
+		}
+		return d
+	}
+	datePtr := func(s string) *time.Time {
+		d := date(s)
+		return &d
+	}
+
+	installment := models.NewMoney(10_000_00, models.DefaultCurrency) // 10 000.00, 0.1% в день — 10.00
+	tests := []struct {
+		name        string
+		policy      penaltyPolicy
+		installment models.Money
+		asOf        string
+		lastAccrued *time.Time
+		accrued     int64
+		first       string  // дата первого начисления
+		amounts     []int64 // суммы начислений по дням подряд, в копейках
+	}{
+		{
+			name:        "no grace",
+			policy:      penaltyPolicy{DailyRate: 0.1},
+			installment: installment,
+			asOf:        "2025-03-13",
+			first:       "2025-03-11",
+			amounts:     []int64{10_00, 10_00, 10_00},
+		},
+		{
+			name:        "not overdue yet",
+			policy:      penaltyPolicy{DailyRate: 0.1},
+			installment: installment,
+			asOf:        "2025-03-10",
+		},
+		{
+			name:        "first overdue day",
+			policy:      penaltyPolicy{DailyRate: 0.1},
+			installment: installment,
+			asOf:        "2025-03-11",
+			first:       "2025-03-11",
+			amounts:     []int64{10_00},
+		},
+		{
+			name:        "last day of grace",
+			policy:      penaltyPolicy{DailyRate: 0.1, GraceDays: 3},
+			installment: installment,
+			asOf:        "2025-03-13",
+		},
+		{
+			name:        "first day after grace",
+			policy:      penaltyPolicy{DailyRate: 0.1, GraceDays: 3},
+			installment: installment,
+			asOf:        "2025-03-14",
+			first:       "2025-03-14",
+			amounts:     []int64{10_00},
+		},
+		{
+			name:        "cap reached mid-run",
+			policy:      penaltyPolicy{DailyRate: 0.1, MaxPercent: 0.25},
+			installment: installment,
+			asOf:        "2025-03-20",
+			first:       "2025-03-11",
+			amounts:     []int64{10_00, 10_00, 5_00},
+		},
+		{
+			name:        "cap already reached",
+			policy:      penaltyPolicy{DailyRate: 0.1, MaxPercent: 0.25},
+			installment: installment,
+			asOf:        "2025-03-20",
+			lastAccrued: datePtr("2025-03-13"),
+			accrued:     25_00,
+		},
+		{
+			name:        "resume from lastAccrued",
+			policy:      penaltyPolicy{DailyRate: 0.1},
+			installment: installment,
+			asOf:        "2025-03-15",
+			lastAccrued: datePtr("2025-03-12"),
+			accrued:     20_00,
+			first:       "2025-03-13",
+			amounts:     []int64{10_00, 10_00, 10_00},
+		},
+		{
+			name:        "resume respects remaining cap",
+			policy:      penaltyPolicy{DailyRate: 0.1, MaxPercent: 0.25},
+			installment: installment,
+			asOf:        "2025-03-20",
+			lastAccrued: datePtr("2025-03-12"),
+			accrued:     20_00,
+			first:       "2025-03-13",
+			amounts:     []int64{5_00},
+		},
+		{
+			name:        "lastAccrued inside grace is ignored",
+			policy:      penaltyPolicy{DailyRate: 0.1, GraceDays: 3},
+			installment: installment,
+			asOf:        "2025-03-15",
+			lastAccrued: datePtr("2025-03-11"),
+			first:       "2025-03-14",
+			amounts:     []int64{10_00, 10_00},
+		},
+		{
+			name:        "already accrued through asOf",
+			policy:      penaltyPolicy{DailyRate: 0.1},
+			installment: installment,
+			asOf:        "2025-03-15",
+			lastAccrued: datePtr("2025-03-15"),
+			accrued:     50_00,
+		},
+		{
+			name:        "zero daily rate",
+			policy:      penaltyPolicy{DailyRate: 0, MaxPercent: 10},
+			installment: installment,
+			asOf:        "2025-04-30",
+		},
+		{
+			name:        "daily penalty rounds to zero",
+			policy:      penaltyPolicy{DailyRate: 0.1},
+			installment: models.NewMoney(4, models.DefaultCurrency),
+			asOf:        "2025-04-30",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asOf := date(tt.asOf).Add(15 * time.Hour)
+			accrued := models.NewMoney(tt.accrued, models.DefaultCurrency)
+			got := tt.policy.accruals(tt.installment, date("2025-03-10"), asOf, tt.lastAccrued, accrued)
+
+			if len(got) != len(tt.amounts) {
+				t.Fatalf("got %d accruals, want %d: %+v", len(got), len(tt.amounts), got)
+			}
+			for i, accrual := range got {
+				if want := date(tt.first).AddDate(0, 0, i); !accrual.Date.Equal(want) {
+					t.Errorf("accrual %d: date = %s, want %s", i, accrual.Date.Format("2006-01-02"), want.Format("2006-01-02"))
+				}
+				if accrual.Amount.Minor() != tt.amounts[i] {
+					t.Errorf("accrual %d: amount = %s, want %d minor units", i, accrual.Amount, tt.amounts[i])
+				}
+			}
+		})
+	}
+}
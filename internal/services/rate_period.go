@@ -0,0 +1,32 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Ограничения периода истории: ключевая ставка приходит одним запросом,
+// а курсы валют запрашиваются у ЦБ РФ по одному дню
+const (
+	maxKeyRatePeriodDays      = 3660
+	maxCurrencyRatePeriodDays = 366
+)
+
+var ErrInvalidRatePeriod = errors.New("invalid rate period")
+
+// validateRatePeriod приводит границы периода к датам и проверяет его длину.
+// Будущие даты отсекаются: официальных данных за них еще нет
+func validateRatePeriod(from, to time.Time, maxDays int) (time.Time, time.Time, error) {
+	from, to = dateOnly(from), dateOnly(to)
+	if today := dateOnly(time.Now()); to.After(today) {
+		to = today
+	}
+	if from.After(to) {
+		return from, to, fmt.Errorf("%w: from must not be after to", ErrInvalidRatePeriod)
+	}
+	if to.Sub(from) > time.Duration(maxDays)*24*time.Hour {
+		return from, to, fmt.Errorf("%w: period must not exceed %d days", ErrInvalidRatePeriod, maxDays)
+	}
+	return from, to, nil
+}
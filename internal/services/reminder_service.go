@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+// maxPaymentReminderDays — предел настройки напоминаний пользователя
+const maxPaymentReminderDays = 30
+
+// defaultPaymentReminderDays возвращает, за сколько дней до платежа напоминать пользователям без собственной
+// настройки (переменная окружения PAYMENT_REMINDER_DAYS, по умолчанию 3)
+func defaultPaymentReminderDays() int {
+	days := envInt("PAYMENT_REMINDER_DAYS", 3)
+	if days < 0 || days > maxPaymentReminderDays {
+		return 3
+	}
+	return days
+}
+
+type ReminderService struct {
+	repo        *repositories.PaymentReminderRepository
+	smtpService *SMTPService
+	uow         *repositories.UnitOfWork
+}
+
+func NewReminderService(repo *repositories.PaymentReminderRepository, smtpService *SMTPService, uow *repositories.UnitOfWork) *ReminderService {
+	return &ReminderService{
+		repo:        repo,
+		smtpService: smtpService,
+		uow:         uow,
+	}
+}
+
+// SendPaymentReminders напоминает заемщикам о платежах по графику, до которых осталось не больше дней,
+// чем задано в их настройках. По каждой строке графика напоминание отправляется один раз: отметка
+// об отправке сохраняется в той же транзакции, поэтому повторный или параллельный запуск его не дублирует,
+// а при ошибке отправки напоминание повторяется при следующем запуске
+func (s *ReminderService) SendPaymentReminders() error {
+	now := time.Now()
+	reminders, err := s.repo.GetDueReminders(defaultPaymentReminderDays(), now)
+	if err != nil {
+		return fmt.Errorf("failed to get due payment reminders: %v", err)
+	}
+
+	for _, reminder := range reminders {
+		err := s.uow.Do(func(tx *repositories.Tx) error {
+			claimed, err := tx.Reminders.ClaimReminder(&reminder, now)
+			if err != nil || !claimed {
+				return err
+			}
+			return s.smtpService.SendPaymentReminder(reminder.Email, reminder.Amount, reminder.DueDate)
+		})
+		if err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"error":       err.Error(),
+				"schedule.ID": reminder.ScheduleID,
+			}).Warn("Failed to send payment reminder")
+		}
+	}
+
+	return nil
+}
@@ -1,38 +1,386 @@
 package services
 
 import (
-		"time"
-		"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
+)
+
+var (
+	// ErrJobNotFound — задача с таким именем не зарегистрирована
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobRunning — задача уже выполняется на этом или другом экземпляре
+	ErrJobRunning = errors.New("job is already running")
 )
 
+const (
+	// schedulerTick — как часто планировщик проверяет лидерство и наступление расписаний
+	schedulerTick = 30 * time.Second
+	// schedulerLeaderLock — блокировка, которую держит единственный экземпляр, запускающий задачи по расписанию
+	schedulerLeaderLock = "scheduler:leader"
+	// jobLockPrefix — префикс блокировки отдельной задачи: задача не выполняется параллельно даже при ручном запуске
+	jobLockPrefix = "job:"
+	// jobRunsHistoryLimit — сколько последних запусков задачи возвращает API
+	jobRunsHistoryLimit = 50
+)
+
+// scheduledJob — задача планировщика
+type scheduledJob struct {
+	name     string
+	spec     string
+	schedule cronSchedule
+	run      func() error
+}
+
+// SchedulerService запускает зарегистрированные задачи по расписанию в формате cron. Задачи по расписанию
+// выполняет только экземпляр-лидер, захвативший advisory-блокировку PostgreSQL; каждый запуск сохраняется
+// в таблице job_runs. Следующий запуск отсчитывается от последнего запуска по расписанию, поэтому
+// перезапуск сервиса не запускает задачи повторно, а пропущенный во время простоя запуск выполняется один раз
 type SchedulerService struct {
-	paymentService *PaymentService
+	jobRunRepo  *repositories.JobRunRepository
+	lockManager *repositories.LockManager
+	jobs        []*scheduledJob
+	jobsByName  map[string]*scheduledJob
+	instance    string
+	startedAt   time.Time
+	leader      *repositories.AdvisoryLock
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+func NewSchedulerService(jobRunRepo *repositories.JobRunRepository, lockManager *repositories.LockManager, cardService *CardService, cardPaymentService *CardPaymentService, holdService *HoldService, paymentService *PaymentService, ledgerService *LedgerService, applicationService *CreditApplicationService, reminderService *ReminderService) (*SchedulerService, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &SchedulerService{
+		jobRunRepo:  jobRunRepo,
+		lockManager: lockManager,
+		jobsByName:  make(map[string]*scheduledJob),
+		instance:    schedulerInstance(),
+		startedAt:   time.Now(),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	// Задачи выполняются в порядке регистрации. Проценты по наступившим платежам начисляются до автосписания,
+	// автосписание идет раньше обработки просрочек, чтобы штраф начислялся только по платежам,
+	// на которые не хватило средств
+	jobs := []struct {
+		name string
+		spec string
+		run  func() error
+	}{
+		{"accrue_interest", "0 0,12 * * *", paymentService.AccrueInterest},
+		{"auto_debits", "5 0,12 * * *", paymentService.ProcessAutoDebits},
+		{"overdue_payments", "10 0,12 * * *", paymentService.ProcessOverduePayments},
+		{"reconcile_ledger", "30 0,12 * * *", func() error {
+			mismatches, err := ledgerService.Reconcile()
+			if err != nil {
+				return err
+			}
+			utils.Log.Infof("Ledger reconciliation mismatches found: %d", len(mismatches))
+			return nil
+		}},
+		{"score_applications", "*/15 * * * *", applicationService.ScoreSubmitted},
+		{"payment_reminders", "0 9 * * *", reminderService.SendPaymentReminders},
+		{"expire_cards", "15 0 * * *", cardService.ExpireCards},
+		{"expire_card_authorizations", "*/10 * * * *", cardPaymentService.ExpireAuthorizations},
+		{"expire_holds", "*/10 * * * *", holdService.ExpireHolds},
+	}
+	for _, job := range jobs {
+		if err := s.register(job.name, job.spec, job.run); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
-func NewSchedulerService(paymentService *PaymentService) *SchedulerService {
-	return &SchedulerService{
-		paymentService: paymentService,
+// register добавляет задачу в реестр. Расписание по умолчанию переопределяется переменной окружения
+// JOB_SCHEDULE_<ИМЯ ЗАДАЧИ>, например JOB_SCHEDULE_PAYMENT_REMINDERS="0 10 * * *"
+func (s *SchedulerService) register(name, spec string, run func() error) error {
+	if value := strings.TrimSpace(os.Getenv("JOB_SCHEDULE_" + strings.ToUpper(name))); value != "" {
+		spec = value
 	}
+	schedule, err := parseCronSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %v", name, err)
+	}
+
+	job := &scheduledJob{name: name, spec: spec, schedule: schedule, run: run}
+	s.jobs = append(s.jobs, job)
+	s.jobsByName[name] = job
+	return nil
 }
 
+// schedulerInstance возвращает имя экземпляра сервиса для истории запусков
+func schedulerInstance() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// Start запускает цикл планировщика в фоне
 func (s *SchedulerService) Start() {
-	// Запускаем шедулер
-	ticker := time.NewTicker(12 * time.Hour)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.resignLeadership()
+
+		ticker := time.NewTicker(schedulerTick)
+		defer ticker.Stop()
+		for {
+			s.tick()
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop останавливает планировщик: новые запуски не начинаются, выполняющиеся задачи завершаются
+// до истечения ctx. Блокировка лидера снимается, чтобы другой экземпляр сразу занял его место
+func (s *SchedulerService) Stop(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
 	go func() {
-		s.ProcessOverduePayments()
-		for range ticker.C {
-			s.ProcessOverduePayments()
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		utils.Log.Info("Scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("scheduler did not stop in time: %v", ctx.Err())
+	}
+}
+
+// tick запускает по очереди задачи, время которых наступило, если этот экземпляр — лидер
+func (s *SchedulerService) tick() {
+	if !s.ensureLeadership() {
+		return
+	}
+
+	for _, job := range s.jobs {
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		next, err := s.nextRun(job)
+		if err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"job":   job.name,
+				"error": err.Error(),
+			}).Warn("Failed to get next job run")
+			continue
+		}
+		if next.After(time.Now()) {
+			continue
+		}
+
+		run, lock, err := s.startRun(job, models.JobTriggerSchedule)
+		if errors.Is(err, ErrJobRunning) {
+			// Задачу запустили вручную: запуск по расписанию повторится на следующем такте
+			continue
+		} else if err != nil {
+			utils.Log.WithFields(logrus.Fields{
+				"job":   job.name,
+				"error": err.Error(),
+			}).Warn("Failed to start job")
+			continue
+		}
+		s.finishRun(job, run, lock)
+	}
+}
+
+// ensureLeadership проверяет, что экземпляр держит блокировку лидера, и пытается захватить ее, если нет
+func (s *SchedulerService) ensureLeadership() bool {
+	ctx, cancel := context.WithTimeout(s.ctx, schedulerTick)
+	defer cancel()
+
+	if s.leader != nil {
+		if s.leader.Alive(ctx) {
+			return true
+		}
+		utils.Log.Warn("Scheduler lost leadership: lock connection is broken")
+		s.resignLeadership()
+	}
+
+	lock, err := s.lockManager.TryLock(ctx, schedulerLeaderLock)
+	if err != nil {
+		utils.Log.WithError(err).Warn("Failed to acquire scheduler leadership")
+		return false
+	}
+	if lock == nil {
+		return false
+	}
+	s.leader = lock
+	utils.Log.WithField("instance", s.instance).Info("Scheduler acquired leadership")
+	return true
+}
+
+// resignLeadership снимает блокировку лидера
+func (s *SchedulerService) resignLeadership() {
+	if s.leader == nil {
+		return
+	}
+	if err := s.leader.Unlock(); err != nil {
+		utils.Log.WithError(err).Warn("Failed to release scheduler leadership")
+	}
+	s.leader = nil
+}
+
+// nextRun возвращает время следующего запуска задачи по расписанию: первое время по расписанию после
+// последнего запуска по расписанию, а для задачи без истории — после старта экземпляра
+func (s *SchedulerService) nextRun(job *scheduledJob) (time.Time, error) {
+	last, err := s.jobRunRepo.GetLastRun(job.name, models.JobTriggerSchedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if last == nil {
+		return job.schedule.Next(s.startedAt), nil
+	}
+	return job.schedule.Next(last.StartedAt), nil
+}
+
+// startRun захватывает блокировку задачи и сохраняет начало запуска. Запуски, оставшиеся в статусе
+// "running" после падения экземпляра, отмечаются прерванными: раз блокировка свободна, они не выполняются
+func (s *SchedulerService) startRun(job *scheduledJob, triggeredBy string) (*models.JobRun, *repositories.AdvisoryLock, error) {
+	lock, err := s.lockManager.TryLock(context.Background(), jobLockPrefix+job.name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if lock == nil {
+		return nil, nil, ErrJobRunning
+	}
+
+	now := time.Now()
+	if err := s.jobRunRepo.FailInterruptedRuns(job.name, now); err != nil {
+		lock.Unlock()
+		return nil, nil, err
+	}
+	run := &models.JobRun{
+		JobName:     job.name,
+		TriggeredBy: triggeredBy,
+		Instance:    s.instance,
+		StartedAt:   now,
+	}
+	if err := s.jobRunRepo.StartRun(run); err != nil {
+		lock.Unlock()
+		return nil, nil, err
+	}
+	return run, lock, nil
+}
+
+// finishRun выполняет задачу, сохраняет результат запуска и снимает блокировку задачи
+func (s *SchedulerService) finishRun(job *scheduledJob, run *models.JobRun, lock *repositories.AdvisoryLock) {
+	defer func() {
+		if err := lock.Unlock(); err != nil {
+			utils.Log.WithField("job", job.name).WithError(err).Warn("Failed to release job lock")
 		}
 	}()
+
+	logger := utils.Log.WithFields(logrus.Fields{
+		"job":          job.name,
+		"triggered_by": run.TriggeredBy,
+	})
+	logger.Info("Job started")
+
+	err := runJob(job)
+	finishedAt := time.Now()
+	duration := finishedAt.Sub(run.StartedAt).Milliseconds()
+	run.FinishedAt = &finishedAt
+	run.DurationMs = &duration
+	if err != nil {
+		run.Status = models.JobRunFailed
+		run.Error = err.Error()
+		logger.WithError(err).Warn("Job failed")
+	} else {
+		run.Status = models.JobRunSucceeded
+		logger.WithField("duration_ms", duration).Info("Job finished")
+	}
+
+	if err := s.jobRunRepo.FinishRun(run); err != nil {
+		logger.WithError(err).Error("Failed to save job run")
+	}
 }
 
-func (s *SchedulerService) ProcessOverduePayments() {
-	utils.Log.Info("Processing overdue payments...")
+// runJob выполняет задачу, превращая панику в ошибку запуска
+func runJob(job *scheduledJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.run()
+}
+
+// RunJob запускает задачу вручную в фоне и возвращает созданную запись запуска.
+// Если задача уже выполняется на любом экземпляре, возвращается ErrJobRunning
+func (s *SchedulerService) RunJob(name string) (*models.JobRun, error) {
+	job, ok := s.jobsByName[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	if s.ctx.Err() != nil {
+		return nil, errors.New("scheduler is stopped")
+	}
+
+	run, lock, err := s.startRun(job, models.JobTriggerManual)
+	if err != nil {
+		return nil, err
+	}
+	started := *run
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.finishRun(job, run, lock)
+	}()
+	return &started, nil
+}
 
-	// Обрабатываем просроченные платежи
-	if err := s.paymentService.ProcessOverduePayments(); err != nil {
-		utils.Log.WithError(err).Warn("Error processing overdue payments")
+// GetJobs возвращает зарегистрированные задачи с расписанием, временем следующего и последним запуском
+func (s *SchedulerService) GetJobs() ([]models.JobInfo, error) {
+	jobs := make([]models.JobInfo, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		next, err := s.nextRun(job)
+		if err != nil {
+			return nil, err
+		}
+		last, err := s.jobRunRepo.GetLastRun(job.name, "")
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, models.JobInfo{
+			Name:     job.name,
+			Schedule: job.spec,
+			NextRun:  next,
+			LastRun:  last,
+		})
 	}
+	return jobs, nil
+}
 
-	utils.Log.Info("Finished processing overdue payments")
-}
\ No newline at end of file
+// GetJobRuns возвращает последние запуски задачи
+func (s *SchedulerService) GetJobRuns(name string) ([]models.JobRun, error) {
+	if _, ok := s.jobsByName[name]; !ok {
+		return nil, ErrJobNotFound
+	}
+	return s.jobRunRepo.GetRuns(name, jobRunsHistoryLimit)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/go-mail/mail/v2"
 	"github.com/sirupsen/logrus"
 	"github.com/vanhellthing93/sf.mephi.go_homework/config"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
 )
 
@@ -34,13 +36,27 @@ func NewSMTPService() *SMTPService {
 }
 
 func (s *SMTPService) SendEmail(to, subject, body string) error {
+	return s.send(to, s.newMessage(to, subject, body))
+}
+
+// SendEmailWithAttachment отправляет письмо с вложением content под именем filename
+func (s *SMTPService) SendEmailWithAttachment(to, subject, body, filename string, content []byte) error {
+	m := s.newMessage(to, subject, body)
+	m.AttachReader(filename, bytes.NewReader(content))
+	return s.send(to, m)
+}
+
+func (s *SMTPService) newMessage(to, subject, body string) *mail.Message {
 	// Создаем сообщение
 	m := mail.NewMessage()
 	m.SetHeader("From", s.from)
 	m.SetHeader("To", to)
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/html", body)
+	return m
+}
 
+func (s *SMTPService) send(to string, m *mail.Message) error {
 	// Отправляем сообщение
 	if err := s.dialer.DialAndSend(m); err != nil {
 		utils.Log.WithError(err).Warn("SMTP error.")
@@ -66,11 +82,11 @@ func (s *SMTPService) SendRegistrationNotification(userEmail string) error {
 	return s.SendEmail(userEmail, "Регистрация в банковском сервисе", content)
 }
 
-func (s *SMTPService) SendOverduePaymentNotification(userEmail string, amount float64) error {
+func (s *SMTPService) SendOverduePaymentNotification(userEmail string, amount models.Money) error {
 	// Создаем тело письма
 	content := fmt.Sprintf(`
 		<h1>У вас есть просроченный платеж!</h1>
-		<p>Сумма: <strong>%.2f RUB</strong></p>
+		<p>Сумма: <strong>%s RUB</strong></p>
 		<p>Пожалуйста, погасите задолженность как можно скорее.</p>
 		<p>Дата: %s</p>
 		<small>Это автоматическое уведомление</small>
@@ -80,11 +96,11 @@ func (s *SMTPService) SendOverduePaymentNotification(userEmail string, amount fl
 	return s.SendEmail(userEmail, "Просроченный платеж", content)
 }
 
-func (s *SMTPService) SendCreditNotification(userEmail string, amount, interestRate float64, term int) error {
+func (s *SMTPService) SendCreditNotification(userEmail string, amount models.Money, interestRate float64, term int) error {
 	// Создаем тело письма
 	content := fmt.Sprintf(`
 		<h1>Кредит успешно оформлен!</h1>
-		<p>Сумма: <strong>%.2f RUB</strong></p>
+		<p>Сумма: <strong>%s RUB</strong></p>
 		<p>Процентная ставка: <strong>%.2f%%</strong></p>
 		<p>Срок: <strong>%d месяцев</strong></p>
 		<p>Дата: %s</p>
@@ -93,4 +109,63 @@ func (s *SMTPService) SendCreditNotification(userEmail string, amount, interestR
 
 	// Отправляем письмо
 	return s.SendEmail(userEmail, "Кредит успешно оформлен", content)
-}
\ No newline at end of file
+}
+func (s *SMTPService) SendRestructuringNotification(userEmail string, restructuring *models.CreditRestructuring, nextPayment models.PaymentSchedule) error {
+	// Создаем тело письма
+	content := fmt.Sprintf(`
+		<h1>Условия вашего кредита изменены</h1>
+		<p>Кредитные каникулы: <strong>%d мес.</strong></p>
+		<p>Процентная ставка: <strong>%.2f%%</strong></p>
+		<p>Срок: <strong>%d месяцев</strong></p>
+		<p>Ближайший платеж: <strong>%s RUB</strong> до %s</p>
+		<p>Дата: %s</p>
+		<small>Это автоматическое уведомление</small>
+	`, restructuring.GraceMonths, restructuring.NewInterestRate, restructuring.NewTerm,
+		nextPayment.Amount, nextPayment.DueDate.Format("02.01.2006"), time.Now().Format("02.01.2006 15:04:05"))
+
+	// Отправляем письмо
+	return s.SendEmail(userEmail, "Изменение условий кредита", content)
+}
+
+func (s *SMTPService) SendClosureCertificate(userEmail string, certificate *models.ClosureCertificate) error {
+	// Создаем тело письма
+	content := fmt.Sprintf(`
+		<h1>Кредит полностью погашен!</h1>
+		<p>Справка о погашении № <strong>%s</strong> во вложении.</p>
+		<p>Справка подписана PGP-ключом банка.</p>
+		<p>Дата: %s</p>
+		<small>Это автоматическое уведомление</small>
+	`, certificate.Number, time.Now().Format("02.01.2006 15:04:05"))
+
+	// Отправляем письмо со справкой во вложении
+	filename := fmt.Sprintf("closure-certificate-%s.txt", certificate.Number)
+	return s.SendEmailWithAttachment(userEmail, "Справка о погашении кредита", content, filename, []byte(certificate.Document))
+}
+
+func (s *SMTPService) SendCardRevealCode(userEmail, code string, expiresAt time.Time) error {
+	// Создаем тело письма
+	content := fmt.Sprintf(`
+		<h1>Код для просмотра реквизитов карты</h1>
+		<p>Ваш одноразовый код: <strong>%s</strong></p>
+		<p>Код действует до %s.</p>
+		<p>Если вы не запрашивали код, заблокируйте карту и смените пароль.</p>
+		<small>Это автоматическое уведомление</small>
+	`, code, expiresAt.Format("02.01.2006 15:04"))
+
+	// Отправляем письмо
+	return s.SendEmail(userEmail, "Код для просмотра реквизитов карты", content)
+}
+
+func (s *SMTPService) SendPaymentReminder(userEmail string, amount models.Money, dueDate time.Time) error {
+	// Создаем тело письма
+	content := fmt.Sprintf(`
+		<h1>Напоминание о платеже по кредиту</h1>
+		<p>Сумма платежа по графику: <strong>%s RUB</strong></p>
+		<p>Дата платежа: <strong>%s</strong></p>
+		<p>Пожалуйста, пополните счет заранее, чтобы избежать просрочки.</p>
+		<small>Это автоматическое уведомление</small>
+	`, amount, dueDate.Format("02.01.2006"))
+
+	// Отправляем письмо
+	return s.SendEmail(userEmail, "Напоминание о платеже по кредиту", content)
+}
@@ -11,20 +11,20 @@ import (
 type TransactionService struct {
 	repo         *repositories.TransactionRepository
 	accountRepo  *repositories.AccountRepository
+	uow          *repositories.UnitOfWork
 }
 
-func NewTransactionService(repo *repositories.TransactionRepository, accountRepo *repositories.AccountRepository) *TransactionService {
+func NewTransactionService(repo *repositories.TransactionRepository, accountRepo *repositories.AccountRepository, uow *repositories.UnitOfWork) *TransactionService {
 	return &TransactionService{
 		repo:         repo,
 		accountRepo:  accountRepo,
+		uow:          uow,
 	}
 }
 
-func (s *TransactionService) CreateTransaction(accountID uint, amount float64, transactionType, category, description string) (*models.Transaction, error) {
-	// Проверяем, что счет существует и принадлежит пользователю
-	account, err := s.accountRepo.GetAccountByID(accountID)
-	if err != nil {
-		return nil, fmt.Errorf("account not found: %v", err)
+func (s *TransactionService) CreateTransaction(accountID uint, amount models.Money, transactionType, category, description string) (*models.Transaction, error) {
+	if err := validateTransaction(amount, transactionType); err != nil {
+		return nil, err
 	}
 
 	// Создаем операцию
@@ -37,20 +37,36 @@ func (s *TransactionService) CreateTransaction(accountID uint, amount float64, t
 		CreatedAt:  time.Now(),
 	}
 
-	// Сохраняем операцию в базе данных
-	if err := s.repo.CreateTransaction(transaction); err != nil {
-		return nil, fmt.Errorf("failed to create transaction: %v", err)
-	}
-
-	// Обновляем баланс счета
-	if transactionType == "income" {
-		account.Balance += amount
-	} else {
-		account.Balance -= amount
-	}
-
-	if err := s.accountRepo.UpdateAccount(account); err != nil {
-		return nil, fmt.Errorf("failed to update account balance: %v", err)
+	err := s.uow.Do(func(tx *repositories.Tx) error {
+		// Блокируем счет до конца транзакции
+		account, err := tx.Accounts.GetAccountForUpdate(accountID)
+		if err != nil {
+			return fmt.Errorf("account not found: %v", err)
+		}
+
+		delta := balanceDelta(transactionType, amount)
+		if err := checkBalanceDelta(account, delta); err != nil {
+			return err
+		}
+
+		// Сохраняем операцию в базе данных
+		if err := tx.Transactions.CreateTransaction(transaction); err != nil {
+			return fmt.Errorf("failed to create transaction: %v", err)
+		}
+
+		// Обновляем баланс счета
+		if err := tx.Accounts.AdjustBalance(accountID, delta); err != nil {
+			return fmt.Errorf("failed to update account balance: %v", err)
+		}
+
+		// Отражаем операцию в главной книге
+		if err := tx.Ledger.PostEntry(transactionEntry(transaction, account.Currency, false)); err != nil {
+			return fmt.Errorf("failed to post transaction to ledger: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return transaction, nil
@@ -65,82 +81,135 @@ func (s *TransactionService) GetTransactionByID(transactionID uint) (*models.Tra
 }
 
 func (s *TransactionService) UpdateTransaction(transaction *models.Transaction) error {
-	// Получаем текущую операцию
-	currentTransaction, err := s.repo.GetTransactionByID(transaction.ID)
+	if err := validateTransaction(transaction.Amount, transaction.Type); err != nil {
+		return err
+	}
+
+	return s.uow.Do(func(tx *repositories.Tx) error {
+		// Блокируем счет, затем операцию — в том же порядке, что и при создании
+		account, err := tx.Accounts.GetAccountForUpdate(transaction.AccountID)
+		if err != nil {
+			return fmt.Errorf("failed to get account: %v", err)
+		}
+
+		// Получаем текущую операцию
+		currentTransaction, err := tx.Transactions.GetTransactionForUpdate(transaction.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get transaction: %v", err)
+		}
+
+		// Корректируем баланс счета: отменяем старую сумму и применяем новую
+		delta, err := balanceDelta(transaction.Type, transaction.Amount).SubChecked(balanceDelta(currentTransaction.Type, currentTransaction.Amount))
+		if err != nil {
+			return validationError("invalid transaction amount: %v", err)
+		}
+		if err := checkBalanceDelta(account, delta); err != nil {
+			return err
+		}
+
+		// Обновляем операцию
+		if err := tx.Transactions.UpdateTransaction(transaction); err != nil {
+			return fmt.Errorf("failed to update transaction: %v", err)
+		}
+
+		// Обновляем баланс счета
+		if err := tx.Accounts.AdjustBalance(account.ID, delta); err != nil {
+			return fmt.Errorf("failed to update account balance: %v", err)
+		}
+
+		// Проводки неизменяемы: сторнируем старую операцию и проводим новую
+		if err := tx.Ledger.PostEntry(transactionEntry(currentTransaction, account.Currency, true)); err != nil {
+			return fmt.Errorf("failed to post transaction reversal to ledger: %v", err)
+		}
+		if err := tx.Ledger.PostEntry(transactionEntry(transaction, account.Currency, false)); err != nil {
+			return fmt.Errorf("failed to post transaction to ledger: %v", err)
+		}
+		return nil
+	})
+}
+
+func (s *TransactionService) DeleteTransaction(transactionID uint) error {
+	// Получаем операцию, чтобы узнать счет
+	transaction, err := s.repo.GetTransactionByID(transactionID)
 	if err != nil {
 		return fmt.Errorf("failed to get transaction: %v", err)
 	}
 
-	// Получаем счет
-	account, err := s.accountRepo.GetAccountByID(transaction.AccountID)
+	return s.uow.Do(func(tx *repositories.Tx) error {
+		account, err := tx.Accounts.GetAccountForUpdate(transaction.AccountID)
+		if err != nil {
+			return fmt.Errorf("failed to get account: %v", err)
+		}
+
+		// Перечитываем операцию под блокировкой: она могла измениться параллельно
+		transaction, err := tx.Transactions.GetTransactionForUpdate(transactionID)
+		if err != nil {
+			return fmt.Errorf("failed to get transaction: %v", err)
+		}
+
+		// Корректируем баланс счета
+		delta := balanceDelta(transaction.Type, transaction.Amount).Neg()
+		if err := checkBalanceDelta(account, delta); err != nil {
+			return err
+		}
+
+		// Удаляем операцию
+		if err := tx.Transactions.DeleteTransaction(transactionID); err != nil {
+			return fmt.Errorf("failed to delete transaction: %v", err)
+		}
+
+		// Обновляем баланс счета
+		if err := tx.Accounts.AdjustBalance(account.ID, delta); err != nil {
+			return fmt.Errorf("failed to update account balance: %v", err)
+		}
+
+		// Сторнируем операцию в главной книге
+		if err := tx.Ledger.PostEntry(transactionEntry(transaction, account.Currency, true)); err != nil {
+			return fmt.Errorf("failed to post transaction reversal to ledger: %v", err)
+		}
+		return nil
+	})
+}
+
+func (s *TransactionService) AccountBelongsToUser(accountID, userID uint) bool {
+	account, err := s.accountRepo.GetAccountByID(accountID)
 	if err != nil {
-		return fmt.Errorf("failed to get account: %v", err)
+		return false
 	}
 
-	// Корректируем баланс счета
-	if currentTransaction.Type == "income" {
-		account.Balance -= currentTransaction.Amount
-	} else {
-		account.Balance += currentTransaction.Amount
-	}
+	return account.UserID == userID
+}
 
-	if transaction.Type == "income" {
-		account.Balance += transaction.Amount
-	} else {
-		account.Balance -= transaction.Amount
+func validateTransaction(amount models.Money, transactionType string) error {
+	if !amount.IsPositive() {
+		return validationError("transaction amount must be positive")
 	}
-
-	// Обновляем операцию
-	if err := s.repo.UpdateTransaction(transaction); err != nil {
-		return fmt.Errorf("failed to update transaction: %v", err)
+	if transactionType != "income" && transactionType != "expense" {
+		return validationError("transaction type must be \"income\" or \"expense\"")
 	}
-
-	// Обновляем баланс счета
-	if err := s.accountRepo.UpdateAccount(account); err != nil {
-		return fmt.Errorf("failed to update account balance: %v", err)
-	}
-
 	return nil
 }
 
-func (s *TransactionService) DeleteTransaction(transactionID uint) error {
-	// Получаем операцию
-	transaction, err := s.repo.GetTransactionByID(transactionID)
-	if err != nil {
-		return fmt.Errorf("failed to get transaction: %v", err)
+// checkBalanceDelta проверяет, что после изменения на delta доступный остаток счета
+// не станет отрицательным и поместится в колонку баланса
+func checkBalanceDelta(account *models.Account, delta models.Money) error {
+	if _, err := account.Balance.AddChecked(delta); err != nil {
+		return validationError("invalid transaction amount: %v", err)
 	}
-
-	// Получаем счет
-	account, err := s.accountRepo.GetAccountByID(transaction.AccountID)
+	available, err := account.AvailableBalance.AddChecked(delta)
 	if err != nil {
-		return fmt.Errorf("failed to get account: %v", err)
+		return validationError("invalid transaction amount: %v", err)
 	}
-
-	// Корректируем баланс счета
-	if transaction.Type == "income" {
-		account.Balance -= transaction.Amount
-	} else {
-		account.Balance += transaction.Amount
+	if available.IsNegative() {
+		return validationError("insufficient funds")
 	}
-
-	// Удаляем операцию
-	if err := s.repo.DeleteTransaction(transactionID); err != nil {
-		return fmt.Errorf("failed to delete transaction: %v", err)
-	}
-
-	// Обновляем баланс счета
-	if err := s.accountRepo.UpdateAccount(account); err != nil {
-		return fmt.Errorf("failed to update account balance: %v", err)
-	}
-
 	return nil
 }
 
-func (s *TransactionService) AccountBelongsToUser(accountID, userID uint) bool {
-	account, err := s.accountRepo.GetAccountByID(accountID)
-	if err != nil {
-		return false
+// balanceDelta возвращает изменение баланса счета от операции
+func balanceDelta(transactionType string, amount models.Money) models.Money {
+	if transactionType == "income" {
+		return amount
 	}
-
-	return account.UserID == userID
-}
\ No newline at end of file
+	return amount.Neg()
+}
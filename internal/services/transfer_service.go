@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
@@ -12,53 +13,118 @@ import (
 type TransferService struct {
 	repo          *repositories.TransferRepository
 	accountRepo   *repositories.AccountRepository
+	uow           *repositories.UnitOfWork
+	cbrService    *CBRService
 }
 
-func NewTransferService(repo *repositories.TransferRepository, accountRepo *repositories.AccountRepository) *TransferService {
+func NewTransferService(repo *repositories.TransferRepository, accountRepo *repositories.AccountRepository, uow *repositories.UnitOfWork, cbrService *CBRService) *TransferService {
 	return &TransferService{
 		repo:          repo,
 		accountRepo:   accountRepo,
+		uow:           uow,
+		cbrService:    cbrService,
 	}
 }
 
-func (s *TransferService) CreateTransfer(fromAccountID, toAccountID uint, amount float64, description string) (*models.Transfer, error) {
-    // Проверяем, что счет отправителя существует и принадлежит пользователю
-    fromAccount, err := s.accountRepo.GetAccountByID(fromAccountID)
-    if err != nil {
-        return nil, fmt.Errorf("from account not found: %v", err)
-    }
+func (s *TransferService) CreateTransfer(fromAccountID, toAccountID uint, amount models.Money, description string) (*models.Transfer, error) {
+	if !amount.IsPositive() {
+		return nil, validationError("transfer amount must be positive")
+	}
+	if fromAccountID == toAccountID {
+		return nil, validationError("cannot transfer to the same account")
+	}
 
-    // Проверяем, что счет получателя существует
-    toAccount, err := s.accountRepo.GetAccountByID(toAccountID)
-    if err != nil {
-        return nil, fmt.Errorf("to account not found: %v", err)
-    }
+	fromAccount, err := s.accountRepo.GetAccountByID(fromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	toAccount, err := s.accountRepo.GetAccountByID(toAccountID)
+	if err != nil {
+		return nil, err
+	}
 
-    // Проверяем баланс отправителя
-    if fromAccount.Balance < amount {
-        return nil, fmt.Errorf("insufficient funds")
-    }
+	// Курс запрашивается у ЦБ РФ до открытия транзакции, чтобы не держать блокировки счетов во время сетевого запроса
+	rate, err := s.exchangeRate(fromAccount.Currency, toAccount.Currency)
+	if err != nil {
+		return nil, err
+	}
 
-    // Проверяем, что валюты совпадают
-    if fromAccount.Currency != toAccount.Currency {
-        return nil, fmt.Errorf("currency mismatch")
-    }
+	amount = amount.WithCurrency(fromAccount.Currency)
+	toAmount, err := models.MoneyFromRatChecked(new(big.Rat).Mul(amount.Rat(), rate), toAccount.Currency, models.DefaultRounding)
+	if err != nil {
+		return nil, validationError("transfer amount is too large to convert")
+	}
+	if !toAmount.IsPositive() {
+		return nil, validationError("transfer amount is too small to convert")
+	}
+	exchangeRate, _ := rate.Float64()
+
+	transfer := &models.Transfer{
+		FromAccount:  fromAccountID,
+		ToAccount:    toAccountID,
+		Amount:       amount,
+		Currency:     fromAccount.Currency,
+		ToAmount:     toAmount,
+		ToCurrency:   toAccount.Currency,
+		ExchangeRate: exchangeRate,
+		Description:  description,
+		CreatedAt:    time.Now(),
+	}
 
-    // Создаем перевод
-    transfer := &models.Transfer{
-        FromAccount: fromAccountID,
-        ToAccount:   toAccountID,
-        Amount:      amount,
-        Description: description,
-        CreatedAt:   time.Now(),
-    }
+	// Проверка баланса и перенос средств выполняются в одной транзакции
+	// под блокировкой обоих счетов, поэтому параллельные переводы не уведут счет в минус
+	err = s.uow.Do(func(tx *repositories.Tx) error {
+		accounts, err := tx.Accounts.LockAccounts(fromAccountID, toAccountID)
+		if err != nil {
+			return err
+		}
+		fromAccount := accounts[fromAccountID]
+		toAccount := accounts[toAccountID]
+
+		// Валюта счетов могла измениться, пока запрашивался курс
+		if fromAccount.Currency != transfer.Currency || toAccount.Currency != transfer.ToCurrency {
+			return fmt.Errorf("account currency changed, please retry")
+		}
+
+		// Проверяем доступный остаток отправителя: заблокированные средства перевести нельзя
+		if fromAccount.AvailableBalance.LessThan(amount) {
+			return validationError("insufficient funds")
+		}
+		// Баланс получателя должен поместиться в колонку счета
+		if _, err := toAccount.Balance.AddChecked(transfer.ToAmount); err != nil {
+			return validationError("transfer amount is too large: %v", err)
+		}
+
+		// Сохраняем перевод в базе данных
+		if err := tx.Transfers.CreateTransfer(transfer); err != nil {
+			return fmt.Errorf("failed to create transfer: %v", err)
+		}
+
+		// Отражаем перевод в главной книге
+		if err := tx.Ledger.PostEntry(transferEntry(transfer)); err != nil {
+			return fmt.Errorf("failed to post transfer to ledger: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-    // Сохраняем перевод в базе данных
-    if err := s.repo.CreateTransfer(transfer); err != nil {
-        return nil, fmt.Errorf("failed to create transfer: %v", err)
-    }
+	return transfer, nil
+}
+
+// exchangeRate возвращает курс конвертации из валюты счета отправителя в валюту счета получателя
+// с учетом спреда банка. Для счетов в одной валюте курс равен 1
+func (s *TransferService) exchangeRate(fromCurrency, toCurrency string) (*big.Rat, error) {
+	if fromCurrency == toCurrency {
+		return big.NewRat(1, 1), nil
+	}
 
-    return transfer, nil
+	marketRate, err := s.cbrService.GetExchangeRate(fromCurrency, toCurrency, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exchange rate: %v", err)
+	}
+	return applySpread(marketRate, fxSpreadPercent()), nil
 }
 
 func (s *TransferService) GetTransfersByAccountID(accountID uint) ([]models.Transfer, error) {
@@ -75,3 +75,25 @@ func (s *UserService) AuthenticateUser(email, password string) (*models.User, er
 	}
 	return user, nil
 }
+
+// GetPaymentReminderDays возвращает, за сколько дней до платежа по кредиту пользователь получает напоминание,
+// и задано ли это значение пользователем
+func (s *UserService) GetPaymentReminderDays(userID uint) (int, bool, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return 0, false, err
+	}
+	if user.PaymentReminderDays == nil {
+		return defaultPaymentReminderDays(), false, nil
+	}
+	return *user.PaymentReminderDays, true, nil
+}
+
+// SetPaymentReminderDays задает, за сколько дней до платежа напоминать пользователю.
+// 0 отключает напоминания, nil возвращает значение по умолчанию
+func (s *UserService) SetPaymentReminderDays(userID uint, days *int) error {
+	if days != nil && (*days < 0 || *days > maxPaymentReminderDays) {
+		return validationError("payment_reminder_days must be between 0 and %d", maxPaymentReminderDays)
+	}
+	return s.repo.SetPaymentReminderDays(userID, days)
+}
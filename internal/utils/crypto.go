@@ -9,30 +9,42 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/joho/godotenv"
-
-
 )
 
 // Переменные для ключей и секретов
 var (
 	pgpEntity  *openpgp.Entity
 	hmacSecret []byte
+
+	cryptoOnce sync.Once
+	cryptoErr  error
 )
 
-func init() {
-	if err := godotenv.Load(); err != nil {
+// InitCrypto загружает HMAC_SECRET и PGP-ключ банка. Ключи загружаются один раз при первом вызове;
+// main вызывает InitCrypto при старте, чтобы ошибка конфигурации обнаруживалась сразу, а не на первом запросе
+func InitCrypto() error {
+	cryptoOnce.Do(func() {
+		cryptoErr = loadKeys()
+	})
+	return cryptoErr
+}
+
+func loadKeys() error {
+	if err := godotenv.Load(); err != nil && Log != nil {
 		Log.WithError(err).Warn("Warning loading .env")
 	}
 
 	// Загружаем HMAC_SECRET из окружения
 	hmacSecretStr := os.Getenv("HMAC_SECRET")
 	if hmacSecretStr == "" {
-		Log.Panic("HMAC_SECRET is not set in environment")
+		return fmt.Errorf("HMAC_SECRET is not set in environment")
 	}
 	// if len(hmacSecretStr) < 32 {
 	// 	log.Printf("Warning: HMAC_SECRET is shorter than recommended 32 bytes")
@@ -43,22 +55,22 @@ func init() {
 	privateKeyFile := os.Getenv("PGP_PRIVATE_KEY")
 	privateKeyBytes, err := os.ReadFile(privateKeyFile)
 	if err != nil {
-		Log.Panic("failed to read PGP private key file")
+		return fmt.Errorf("failed to read PGP private key file: %v", err)
 	}
 	privateKeyArmored := string(privateKeyBytes)
 	if privateKeyArmored == "" {
-		Log.Panic("PGP_PRIVATE_KEY is not set in environment")
+		return fmt.Errorf("PGP_PRIVATE_KEY is not set in environment")
 	}
 
 	entityList, err := readPGPEntityFromString(privateKeyArmored)
 	if err != nil {
-		Log.Panic("failed to parse PGP private key")
+		return fmt.Errorf("failed to parse PGP private key: %v", err)
 	}
 	if len(entityList) == 0 {
-		Log.Panic("no PGP entities found in key")
+		return fmt.Errorf("no PGP entities found in key")
 	}
 	pgpEntity = entityList[0]
-	
+	return nil
 }
 
 func readPGPEntityFromString(key string) ([]*openpgp.Entity, error) {
@@ -74,6 +86,9 @@ func readPGPEntityFromString(key string) ([]*openpgp.Entity, error) {
 }
 
 func EncryptPGP(data string) (string, error) {
+	if err := InitCrypto(); err != nil {
+		return "", err
+	}
 	var buf bytes.Buffer
 	w, err := armor.Encode(&buf, "PGP MESSAGE", nil)
 	if err != nil {
@@ -93,6 +108,9 @@ func EncryptPGP(data string) (string, error) {
 }
 
 func DecryptPGP(encrypted string) (string, error) {
+	if err := InitCrypto(); err != nil {
+		return "", err
+	}
 	block, err := armor.Decode(strings.NewReader(encrypted))
 	if err != nil {
 		return "", err
@@ -108,7 +126,31 @@ func DecryptPGP(encrypted string) (string, error) {
 	return string(bytes), nil
 }
 
+// SignPGP подписывает текст ключом банка и возвращает документ с открытой подписью (clearsign),
+// который можно проверить публичным ключом, например командой gpg --verify
+func SignPGP(data string) (string, error) {
+	if err := InitCrypto(); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	plaintext, err := clearsign.Encode(&buf, pgpEntity.PrivateKey, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err := plaintext.Write([]byte(data)); err != nil {
+		return "", err
+	}
+	if err := plaintext.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ComputeHMAC паникует, если ключи не загружены: без HMAC_SECRET индекс поиска посчитать нельзя
 func ComputeHMAC(data string) string {
+	if err := InitCrypto(); err != nil {
+		panic(err)
+	}
 	h := hmac.New(sha256.New, hmacSecret)
 	h.Write([]byte(data))
 	return hex.EncodeToString(h.Sum(nil))
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/vanhellthing93/sf.mephi.go_homework/config"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/handlers"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/middleware"
+	"github.com/vanhellthing93/sf.mephi.go_homework/internal/models"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/repositories"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/services"
 	"github.com/vanhellthing93/sf.mephi.go_homework/internal/utils"
@@ -14,6 +20,9 @@ import (
 
 func main() {
 	utils.InitLogger()
+	if err := utils.InitCrypto(); err != nil {
+		utils.Log.WithError(err).Fatal("Failed to load encryption keys")
+	}
 
 	db := config.ConnectDB()
 	defer db.Close()
@@ -31,26 +40,63 @@ func main() {
 	paymentRepo := repositories.NewPaymentRepository(db)
 	analyticsRepo := repositories.NewAnalyticsRepository(db)
 	transactionRepo := repositories.NewTransactionRepository(db)
+	ledgerRepo := repositories.NewLedgerRepository(db)
+	idempotencyRepo := repositories.NewIdempotencyRepository(db)
+	fxQuoteRepo := repositories.NewFXQuoteRepository(db)
+	rateRepo := repositories.NewRateRepository(db)
+	creditProductRepo := repositories.NewCreditProductRepository(db)
+	penaltyRepo := repositories.NewPenaltyRepository(db)
+	creditApplicationRepo := repositories.NewCreditApplicationRepository(db)
+	creditRestructuringRepo := repositories.NewCreditRestructuringRepository(db)
+	closureCertificateRepo := repositories.NewClosureCertificateRepository(db)
+	paymentReminderRepo := repositories.NewPaymentReminderRepository(db)
+	jobRunRepo := repositories.NewJobRunRepository(db)
+	lockManager := repositories.NewLockManager(db)
+	cardPaymentRepo := repositories.NewCardPaymentRepository(db)
+	holdRepo := repositories.NewHoldRepository(db)
+	cardRevealRepo := repositories.NewCardRevealRepository(db)
+	uow := repositories.NewUnitOfWork(db)
 
 
 
 
 	// Инициализация сервисов
 	smtpService := services.NewSMTPService()
-	cbrService := services.NewCBRService()
+	cbrService := services.NewCBRService(rateRepo)
 	userService := services.NewUserService(userRepo, smtpService)
 	accountService := services.NewAccountService(accountRepo)
-	cardService := services.NewCardService(cardRepo)
-	transferService := services.NewTransferService(transferRepo, accountRepo)
-	creditService := services.NewCreditService(creditRepo, userRepo, cbrService, smtpService)
-	paymentService := services.NewPaymentService(paymentRepo, creditRepo, accountRepo, userRepo, smtpService)
+	cardService := services.NewCardService(cardRepo, cardRevealRepo, userRepo, smtpService, uow)
+	transferService := services.NewTransferService(transferRepo, accountRepo, uow, cbrService)
+	creditService := services.NewCreditService(creditRepo, userRepo, accountRepo, creditApplicationRepo, creditProductRepo, cbrService, smtpService, uow)
+	paymentService := services.NewPaymentService(paymentRepo, creditRepo, accountRepo, userRepo, penaltyRepo, closureCertificateRepo, smtpService, uow)
 	analyticsService := services.NewAnalyticsService(analyticsRepo)
-	transactionService := services.NewTransactionService(transactionRepo, accountRepo)
+	transactionService := services.NewTransactionService(transactionRepo, accountRepo, uow)
+	ledgerService := services.NewLedgerService(ledgerRepo, accountRepo)
+	fxService := services.NewFXService(fxQuoteRepo, accountRepo, uow, cbrService)
+	creditScorer := services.NewDebtToIncomeScorer(analyticsRepo)
+	creditApplicationService := services.NewCreditApplicationService(creditApplicationRepo, accountRepo, creditProductRepo, cbrService, creditScorer)
+	creditRestructuringService := services.NewCreditRestructuringService(creditRestructuringRepo, creditRepo, userRepo, smtpService, uow)
+	reminderService := services.NewReminderService(paymentReminderRepo, smtpService, uow)
+	cardPaymentService := services.NewCardPaymentService(cardPaymentRepo, cardRepo, cardService, uow)
+	holdService := services.NewHoldService(holdRepo, accountRepo, uow)
+
+	// Перенос в главную книгу остатков, созданных до ее ведения
+	if err := ledgerService.PostOpeningBalances(); err != nil {
+		utils.Log.WithError(err).Fatal("Failed to post opening balances to ledger")
+	}
+
+	// Хеши и маскированные номера для карт, выпущенных до их появления
+	if err := cardService.IndexCardNumbers(); err != nil {
+		utils.Log.WithError(err).Fatal("Failed to index card numbers")
+	}
 
 
 
 	// Инициализация шедулера
-	schedulerService := services.NewSchedulerService(paymentService)
+	schedulerService, err := services.NewSchedulerService(jobRunRepo, lockManager, cardService, cardPaymentService, holdService, paymentService, ledgerService, creditApplicationService, reminderService)
+	if err != nil {
+		utils.Log.WithError(err).Fatal("Failed to initialize scheduler")
+	}
 	schedulerService.Start()
 
 	// Инициализация обработчиков
@@ -62,6 +108,14 @@ func main() {
 	paymentHandler := handlers.NewPaymentHandler(paymentService)
 	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService)
 	transactionHandler := handlers.NewTransactionHandler(transactionService)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerService)
+	fxHandler := handlers.NewFXHandler(fxService)
+	rateHandler := handlers.NewRateHandler(cbrService)
+	creditApplicationHandler := handlers.NewCreditApplicationHandler(creditApplicationService)
+	creditRestructuringHandler := handlers.NewCreditRestructuringHandler(creditRestructuringService)
+	jobHandler := handlers.NewJobHandler(schedulerService)
+	cardPaymentHandler := handlers.NewCardPaymentHandler(cardPaymentService)
+	holdHandler := handlers.NewHoldHandler(holdService)
 
 
 
@@ -76,29 +130,67 @@ func main() {
 	authRouter := r.PathPrefix("/").Subrouter()
 	authRouter.Use(middleware.AuthMiddleware)
 
+	// Повтор запросов, изменяющих деньги, с тем же Idempotency-Key не создает дублей
+	idempotent := middleware.IdempotencyMiddleware(idempotencyRepo)
+
+	// Настройки пользователя
+	authRouter.HandleFunc("/profile/payment-reminders", userHandler.GetPaymentReminderSettings).Methods("GET")
+	authRouter.HandleFunc("/profile/payment-reminders", userHandler.SetPaymentReminderSettings).Methods("PUT")
+
 	// Управление счетами
 	authRouter.HandleFunc("/accounts", accountHandler.CreateAccount).Methods("POST")
 	authRouter.HandleFunc("/accounts", accountHandler.GetUserAccounts).Methods("GET")
+	authRouter.HandleFunc("/accounts/{account_id}/history", ledgerHandler.GetAccountHistory).Methods("GET")
+	authRouter.Handle("/accounts/{account_id}/holds", idempotent(http.HandlerFunc(holdHandler.CreateHold))).Methods("POST")
+	authRouter.HandleFunc("/accounts/{account_id}/holds", holdHandler.GetAccountHolds).Methods("GET")
+	authRouter.HandleFunc("/accounts/{account_id}/holds/{hold_id}/release", holdHandler.ReleaseHold).Methods("POST")
 
 	// Управление картами
 	authRouter.HandleFunc("/accounts/{account_id}/cards", cardHandler.CreateCard).Methods("POST")
 	authRouter.HandleFunc("/accounts/{account_id}/cards", cardHandler.GetAccountCards).Methods("GET")
 	authRouter.HandleFunc("/cards/{card_id}", cardHandler.GetCard).Methods("GET")
 	authRouter.HandleFunc("/cards/{card_id}", cardHandler.DeleteCard).Methods("DELETE")
+	authRouter.HandleFunc("/cards/{card_id}/block", cardHandler.BlockCard).Methods("POST")
+	authRouter.HandleFunc("/cards/{card_id}/unblock", cardHandler.UnblockCard).Methods("POST")
+	authRouter.HandleFunc("/cards/{card_id}/reissue", cardHandler.ReissueCard).Methods("POST")
+	authRouter.HandleFunc("/cards/{card_id}/limits", cardHandler.GetCardLimits).Methods("GET")
+	authRouter.HandleFunc("/cards/{card_id}/limits", cardHandler.UpdateCardLimits).Methods("PATCH")
+	authRouter.HandleFunc("/cards/{card_id}/declines", cardHandler.GetCardDeclines).Methods("GET")
+	authRouter.HandleFunc("/cards/{card_id}/reveal", cardHandler.RevealCard).Methods("POST")
+	authRouter.HandleFunc("/cards/{card_id}/reveal/otp", cardHandler.RequestRevealOTP).Methods("POST")
+	authRouter.HandleFunc("/cards/{card_id}/reveal-audit", cardHandler.GetRevealEvents).Methods("GET")
 
 	// Управление переводами
-	authRouter.HandleFunc("/accounts/{from_account_id}/transfers", transferHandler.CreateTransfer).Methods("POST")
+	authRouter.Handle("/accounts/{from_account_id}/transfers", idempotent(http.HandlerFunc(transferHandler.CreateTransfer))).Methods("POST")
 	authRouter.HandleFunc("/accounts/{account_id}/transfers", transferHandler.GetAccountTransfers).Methods("GET")
 	authRouter.HandleFunc("/transfers/{transfer_id}", transferHandler.GetTransfer).Methods("GET")
 
+	// Обмен валюты между своими счетами
+	authRouter.HandleFunc("/fx/quotes", fxHandler.CreateQuote).Methods("POST")
+	authRouter.HandleFunc("/fx/quotes/{quote_id}", fxHandler.GetQuote).Methods("GET")
+	authRouter.Handle("/fx/quotes/{quote_id}/execute", idempotent(http.HandlerFunc(fxHandler.ExecuteQuote))).Methods("POST")
+
+	// История ключевой ставки и курсов валют ЦБ РФ
+	authRouter.HandleFunc("/rates/key-rate", rateHandler.GetKeyRateHistory).Methods("GET")
+	authRouter.HandleFunc("/rates/currency", rateHandler.GetCurrencyRateHistory).Methods("GET")
+
 	// Управление кредитами
-	authRouter.HandleFunc("/credits", creditHandler.CreateCredit).Methods("POST")
+	authRouter.HandleFunc("/credit-products", creditHandler.GetProducts).Methods("GET")
+	authRouter.Handle("/credit-applications", idempotent(http.HandlerFunc(creditApplicationHandler.SubmitApplication))).Methods("POST")
+	authRouter.HandleFunc("/credit-applications", creditApplicationHandler.GetUserApplications).Methods("GET")
+	authRouter.HandleFunc("/credit-applications/{application_id}", creditApplicationHandler.GetApplication).Methods("GET")
+	authRouter.Handle("/credits", idempotent(http.HandlerFunc(creditHandler.CreateCredit))).Methods("POST")
 	authRouter.HandleFunc("/credits", creditHandler.GetUserCredits).Methods("GET")
 	authRouter.HandleFunc("/credits/{credit_id}/schedule", creditHandler.GetPaymentSchedule).Methods("GET")
+	authRouter.HandleFunc("/credits/{credit_id}/balance", creditHandler.GetCreditBalance).Methods("GET")
+	authRouter.HandleFunc("/credits/{credit_id}/repayment-account", creditHandler.SetRepaymentAccount).Methods("PUT")
 
 	// Управление платежами
-	authRouter.HandleFunc("/credits/{credit_id}/payments", paymentHandler.CreatePayment).Methods("POST")
+	authRouter.Handle("/credits/{credit_id}/payments", idempotent(http.HandlerFunc(paymentHandler.CreatePayment))).Methods("POST")
 	authRouter.HandleFunc("/credits/{credit_id}/payments", paymentHandler.GetCreditPayments).Methods("GET")
+	authRouter.HandleFunc("/credits/{credit_id}/penalties", paymentHandler.GetCreditPenalties).Methods("GET")
+	authRouter.HandleFunc("/credits/{credit_id}/payoff-quote", paymentHandler.GetPayoffQuote).Methods("GET")
+	authRouter.HandleFunc("/credits/{credit_id}/closure-certificate", paymentHandler.GetClosureCertificate).Methods("GET")
 	authRouter.HandleFunc("/payments/{payment_id}", paymentHandler.GetPayment).Methods("GET")
 
 	// Аналитика
@@ -107,15 +199,55 @@ func main() {
 	authRouter.HandleFunc("/analytics/credit-load", analyticsHandler.GetCreditLoad).Methods("GET")
 	authRouter.HandleFunc("/analytics/monthly-stats", analyticsHandler.GetMonthlyStats).Methods("GET")
 
+	// Операции службы поддержки
+	supportRouter := authRouter.PathPrefix("/support").Subrouter()
+	supportRouter.Use(middleware.RequireRole(userRepo, models.RoleSupport))
+	supportRouter.Handle("/credits/{credit_id}/restructure", idempotent(http.HandlerFunc(creditRestructuringHandler.RestructureCredit))).Methods("POST")
+	supportRouter.HandleFunc("/credits/{credit_id}/restructurings", creditRestructuringHandler.GetRestructurings).Methods("GET")
+	supportRouter.HandleFunc("/jobs", jobHandler.GetJobs).Methods("GET")
+	supportRouter.HandleFunc("/jobs/{name}/runs", jobHandler.GetJobRuns).Methods("GET")
+	supportRouter.HandleFunc("/jobs/{name}/run", jobHandler.RunJob).Methods("POST")
+
+	// Прием оплаты картами торговыми предприятиями
+	merchantRouter := authRouter.PathPrefix("/card-payments").Subrouter()
+	merchantRouter.Use(middleware.RequireRole(userRepo, models.RoleMerchant))
+	// Тело авторизации содержит реквизиты карты, поэтому повторы сравниваются только по несекретным полям
+	authorizeIdempotent := middleware.IdempotencyMiddlewareWithFingerprint(idempotencyRepo, services.CardAuthorizationFingerprint)
+	merchantRouter.Handle("/authorize", authorizeIdempotent(http.HandlerFunc(cardPaymentHandler.Authorize))).Methods("POST")
+	merchantRouter.HandleFunc("/{authorization_id}", cardPaymentHandler.GetAuthorization).Methods("GET")
+	merchantRouter.Handle("/{authorization_id}/capture", idempotent(http.HandlerFunc(cardPaymentHandler.Capture))).Methods("POST")
+	merchantRouter.Handle("/{authorization_id}/void", idempotent(http.HandlerFunc(cardPaymentHandler.Void))).Methods("POST")
+	merchantRouter.Handle("/{authorization_id}/refund", idempotent(http.HandlerFunc(cardPaymentHandler.Refund))).Methods("POST")
+
 	// Управление операциями
-	authRouter.HandleFunc("/accounts/{account_id}/transactions", transactionHandler.CreateTransaction).Methods("POST")
+	authRouter.Handle("/accounts/{account_id}/transactions", idempotent(http.HandlerFunc(transactionHandler.CreateTransaction))).Methods("POST")
 	authRouter.HandleFunc("/accounts/{account_id}/transactions", transactionHandler.GetAccountTransactions).Methods("GET")
 	authRouter.HandleFunc("/transactions/{transaction_id}", transactionHandler.GetTransaction).Methods("GET")
 	authRouter.HandleFunc("/transactions/{transaction_id}", transactionHandler.UpdateTransaction).Methods("PATCH")
 	authRouter.HandleFunc("/transactions/{transaction_id}", transactionHandler.DeleteTransaction).Methods("DELETE")
 
 
-	utils.Log.Info("Server is running on :8080")
-	http.ListenAndServe(":8080", r)
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		utils.Log.Info("Server is running on :8080")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			utils.Log.WithError(err).Fatal("Server failed")
+		}
+	}()
+
+	// Корректное завершение: сервер дообрабатывает запросы, планировщик дожидается выполняющихся задач
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+	utils.Log.Info("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		utils.Log.WithError(err).Warn("Failed to shut down server gracefully")
+	}
+	if err := schedulerService.Stop(ctx); err != nil {
+		utils.Log.WithError(err).Warn("Failed to stop scheduler gracefully")
+	}
 
 }
\ No newline at end of file